@@ -0,0 +1,28 @@
+// Package assets embeds the game's data files.
+package assets
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed ships behaviors
+var files embed.FS
+
+// Ships returns the stock ship class definitions, one JSON file per class.
+func Ships() fs.FS {
+	ships, err := fs.Sub(files, "ships")
+	if err != nil {
+		panic(err)
+	}
+	return ships
+}
+
+// Behaviors returns the stock behavior trees, one JSON file per tree.
+func Behaviors() fs.FS {
+	behaviors, err := fs.Sub(files, "behaviors")
+	if err != nil {
+		panic(err)
+	}
+	return behaviors
+}
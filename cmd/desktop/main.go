@@ -1,62 +1,139 @@
+//go:build !headless
+
 package main
 
 import (
+	"flag"
 	"fmt"
+	"github.com/StCredZero/vectrek/assets"
 	"github.com/StCredZero/vectrek/constants"
 	"github.com/StCredZero/vectrek/ecs"
 	"github.com/StCredZero/vectrek/ecstypes"
 	"github.com/StCredZero/vectrek/geom"
 	"github.com/hajimehoshi/ebiten/v2"
 	"log"
+	"os"
 )
 
-func newServerInstance(inputPipe ecstypes.Receiver, outputPipe ecstypes.Sender) *ecs.Instance {
-	instance := ecs.NewInstance(ecs.Parameters{
-		ScreenWidth:  constants.ScreenWidth,
-		ScreenHeight: constants.ScreenHeight,
+// playerClass is the ship class the player flies, and escortClass that of
+// the ships in their fleet.
+const (
+	playerClass = "cruiser"
+	escortClass = "scout"
+)
+
+// galaxyLayout is the shape of the galaxy: its grid of sectors and each
+// sector's size.
+type galaxyLayout struct {
+	Columns int
+	Rows    int
+	Wrap    bool
+	Width   float64
+	Height  float64
+	// Seed generates the sectors' content; zero leaves them empty.
+	Seed uint64
+}
+
+var (
+	// bigGalaxy is a bounded grid of sectors several screens across.
+	bigGalaxy = galaxyLayout{Columns: 3, Rows: 3, Width: 4 * constants.ScreenWidth, Height: 4 * constants.ScreenHeight, Seed: 1}
+	// oneScreen is a single wrapping sector the size of the screen. Nothing
+	// leaves it, so a recording of it replays exactly.
+	oneScreen = galaxyLayout{Columns: 1, Rows: 1, Wrap: true, Width: constants.ScreenWidth, Height: constants.ScreenHeight}
+
+	layouts = map[string]galaxyLayout{
+		"galaxy": bigGalaxy,
+		"screen": oneScreen,
+	}
+)
+
+// start is the sector the player starts in, in the middle of the galaxy.
+func (layout galaxyLayout) start() ecs.SectorCoord {
+	return ecs.SectorCoord{X: int32(layout.Columns / 2), Y: int32(layout.Rows / 2)}
+}
+
+// newServerGalaxy makes the galaxy with the player's ship in it, and returns
+// the ship's entity, which the client's ship shares.
+func newServerGalaxy(layout galaxyLayout, classes map[string]ecs.ShipClass, behaviors map[string]ecs.BehaviorTree, inputPipe ecstypes.Receiver, outputPipe ecstypes.Sender) (*ecs.Galaxy, ecstypes.EntityID) {
+	galaxy := ecs.NewGalaxy(layout.Columns, layout.Rows, layout.Wrap, ecs.Parameters{
+		ScreenWidth:  layout.Width,
+		ScreenHeight: layout.Height,
+		GalaxySeed:   layout.Seed,
 	})
-	instance.Name = "Server"
-	err := instance.AddEntity(
-		ecstypes.EntityID(0),
-		&ecs.Position{
+	galaxy.ShipClasses = classes
+	galaxy.Behaviors = behaviors
+	galaxy.SetReceiver(inputPipe)
+	galaxy.SetSender(outputPipe)
+	player, err := galaxy.Spawn(
+		layout.start(),
+		playerClass,
+		ecs.Position{
 			Vector: geom.Vector{
-				X: constants.ScreenWidth / 2,
-				Y: constants.ScreenHeight / 2,
+				X: layout.Width / 2,
+				Y: layout.Height / 2,
 			},
 		},
-		new(ecs.Motion),
-		new(ecs.Helm),
 		new(ecs.SyncSender),
+		new(ecs.Fleet),
 	)
 	if err != nil {
-		log.Fatalf("fatal error: %w", err)
+		log.Fatalf("fatal error: %v", err)
 	}
-	instance.SetReceiver(inputPipe)
-	instance.SetSender(outputPipe)
-	return instance
+	// two escorts in the player's fleet
+	for _, side := range []float64{-1, 1} {
+		_, err = galaxy.Spawn(
+			layout.start(),
+			escortClass,
+			ecs.Position{
+				Vector: geom.Vector{
+					X: layout.Width/2 - 80,
+					Y: layout.Height/2 + side*80,
+				},
+			},
+			new(ecs.AI),
+			&ecs.Wingman{Leader: player},
+			&ecs.Replicated{Class: escortClass},
+		)
+		if err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
+	}
+	return galaxy, player
 }
 
-func newClientInstance(inputPipe ecstypes.Receiver, outputPipe ecstypes.Sender) *ecs.Instance {
+func newClientInstance(layout galaxyLayout, player ecstypes.EntityID, classes map[string]ecs.ShipClass, inputPipe ecstypes.Receiver, outputPipe ecstypes.Sender) *ecs.Instance {
 	instance := ecs.NewInstance(ecs.Parameters{
-		ScreenWidth:  constants.ScreenWidth,
-		ScreenHeight: constants.ScreenHeight,
+		ScreenWidth:  layout.Width,
+		ScreenHeight: layout.Height,
+		GalaxySeed:   layout.Seed,
 	})
 	instance.Name = "Client"
+	instance.Client = true
+	instance.ShipClasses = classes
+	instance.Sector = layout.start()
 	err := instance.AddEntity(
-		ecstypes.EntityID(0),
+		player,
 		&ecs.Position{
 			Vector: geom.Vector{
-				X: constants.ScreenWidth / 2,
-				Y: constants.ScreenHeight / 2,
+				X: layout.Width / 2,
+				Y: layout.Height / 2,
 			},
 		},
 		new(ecs.Motion),
-		new(ecs.Sprite),
+		classes[playerClass].Sprite(),
 		new(ecs.Player),
 		new(ecs.SyncReceiver),
+		ecs.NewReactor(),
+		new(ecs.Warp),
+		new(ecs.Fleet),
 	)
 	if err != nil {
-		log.Fatalf("fatal error: %w", err)
+		log.Fatalf("fatal error: %v", err)
+	}
+	if layout.Seed != 0 {
+		if err = instance.GenerateSector(false); err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
 	}
 	instance.SetReceiver(inputPipe)
 	instance.SetSender(outputPipe)
@@ -64,19 +141,76 @@ func newClientInstance(inputPipe ecstypes.Receiver, outputPipe ecstypes.Sender)
 }
 
 func main() {
+	var layoutName = flag.String("layout", "galaxy", "play in the galaxy, or on a single screen")
+	var record = flag.String("record", "", "record the server to this replay file; needs -layout=screen")
+	var ships = flag.String("ships", "", "load ship classes from this directory instead of the stock ones")
+	var behaviors = flag.String("behaviors", "", "load NPC behavior trees from this directory instead of the stock ones")
+	flag.Parse()
+
 	var err error
+	var shipFiles = assets.Ships()
+	if *ships != "" {
+		shipFiles = os.DirFS(*ships)
+	}
+	classes, err := ecs.LoadShipClasses(shipFiles)
+	if err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
+	var behaviorFiles = assets.Behaviors()
+	if *behaviors != "" {
+		behaviorFiles = os.DirFS(*behaviors)
+	}
+	trees, err := ecs.LoadBehaviorTrees(behaviorFiles)
+	if err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
 	var serverReceiver = ecs.NewPipe()
 	var serverSender = ecs.NewPipe()
-	serverInstance := newServerInstance(serverReceiver, serverSender)
-	clientInstance := newClientInstance(serverSender, serverReceiver)
+	layout, ok := layouts[*layoutName]
+	if !ok {
+		log.Fatalf("fatal error: no layout %q: use galaxy or screen", *layoutName)
+	}
+	if *record != "" && layout.Columns*layout.Rows > 1 {
+		// a replay is of one Instance, and ships leave it for other sectors
+		log.Fatalf("fatal error: -record can't record the %s layout's sectors: use -layout=screen", *layoutName)
+	}
+	serverGalaxy, player := newServerGalaxy(layout, classes, trees, serverReceiver, serverSender)
+	clientInstance := newClientInstance(layout, player, classes, serverSender, serverReceiver)
+
+	var recorder *ecs.ReplayWriter
+	if *record != "" {
+		file, err := os.Create(*record)
+		if err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
+		defer file.Close()
+		sector, err := serverGalaxy.Sector(layout.start())
+		if err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
+		if recorder, err = ecs.NewReplayWriter(file, sector); err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
+		sector.Recorder = recorder
+	}
 
 	ebiten.SetWindowSize(constants.ScreenWidth, constants.ScreenHeight)
 	ebiten.SetWindowTitle("Vector (Ebitengine Demo)")
+	// update every frame; the client's Clock decides when to tick and
+	// interpolates in between
+	ebiten.SetTPS(ebiten.SyncWithFPS)
 	fmt.Println("about to run server")
 	done := make(chan bool, 10)
-	go serverInstance.RunServer(done)
+	go serverGalaxy.RunServer(done)
 	fmt.Println("about to run game")
-	if err = ebiten.RunGame(clientInstance); err != nil {
-		log.Fatalf("fatal error: %w", err)
+	err = ebiten.RunGame(clientInstance)
+	done <- true
+	if recorder != nil {
+		if flushErr := recorder.Flush(); flushErr != nil {
+			log.Printf("saving replay: %v", flushErr)
+		}
+	}
+	if err != nil {
+		log.Fatalf("fatal error: %v", err)
 	}
 }
@@ -0,0 +1,133 @@
+//go:build !headless
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/StCredZero/vectrek/assets"
+	"github.com/StCredZero/vectrek/constants"
+	"github.com/StCredZero/vectrek/ecs"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/transport"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"image/color"
+	"log"
+	"math"
+	"net/http"
+)
+
+const (
+	hostShip  = ecstypes.EntityID(0)
+	guestShip = ecstypes.EntityID(1)
+)
+
+// newDuelInstance builds the same starting world on both peers.
+func newDuelInstance() *ecs.Instance {
+	instance := ecs.NewInstance(ecs.Parameters{
+		ScreenWidth:   constants.ScreenWidth,
+		ScreenHeight:  constants.ScreenHeight,
+		Deterministic: true,
+		Seed:          1,
+	})
+	instance.Name = "Duel"
+	instance.SetSender(ecs.Discard{})
+	classes, err := ecs.LoadShipClasses(assets.Ships())
+	if err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
+	instance.ShipClasses = classes
+	for _, ship := range []struct {
+		Entity ecstypes.EntityID
+		X      float64
+		Angle  geom.Angle
+	}{
+		{Entity: hostShip, X: constants.ScreenWidth / 4, Angle: 0},
+		{Entity: guestShip, X: constants.ScreenWidth * 3 / 4, Angle: math.Pi},
+	} {
+		err := instance.SpawnEntity(
+			ship.Entity,
+			"cruiser",
+			ecs.Position{
+				Vector: geom.Vector{X: ship.X, Y: constants.ScreenHeight / 2},
+				Angle:  ship.Angle,
+			},
+		)
+		if err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
+	}
+	return instance
+}
+
+type duel struct {
+	Rollback *ecs.Rollback
+}
+
+func (d *duel) Update() error {
+	_, err := d.Rollback.Advance(ecs.KeyboardHelmInput())
+	return err
+}
+
+func (d *duel) Draw(screen *ebiten.Image) {
+	screen.Fill(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	d.Rollback.Instance.Draw(screen)
+	msg := fmt.Sprintf("Frame: %d\nRollbacks: %d", d.Rollback.Frame(), d.Rollback.Rollbacks)
+	if d.Rollback.Stalled() {
+		msg += "\nWaiting for peer..."
+	}
+	ebitenutil.DebugPrint(screen, msg)
+}
+
+func (d *duel) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return constants.ScreenWidth, constants.ScreenHeight
+}
+
+func main() {
+	var listen = flag.String("listen", "", "host a duel on this address, e.g. :8080")
+	var connect = flag.String("connect", "", "join a duel at this WebSocket URL, e.g. ws://host:8080/")
+	var syncTest = flag.Int("synctest", 0, "play alone, rolling back this many frames every frame to check determinism")
+	flag.Parse()
+
+	var rollback *ecs.Rollback
+	switch {
+	case *syncTest > 0:
+		rollback = ecs.NewRollback(newDuelInstance(), hostShip, guestShip)
+		rollback.SyncTest = *syncTest
+	case *listen != "":
+		server := transport.NewServer()
+		// the guest only steers their own ship
+		server.OnConnect = func(conn *transport.Conn) {
+			conn.Bind(guestShip)
+		}
+		go func() {
+			log.Fatal(http.ListenAndServe(*listen, server))
+		}()
+		rollback = ecs.NewRollback(newDuelInstance(), hostShip, guestShip)
+		rollback.Sender = server
+		rollback.Receiver = server
+	case *connect != "":
+		conn, err := transport.Dial(context.Background(), *connect)
+		if err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
+		defer conn.Close()
+		rollback = ecs.NewRollback(newDuelInstance(), guestShip, hostShip)
+		rollback.Sender = conn
+		rollback.Receiver = conn
+	default:
+		flag.Usage()
+		return
+	}
+
+	ebiten.SetWindowSize(constants.ScreenWidth, constants.ScreenHeight)
+	ebiten.SetWindowTitle("Vector Duel")
+	// rollback frames are simulation ticks, one per ebiten Update
+	ebiten.SetTPS(rollback.Instance.Parameters.TickRate)
+	if err := ebiten.RunGame(&duel{Rollback: rollback}); err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+//go:build !headless
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/StCredZero/vectrek/constants"
+	"github.com/StCredZero/vectrek/ecs"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"image/color"
+	"log"
+	"os"
+)
+
+// viewer renders a Playback with the client's Sprite drawing.
+type viewer struct {
+	Playback *ecs.Playback
+}
+
+func (v *viewer) Update() error {
+	var p = v.Playback
+	var err error
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		p.Paused = !p.Paused
+	case inpututil.IsKeyJustPressed(ebiten.KeyPeriod):
+		p.Paused = true
+		err = p.Step()
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowUp):
+		p.Speed *= 2
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowDown):
+		p.Speed /= 2
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft):
+		err = p.Seek(p.Tick() - ebiten.TPS()*5)
+	case inpututil.IsKeyJustPressed(ebiten.KeyArrowRight):
+		err = p.Seek(p.Tick() + ebiten.TPS()*5)
+	case inpututil.IsKeyJustPressed(ebiten.KeyHome):
+		err = p.Seek(0)
+	}
+	for digit := ebiten.Key0; digit <= ebiten.Key9; digit++ {
+		if inpututil.IsKeyJustPressed(digit) {
+			err = p.Seek(p.Len() * int(digit-ebiten.Key0) / 10)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return p.Advance()
+}
+
+func (v *viewer) Draw(screen *ebiten.Image) {
+	var p = v.Playback
+	screen.Fill(color.RGBA{0x00, 0x00, 0x00, 0xff})
+	p.Instance.Draw(screen)
+
+	var state = "playing"
+	if p.Paused {
+		state = "paused"
+	} else if p.Done() {
+		state = "finished"
+	}
+	msg := fmt.Sprintf("Tick: %d/%d (%s, %gx)", p.Tick(), p.Len(), state, p.Speed)
+	msg += fmt.Sprintf("\nChecksum: %016x", p.Instance.Checksum())
+	msg += "\nSpace: pause  Period: step  Up/Down: speed"
+	msg += "\nLeft/Right: seek 5s  Home/0-9: seek"
+	ebitenutil.DebugPrint(screen, msg)
+}
+
+func (v *viewer) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return constants.ScreenWidth, constants.ScreenHeight
+}
+
+// addSprites gives every moving entity a Sprite so the replayed server
+// world can be drawn.
+func addSprites(instance *ecs.Instance) error {
+	for entity := range instance.Entities {
+		if _, ok := instance.Motion.GetComponent(entity); !ok {
+			continue
+		}
+		if _, ok := instance.Sprite.GetComponent(entity); ok {
+			continue
+		}
+		if err := new(ecs.Sprite).Init(instance, entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	var headless = flag.Bool("headless", false, "simulate the whole replay without a window")
+	var checksums = flag.Bool("checksums", false, "print the world checksum after every tick (headless)")
+	var speed = flag.Float64("speed", 1, "playback speed in ticks per frame")
+	var seek = flag.Int("seek", 0, "start playback at this tick")
+	var dump = flag.Bool("dump", false, "print the world at the -seek tick as JSON and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] replay-file\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
+	replay, err := ecs.ReadReplay(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
+
+	var playback = ecs.NewPlayback(replay)
+	playback.Speed = *speed
+
+	if *headless || *dump {
+		if err = playback.Seek(*seek); err != nil {
+			log.Fatalf("fatal error: %v", err)
+		}
+		if *dump {
+			world, err := playback.Instance.SaveWorld()
+			if err == nil {
+				err = ecs.WriteWorldJSON(os.Stdout, world)
+			}
+			if err != nil {
+				log.Fatalf("fatal error: %v", err)
+			}
+			return
+		}
+		for !playback.Done() {
+			if err = playback.Step(); err != nil {
+				log.Printf("tick %d: %v", playback.Tick(), err)
+			}
+			if *checksums {
+				fmt.Printf("%d %016x\n", playback.Instance.Counter, playback.Instance.LastChecksum)
+			}
+		}
+		fmt.Printf("ticks: %d\nchecksum: %016x\n", playback.Len(), playback.Instance.Checksum())
+		return
+	}
+
+	playback.Restored = addSprites
+	if err = playback.Seek(*seek); err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
+	ebiten.SetWindowSize(constants.ScreenWidth, constants.ScreenHeight)
+	ebiten.SetWindowTitle("Vector Replay")
+	ebiten.SetTPS(playback.Instance.Parameters.TickRate)
+	if err = ebiten.RunGame(&viewer{Playback: playback}); err != nil {
+		log.Fatalf("fatal error: %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+	"math"
+)
+
+// Steering is what an AI is trying to do.
+type Steering uint8
+
+const (
+	// SteerIdle leaves the controls alone.
+	SteerIdle Steering = iota
+	// SteerSeek heads for the target at full speed.
+	SteerSeek
+	// SteerFlee heads straight away from the target.
+	SteerFlee
+	// SteerArrive heads for the target, slowing to stop within Radius.
+	SteerArrive
+	// SteerPursue heads for where the target will be when the ship gets
+	// there.
+	SteerPursue
+	// SteerOrbit circles the target at Radius.
+	SteerOrbit
+)
+
+const (
+	// aimTolerance is how far off the target a shot may be aimed.
+	aimTolerance = math.Pi / 18
+	// thrustTolerance is how far off the desired heading a ship thrusts.
+	thrustTolerance = math.Pi / 6
+	// avoidDistance is how far ahead an AI looks for obstacles, by default.
+	avoidDistance = 150.0
+)
+
+// AI flies a ship by setting its Helm's input every tick, exactly as a
+// player's keyboard would, so AI ships obey the same physics. The target is
+// the entity Target if HasTarget, and the point Point otherwise. Radius is
+// the orbit radius or, when arriving, where to start slowing down. Match is
+// the velocity to arrive at, for keeping station on something moving.
+//
+// Whatever the Steering, the ship swerves round obstacles within
+// AvoidDistance ahead of it. With WeaponsFree it fires at a target entity
+// that any of its hardpoints can reach, leading torpedo shots.
+type AI struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+	Motion   *Motion   `json:"-"`
+
+	Steering      Steering
+	Target        ecstypes.EntityID
+	HasTarget     bool
+	Point         geom.Vector
+	Radius        float64
+	Match         geom.Vector
+	AvoidDistance float64
+	WeaponsFree   bool
+}
+
+func (comp AI) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Motion, err = GetComponent[Motion](sm, entity); comp.Motion == nil {
+		return fmt.Errorf("no Motion found: %w", vterr.ErrMissing)
+	}
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if comp.AvoidDistance == 0 {
+		comp.AvoidDistance = avoidDistance
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding ai: %w", err)
+	}
+	return nil
+}
+func (comp AI) Update(sm ecstypes.SystemManager) (AI, error) {
+	helm, err := GetComponent[Helm](sm, comp.Entity)
+	if err != nil || helm == nil {
+		return comp, err
+	}
+	var targetPosition, targetVelocity = comp.Point, geom.Vector{}
+	if comp.HasTarget {
+		position, err := GetComponent[Position](sm, comp.Target)
+		if err != nil {
+			return comp, err
+		}
+		if position == nil {
+			// the target is gone
+			comp.HasTarget = false
+			comp.Steering = SteerIdle
+			helm.Input = HelmInput{}
+			return comp, nil
+		}
+		targetPosition = position.Vector
+		if motion, _ := GetComponent[Motion](sm, comp.Target); motion != nil {
+			targetVelocity = motion.Velocity
+		}
+	}
+
+	var world = sm.GetWorld()
+	var desired geom.Vector
+	switch comp.Steering {
+	case SteerIdle:
+		helm.Input = HelmInput{}
+		return comp, nil
+	case SteerSeek:
+		desired = comp.seek(world, targetPosition, helm.MaxVelocity)
+	case SteerFlee:
+		desired = comp.seek(world, targetPosition, helm.MaxVelocity).Negate()
+	case SteerArrive:
+		desired = comp.arrive(world, targetPosition, helm)
+	case SteerPursue:
+		desired = comp.seek(world, comp.lead(world, targetPosition, targetVelocity, helm.MaxVelocity), helm.MaxVelocity)
+	case SteerOrbit:
+		desired = comp.orbit(world, targetPosition, helm.MaxVelocity)
+	}
+	desired = desired.Add(comp.avoid(sm, desired, helm.MaxVelocity))
+
+	var input = comp.steer(desired, helm, sm.GetTimeStep())
+	if comp.WeaponsFree && comp.HasTarget {
+		if err = comp.fire(sm, &input, targetPosition, targetVelocity); err != nil {
+			return comp, err
+		}
+	}
+	helm.Input = input
+	return comp, nil
+}
+func (comp AI) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemAI
+}
+
+// Order replaces what the AI is doing with orders, keeping how it's set up.
+func (comp *AI) Order(orders AI) {
+	orders.Entity, orders.Position, orders.Motion = comp.Entity, comp.Position, comp.Motion
+	orders.AvoidDistance = comp.AvoidDistance
+	*comp = orders
+}
+
+// seek returns the velocity straight at target at full speed.
+func (comp *AI) seek(world geom.Torus, target geom.Vector, maxSpeed float64) geom.Vector {
+	return world.Delta(comp.Position.Vector, target).Normalize().Multiply(maxSpeed)
+}
+
+// arrive returns the velocity towards target, slowing in proportion to the
+// distance left once within stopping distance: the time to turn round plus
+// the time to brake from full speed, or Radius if that is further. It's
+// relative to Match.
+func (comp *AI) arrive(world geom.Torus, target geom.Vector, helm *Helm) geom.Vector {
+	var delta = world.Delta(comp.Position.Vector, target)
+	var stopping = helm.MaxVelocity * (math.Pi/helm.TurnRate + helm.MaxVelocity/(2*helm.Thrust))
+	var speed = helm.MaxVelocity * min(1, delta.Length()/max(comp.Radius, stopping))
+	return comp.Match.Add(delta.Normalize().Multiply(speed)).ClampLength(helm.MaxVelocity)
+}
+
+// lead returns where a target now at target moving at velocity will be when
+// something leaving here at speed could reach it.
+func (comp *AI) lead(world geom.Torus, target, velocity geom.Vector, speed float64) geom.Vector {
+	var relative = world.Delta(comp.Position.Vector, target)
+	// solve |relative + velocity t| = speed t for the earliest t > 0
+	var a = velocity.LengthSquared() - speed*speed
+	var b = 2 * relative.Dot(velocity)
+	var c = relative.LengthSquared()
+	var t = 0.0
+	if math.Abs(a) < 1e-9 {
+		if b < 0 {
+			t = -c / b
+		}
+	} else if discriminant := b*b - 4*a*c; discriminant >= 0 {
+		var root = math.Sqrt(discriminant)
+		for _, candidate := range []float64{(-b - root) / (2 * a), (-b + root) / (2 * a)} {
+			if candidate > 0 && (t == 0 || candidate < t) {
+				t = candidate
+			}
+		}
+	}
+	return world.Wrap(target.Add(velocity.Multiply(t)))
+}
+
+// orbit returns a velocity along the circle of Radius round center,
+// clockwise on screen, turning in or out to get onto it.
+func (comp *AI) orbit(world geom.Torus, center geom.Vector, maxSpeed float64) geom.Vector {
+	var outward = world.Delta(center, comp.Position.Vector)
+	var distance = outward.Length()
+	if distance == 0 {
+		return geom.Vector{X: maxSpeed}
+	}
+	var radial = outward.Multiply(1 / distance)
+	var tangent = radial.Perp()
+	var radius = max(comp.Radius, 1)
+	var correction = max(-1, min(1, (radius-distance)/radius))
+	return tangent.Add(radial.Multiply(correction)).Normalize().Multiply(maxSpeed)
+}
+
+// avoid returns a push away from the nearest obstacle or ship the desired
+// course runs into within AvoidDistance, stronger the nearer it is.
+func (comp *AI) avoid(sm ecstypes.SystemManager, desired geom.Vector, maxSpeed float64) geom.Vector {
+	if desired.LengthSquared() == 0 || comp.AvoidDistance <= 0 {
+		return geom.Vector{}
+	}
+	var ignore = []ecstypes.EntityID{comp.Entity}
+	if comp.HasTarget {
+		ignore = append(ignore, comp.Target)
+	}
+	var hits = sm.Raycast(ecstypes.Ray{
+		Origin:    comp.Position.Vector,
+		Direction: desired.Normalize(),
+		Length:    comp.AvoidDistance,
+		Mask:      LayerObstacle | LayerShip,
+		Ignore:    ignore,
+	})
+	if len(hits) == 0 {
+		return geom.Vector{}
+	}
+	var hit = hits[0]
+	var urgency = 1 - hit.Distance/comp.AvoidDistance
+	// push off the surface and to the side of it, so a head-on approach
+	// doesn't just stop short
+	var side = hit.Normal.Perp()
+	if side.Dot(desired) < 0 {
+		side = side.Negate()
+	}
+	return hit.Normal.Add(side).Normalize().Multiply(2 * maxSpeed * urgency)
+}
+
+// steer returns the helm input that turns towards desired and thrusts when
+// roughly facing it. Turns smaller than one tick's worth are left alone, as
+// are changes in velocity smaller than one tick's thrust, so the ship doesn't
+// wobble either side of its heading or its speed.
+func (comp *AI) steer(desired geom.Vector, helm *Helm, dt float64) HelmInput {
+	var input HelmInput
+	var change = desired.Sub(comp.Motion.Velocity)
+	if change.Length() < helm.Thrust*dt {
+		return input
+	}
+	var heading = change.Angle()
+	var turn = comp.Position.Angle.Diff(heading)
+	input.Left = float64(turn) < -helm.TurnRate*dt
+	input.Right = float64(turn) > helm.TurnRate*dt
+	input.Thrust = math.Abs(float64(turn)) < thrustTolerance
+	return input
+}
+
+// fire fires every kind of weapon that can reach the target from here and
+// is pointing close enough at it, leading torpedoes.
+func (comp *AI) fire(sm ecstypes.SystemManager, input *HelmInput, target, velocity geom.Vector) error {
+	weapons, err := GetComponent[Weapons](sm, comp.Entity)
+	if err != nil || weapons == nil {
+		return err
+	}
+	var world = sm.GetWorld()
+	if !sm.LineOfSight(comp.Entity, comp.Target, LayerObstacle) {
+		return nil
+	}
+	for _, hardpoint := range weapons.Hardpoints {
+		var aim, reach = target, hardpoint.Range
+		if hardpoint.Kind == WeaponTorpedo {
+			aim = comp.lead(world, target, velocity.Sub(comp.Motion.Velocity), hardpoint.Speed)
+			reach = hardpoint.Speed * hardpoint.Lifetime
+		}
+		var delta = world.Delta(comp.Position.Vector, aim)
+		var facing = comp.Position.Angle + hardpoint.Angle
+		if delta.Length() > reach || math.Abs(float64(facing.Diff(delta.Angle()))) > aimTolerance {
+			continue
+		}
+		switch hardpoint.Kind {
+		case WeaponPhaser:
+			input.FirePhaser = true
+		case WeaponTorpedo:
+			input.FireTorpedo = true
+		}
+	}
+	return nil
+}
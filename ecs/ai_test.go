@@ -0,0 +1,150 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"testing"
+)
+
+// newPilot returns an Instance with an AI ship, entity 0, at rest at
+// (500, 1000) facing along +x.
+func newPilot(t *testing.T, ai *AI, extra ...ecstypes.Component) *Instance {
+	t.Helper()
+	var instance = NewInstance(Parameters{ScreenWidth: 2000, ScreenHeight: 2000})
+	var components = append([]ecstypes.Component{&Position{Vector: geom.Vector{X: 500, Y: 1000}}, new(Motion), new(Helm), ai}, extra...)
+	if err := instance.AddEntity(0, components...); err != nil {
+		t.Fatal(err)
+	}
+	return instance
+}
+
+// fly steps the Instance for seconds, calling each after every tick.
+func fly(t *testing.T, instance *Instance, seconds float64, each func()) {
+	t.Helper()
+	for tick := 0; tick < int(seconds*DefaultTickRate); tick++ {
+		if err := instance.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		if each != nil {
+			each()
+		}
+	}
+}
+
+func TestLead(t *testing.T) {
+	var world = geom.Torus{Width: 2000, Height: 2000}
+	var tests = []struct {
+		name     string
+		target   geom.Vector
+		velocity geom.Vector
+		speed    float64
+	}{
+		{"stationary", geom.Vector{X: 300, Y: 100}, geom.Vector{}, 200},
+		{"crossing", geom.Vector{X: 300, Y: 100}, geom.Vector{Y: 100}, 200},
+		{"closing", geom.Vector{X: 300, Y: 100}, geom.Vector{X: -150}, 200},
+		{"across the edge", geom.Vector{X: 1900, Y: 100}, geom.Vector{Y: -50}, 200},
+		{"too fast to catch", geom.Vector{X: 300, Y: 100}, geom.Vector{X: 400}, 200},
+	}
+	for _, test := range tests {
+		var ai = AI{Position: &Position{Vector: geom.Vector{X: 100, Y: 100}}}
+		var aim = ai.lead(world, test.target, test.velocity, test.speed)
+		// the target gets to aim at the same time as a shot at speed
+		var travelled = world.Distance(test.target, aim)
+		var flight = world.Distance(ai.Position.Vector, aim) / test.speed
+		if test.name == "too fast to catch" {
+			if aim != test.target {
+				t.Errorf("%s: aimed at %v, want the target itself", test.name, aim)
+			}
+			continue
+		}
+		if want := test.velocity.Length() * flight; !near(travelled, want) {
+			t.Errorf("%s: aimed at %v, where the target is after %vs, not %vs", test.name, aim, travelled/max(test.velocity.Length(), 1e-9), flight)
+		}
+	}
+}
+
+func TestSteering(t *testing.T) {
+	var point = geom.Vector{X: 900, Y: 1000}
+	var tests = []struct {
+		name    string
+		ai      AI
+		seconds float64
+		ok      func(distance, speed float64) bool
+	}{
+		{"seek closes", AI{Steering: SteerSeek, Point: point}, 1.5, func(distance, _ float64) bool { return distance < 200 }},
+		{"flee opens", AI{Steering: SteerFlee, Point: point}, 3, func(distance, _ float64) bool { return distance > 800 }},
+		{"arrive stops", AI{Steering: SteerArrive, Point: point}, 8, func(distance, speed float64) bool { return distance < 20 && speed < 30 }},
+		{"orbit circles", AI{Steering: SteerOrbit, Point: point, Radius: 200}, 10, func(distance, _ float64) bool { return math.Abs(distance-200) < 50 }},
+		{"idle drifts", AI{Steering: SteerIdle, Point: point}, 1, func(distance, speed float64) bool { return distance == 400 && speed == 0 }},
+	}
+	for _, test := range tests {
+		var ai = test.ai
+		var instance = newPilot(t, &ai)
+		fly(t, instance, test.seconds, nil)
+		var position, _ = instance.Position.GetComponent(0)
+		var motion, _ = instance.Motion.GetComponent(0)
+		var distance = instance.GetWorld().Distance(position.Vector, point)
+		if !test.ok(distance, motion.Velocity.Length()) {
+			t.Errorf("%s: %v from the point at speed %v", test.name, distance, motion.Velocity.Length())
+		}
+	}
+}
+
+func TestPursueTargetGone(t *testing.T) {
+	var instance = newPilot(t, &AI{Steering: SteerPursue, Target: 1, HasTarget: true})
+	err := instance.AddEntity(1, &Position{Vector: geom.Vector{X: 900, Y: 900}}, &Motion{Velocity: geom.Vector{Y: 50}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var start = instance.GetWorld().Distance(geom.Vector{X: 500, Y: 1000}, geom.Vector{X: 900, Y: 900})
+	fly(t, instance, 1.5, nil)
+	var position, _ = instance.Position.GetComponent(0)
+	var target, _ = instance.Position.GetComponent(1)
+	if distance := instance.GetWorld().Distance(position.Vector, target.Vector); distance >= start/2 {
+		t.Errorf("pursuer %v from the target after 1.5s, started %v away", distance, start)
+	}
+
+	instance.RemoveEntity(1)
+	if err := instance.Step(nil); err != nil {
+		t.Fatal(err)
+	}
+	var ai, _ = instance.AI.GetComponent(0)
+	var helm, _ = instance.Helm.GetComponent(0)
+	if ai.Steering != SteerIdle || ai.HasTarget || helm.Input != (HelmInput{}) {
+		t.Errorf("ai %+v with input %+v, want idle once the target is gone", ai, helm.Input)
+	}
+}
+
+func TestAvoidObstacle(t *testing.T) {
+	var rock = geom.Vector{X: 900, Y: 1010}
+	var goal = geom.Vector{X: 1300, Y: 1000}
+	var instance = newPilot(t, &AI{Steering: SteerArrive, Point: goal, AvoidDistance: 300})
+	addCollider(t, instance, 1, rock, &Collider{Shape: geom.Circle(40), Layer: LayerObstacle})
+	var closest = math.Inf(1)
+	fly(t, instance, 10, func() {
+		var position, _ = instance.Position.GetComponent(0)
+		closest = min(closest, instance.GetWorld().Distance(position.Vector, rock))
+	})
+	if closest <= 40 {
+		t.Errorf("came within %v of the obstacle's center, inside it", closest)
+	}
+	var position, _ = instance.Position.GetComponent(0)
+	if distance := instance.GetWorld().Distance(position.Vector, goal); distance > 50 {
+		t.Errorf("%v from the point after going round the obstacle", distance)
+	}
+}
+
+func TestWeaponsFree(t *testing.T) {
+	for _, weaponsFree := range []bool{false, true} {
+		var weapons = &Weapons{Hardpoints: []Hardpoint{PhaserBank}, Energy: 100, MaxEnergy: 100}
+		var instance = newPilot(t, &AI{Steering: SteerSeek, Target: 1, HasTarget: true, WeaponsFree: weaponsFree}, weapons)
+		addCollider(t, instance, 1, geom.Vector{X: 650, Y: 1000}, &Collider{Shape: geom.Circle(10)})
+		if err := instance.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		if fired := len(instance.Damage) > 0; fired != weaponsFree {
+			t.Errorf("WeaponsFree %v: damage %+v", weaponsFree, instance.Damage)
+		}
+	}
+}
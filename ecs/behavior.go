@@ -0,0 +1,436 @@
+package ecs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"slices"
+	"strings"
+)
+
+var ErrBehavior = errors.New("invalid behavior tree")
+
+// BehaviorStatus is what a behavior tree node reports when it's ticked.
+type BehaviorStatus uint8
+
+const (
+	BehaviorFailure BehaviorStatus = iota
+	BehaviorSuccess
+	BehaviorRunning
+)
+
+func (status BehaviorStatus) String() string {
+	switch status {
+	case BehaviorFailure:
+		return "failure"
+	case BehaviorSuccess:
+		return "success"
+	case BehaviorRunning:
+		return "running"
+	}
+	return fmt.Sprintf("BehaviorStatus(%d)", uint8(status))
+}
+
+// BehaviorNode is one node of a behavior tree, as written in its JSON file.
+// Node names the kind of node:
+//
+//   - sequence ticks its Children in order until one doesn't succeed.
+//   - selector ticks its Children in order until one doesn't fail.
+//   - invert swaps its child's success and failure.
+//   - succeed succeeds whenever its child isn't running.
+//   - cooldown fails for Seconds after its child succeeds.
+//   - shields-low succeeds if the weakest shield is under Threshold of full.
+//   - enemy-near succeeds if a ship of another team is within Range, as far
+//     as the ship's sensors see, and makes the nearest the target.
+//   - scattered succeeds if the ship is further than Radius from the middle
+//     of its teammates within Range.
+//   - patrol flies round Home, Radius out. It never finishes.
+//   - engage pursues the target with weapons free, circling it at Radius
+//     once close. It fails if there's no target.
+//   - retreat flees the target until it's Range away, with weapons held.
+//   - regroup flies to the middle of the teammates within Range, and
+//     succeeds once within Radius of it.
+type BehaviorNode struct {
+	Node      string
+	Children  []BehaviorNode `json:",omitempty"`
+	Range     float64        `json:",omitempty"`
+	Radius    float64        `json:",omitempty"`
+	Threshold float64        `json:",omitempty"`
+	Seconds   float64        `json:",omitempty"`
+
+	// index numbers the nodes of a tree depth first, for their state on the
+	// Blackboard.
+	index int
+}
+
+// BehaviorTree is a named tree of BehaviorNodes, loaded by
+// LoadBehaviorTrees.
+type BehaviorTree struct {
+	Name string
+	Root BehaviorNode
+
+	nodes int
+}
+
+// childCount is how many children each kind of node takes, or -1 for at
+// least one.
+var childCount = map[string]int{
+	"sequence":    -1,
+	"selector":    -1,
+	"invert":      1,
+	"succeed":     1,
+	"cooldown":    1,
+	"shields-low": 0,
+	"enemy-near":  0,
+	"scattered":   0,
+	"patrol":      0,
+	"engage":      0,
+	"retreat":     0,
+	"regroup":     0,
+}
+
+// LoadBehaviorTrees reads every .json file at the top of fsys as a
+// BehaviorTree. A tree without a Name is named after its file.
+func LoadBehaviorTrees(fsys fs.FS) (map[string]BehaviorTree, error) {
+	names, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("listing behavior trees: %w", err)
+	}
+	var trees = make(map[string]BehaviorTree, len(names))
+	for _, name := range names {
+		raw, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		var tree BehaviorTree
+		if err = json.Unmarshal(raw, &tree); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", name, err)
+		}
+		if tree.Name == "" {
+			tree.Name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		if err = tree.number(&tree.Root); err != nil {
+			return nil, fmt.Errorf("%s: tree %q: %w", name, tree.Name, err)
+		}
+		if _, ok := trees[tree.Name]; ok {
+			return nil, fmt.Errorf("%s: tree %q defined twice: %w", name, tree.Name, ErrBehavior)
+		}
+		trees[tree.Name] = tree
+	}
+	return trees, nil
+}
+
+// number checks node and its descendants and numbers them depth first.
+func (tree *BehaviorTree) number(node *BehaviorNode) error {
+	count, ok := childCount[node.Node]
+	if !ok {
+		return fmt.Errorf("unknown node %q: %w", node.Node, ErrBehavior)
+	}
+	if (count < 0 && len(node.Children) == 0) || (count >= 0 && len(node.Children) != count) {
+		return fmt.Errorf("%s node with %d children: %w", node.Node, len(node.Children), ErrBehavior)
+	}
+	node.index = tree.nodes
+	tree.nodes++
+	for index := range node.Children {
+		if err := tree.number(&node.Children[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Blackboard is what a Brain remembers between ticks: its target, the point
+// it patrols round and the next waypoint, and the seconds left on each
+// cooldown node, by node index.
+type Blackboard struct {
+	Target    ecstypes.EntityID
+	HasTarget bool
+	Home      geom.Vector
+	Waypoint  int
+	Cooldowns []float64
+}
+
+// Brain decides what a ship's AI does by ticking the behavior tree named
+// Tree from its root every tick. Ships on other Teams are enemies; ships
+// without a Brain, such as players', are on team 0, and wingmen are on their
+// leader's. Blackboard.Home is where the ship was added, unless set.
+//
+// Active is the path to the last node ticked and Status what the root
+// reported, for debugging.
+type Brain struct {
+	Entity ecstypes.EntityID
+	AI     *AI `json:"-"`
+
+	Tree       string
+	Team       uint32
+	Blackboard Blackboard
+	Active     string
+	Status     BehaviorStatus
+}
+
+func (comp Brain) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.AI, err = GetComponent[AI](sm, entity); comp.AI == nil {
+		return fmt.Errorf("no AI found: %w", vterr.ErrMissing)
+	}
+	if comp.Tree == "" {
+		return fmt.Errorf("brain has no tree: %w", ErrType)
+	}
+	if comp.Blackboard.Home == (geom.Vector{}) {
+		comp.Blackboard.Home = comp.AI.Position.Vector
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding brain: %w", err)
+	}
+	return nil
+}
+func (comp Brain) Update(_ ecstypes.SystemManager) (Brain, error) {
+	return comp, nil
+}
+func (comp Brain) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemBrain
+}
+
+// Clone copies the brain with its own Cooldowns, which ticking its tree
+// changes in place.
+func (comp Brain) Clone() Brain {
+	comp.Blackboard.Cooldowns = slices.Clone(comp.Blackboard.Cooldowns)
+	return comp
+}
+
+// runBehaviors ticks every Brain's tree, which sets up its AI for this tick.
+// A tree that has finished, or failed, leaves its AI idle.
+func (i *Instance) runBehaviors() error {
+	var errs []error
+	var dt = i.GetTimeStep()
+	i.Brain.EachSorted(func(e ecstypes.EntityID, brain *Brain) {
+		tree, ok := i.Behaviors[brain.Tree]
+		if !ok {
+			errs = append(errs, fmt.Errorf("entity %d: no behavior tree %q: %w", e, brain.Tree, ErrBehavior))
+			return
+		}
+		var board = &brain.Blackboard
+		if len(board.Cooldowns) != tree.nodes {
+			board.Cooldowns = make([]float64, tree.nodes)
+		} else {
+			for index := range board.Cooldowns {
+				board.Cooldowns[index] = max(0, board.Cooldowns[index]-dt)
+			}
+		}
+		var ctx = behaviorContext{Instance: i, brain: brain}
+		if brain.Status = ctx.tick(&tree.Root); brain.Status != BehaviorRunning {
+			// nothing to do: stop steering
+			ctx.brain.AI.Order(AI{})
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// behaviorContext is one Brain's tick of its tree.
+type behaviorContext struct {
+	*Instance
+	brain *Brain
+	path  []string
+}
+
+func (ctx *behaviorContext) tick(node *BehaviorNode) BehaviorStatus {
+	ctx.path = append(ctx.path, node.Node)
+	defer func() { ctx.path = ctx.path[:len(ctx.path)-1] }()
+	if len(node.Children) == 0 {
+		ctx.brain.Active = strings.Join(ctx.path, " > ")
+	}
+	var board = &ctx.brain.Blackboard
+	var ai = ctx.brain.AI
+	switch node.Node {
+	case "sequence":
+		for index := range node.Children {
+			if status := ctx.tick(&node.Children[index]); status != BehaviorSuccess {
+				return status
+			}
+		}
+		return BehaviorSuccess
+	case "selector":
+		for index := range node.Children {
+			if status := ctx.tick(&node.Children[index]); status != BehaviorFailure {
+				return status
+			}
+		}
+		return BehaviorFailure
+	case "invert":
+		switch status := ctx.tick(&node.Children[0]); status {
+		case BehaviorSuccess:
+			return BehaviorFailure
+		case BehaviorFailure:
+			return BehaviorSuccess
+		default:
+			return status
+		}
+	case "succeed":
+		if ctx.tick(&node.Children[0]) == BehaviorRunning {
+			return BehaviorRunning
+		}
+		return BehaviorSuccess
+	case "cooldown":
+		if board.Cooldowns[node.index] > 0 {
+			return BehaviorFailure
+		}
+		var status = ctx.tick(&node.Children[0])
+		if status == BehaviorSuccess {
+			board.Cooldowns[node.index] = node.Seconds
+		}
+		return status
+	case "shields-low":
+		shields, ok := ctx.Shields.GetComponent(ctx.brain.Entity)
+		if !ok || shields.Max <= 0 {
+			return BehaviorFailure
+		}
+		return behaviorResult(slices.Min(shields.Strength[:]) < node.Threshold*shields.Max)
+	case "enemy-near":
+		enemy, ok := ctx.nearestEnemy(node.Range)
+		if !ok {
+			return BehaviorFailure
+		}
+		board.Target, board.HasTarget = enemy, true
+		return BehaviorSuccess
+	case "scattered":
+		middle, ok := ctx.teamMiddle(node.Range)
+		if !ok {
+			return BehaviorFailure
+		}
+		return behaviorResult(ctx.World.Delta(ai.Position.Vector, middle).Length() > node.Radius)
+	case "patrol":
+		var radius = max(node.Radius, 1)
+		var waypoint = ctx.World.Wrap(board.Home.Add(geom.Vector{X: radius}.Rotate(geom.Angle(board.Waypoint) * math.Pi / 2)))
+		if ctx.World.Delta(ai.Position.Vector, waypoint).Length() < radius/2 {
+			board.Waypoint = (board.Waypoint + 1) % 4
+		}
+		ctx.brain.AI.Order(AI{Steering: SteerSeek, Point: waypoint})
+		return BehaviorRunning
+	case "engage":
+		target, ok := ctx.target()
+		if !ok {
+			return BehaviorFailure
+		}
+		var steering = SteerPursue
+		if node.Radius > 0 && ctx.World.Delta(ai.Position.Vector, target).Length() < 2*node.Radius {
+			steering = SteerOrbit
+		}
+		ctx.brain.AI.Order(AI{Steering: steering, Target: board.Target, HasTarget: true, Radius: node.Radius, WeaponsFree: true})
+		return BehaviorRunning
+	case "retreat":
+		target, ok := ctx.target()
+		if !ok {
+			return BehaviorFailure
+		}
+		if ctx.World.Delta(ai.Position.Vector, target).Length() >= node.Range {
+			return BehaviorSuccess
+		}
+		ctx.brain.AI.Order(AI{Steering: SteerFlee, Target: board.Target, HasTarget: true})
+		return BehaviorRunning
+	case "regroup":
+		middle, ok := ctx.teamMiddle(node.Range)
+		if !ok {
+			return BehaviorFailure
+		}
+		if ctx.World.Delta(ai.Position.Vector, middle).Length() <= node.Radius {
+			return BehaviorSuccess
+		}
+		ctx.brain.AI.Order(AI{Steering: SteerArrive, Point: middle, Radius: node.Radius})
+		return BehaviorRunning
+	}
+	return BehaviorFailure
+}
+
+func behaviorResult(ok bool) BehaviorStatus {
+	if ok {
+		return BehaviorSuccess
+	}
+	return BehaviorFailure
+}
+
+// target returns where the Blackboard's target is, forgetting it if it's
+// gone.
+func (ctx *behaviorContext) target() (geom.Vector, bool) {
+	var board = &ctx.brain.Blackboard
+	if !board.HasTarget {
+		return geom.Vector{}, false
+	}
+	position, ok := ctx.Position.GetComponent(board.Target)
+	if !ok {
+		board.HasTarget = false
+		return geom.Vector{}, false
+	}
+	return position.Vector, true
+}
+
+// nearestEnemy returns the nearest ship of another team within reach, as
+// the ship's sensors see it.
+func (ctx *behaviorContext) nearestEnemy(reach float64) (ecstypes.EntityID, bool) {
+	reach = ctx.sensorRange(ctx.brain.Entity, reach)
+	var here = ctx.brain.AI.Position.Vector
+	var team = ctx.brain.Team
+	var nearest ecstypes.EntityID
+	var found bool
+	ctx.Helm.EachSorted(func(e ecstypes.EntityID, helm *Helm) {
+		if e == ctx.brain.Entity || ctx.team(e) == team {
+			return
+		}
+		var distance = ctx.World.Delta(here, helm.Position.Vector).Length()
+		if distance <= reach {
+			nearest, found, reach = e, true, distance
+		}
+	})
+	return nearest, found
+}
+
+// teamMiddle returns the middle of the ship's teammates within reach, not
+// counting itself.
+func (ctx *behaviorContext) teamMiddle(reach float64) (geom.Vector, bool) {
+	var here = ctx.brain.AI.Position.Vector
+	var sum geom.Vector
+	var count int
+	ctx.Brain.EachSorted(func(e ecstypes.EntityID, brain *Brain) {
+		if e == ctx.brain.Entity || brain.Team != ctx.brain.Team {
+			return
+		}
+		var delta = ctx.World.Delta(here, brain.AI.Position.Vector)
+		if delta.Length() <= reach {
+			sum = sum.Add(delta)
+			count++
+		}
+	})
+	if count == 0 {
+		return geom.Vector{}, false
+	}
+	return ctx.World.Wrap(here.Add(sum.Multiply(1 / float64(count)))), true
+}
+
+// DumpBehaviors writes a line for each Brain: its entity, tree, the node it
+// last ticked and what its tree reported.
+func (i *Instance) DumpBehaviors(w io.Writer) error {
+	var err error
+	i.Brain.EachSorted(func(e ecstypes.EntityID, brain *Brain) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, "%s entity %d %s: %s (%s)\n", i.Name, e, brain.Tree, brain.Active, brain.Status)
+		}
+	})
+	return err
+}
+
+// DumpBehaviors writes every sector's Brains, sector by sector.
+func (g *Galaxy) DumpBehaviors(w io.Writer) error {
+	for _, coord := range g.sortedSectors() {
+		if err := g.Sectors[coord].DumpBehaviors(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package ecs
+
+import (
+	"bytes"
+	"errors"
+	"github.com/StCredZero/vectrek/assets"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// newBehaviorRange returns an Instance with the stock ship classes and
+// behaviors, and a player's cruiser, on team 0, at (1000, 1000).
+func newBehaviorRange(t *testing.T) (*Instance, ecstypes.EntityID) {
+	t.Helper()
+	var instance = NewInstance(Parameters{ScreenWidth: 4000, ScreenHeight: 4000})
+	var err error
+	if instance.ShipClasses, err = LoadShipClasses(assets.Ships()); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Behaviors, err = LoadBehaviorTrees(assets.Behaviors()); err != nil {
+		t.Fatal(err)
+	}
+	player, err := instance.Spawn("cruiser", Position{Vector: geom.Vector{X: 1000, Y: 1000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instance, player
+}
+
+// think steps the Instance for ticks and returns what DumpBehaviors writes.
+func think(t *testing.T, instance *Instance, ticks int) string {
+	t.Helper()
+	for tick := 0; tick < ticks; tick++ {
+		if err := instance.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var dump bytes.Buffer
+	if err := instance.DumpBehaviors(&dump); err != nil {
+		t.Fatal(err)
+	}
+	return dump.String()
+}
+
+func TestLoadBehaviorTrees(t *testing.T) {
+	trees, err := LoadBehaviorTrees(assets.Behaviors())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"patrol", "sentry"} {
+		if _, ok := trees[name]; !ok {
+			t.Errorf("no stock %s tree", name)
+		}
+	}
+
+	trees, err = LoadBehaviorTrees(fstest.MapFS{
+		"idle.json":   {Data: []byte(`{"Root": {"Node": "patrol"}}`)},
+		"notes.txt":   {Data: []byte(`not a tree`)},
+		"nested.json": {Data: []byte(`{"Name": "guard", "Root": {"Node": "invert", "Children": [{"Node": "enemy-near"}]}}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trees) != 2 || trees["idle"].Name != "idle" || trees["guard"].nodes != 2 {
+		t.Errorf("loaded %+v", trees)
+	}
+}
+
+func TestBehaviorTreeErrors(t *testing.T) {
+	var tests = []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"unknown node", fstest.MapFS{"a.json": {Data: []byte(`{"Root": {"Node": "dance"}}`)}}},
+		{"no children", fstest.MapFS{"a.json": {Data: []byte(`{"Root": {"Node": "sequence"}}`)}}},
+		{"extra child", fstest.MapFS{"a.json": {Data: []byte(`{"Root": {"Node": "patrol", "Children": [{"Node": "engage"}]}}`)}}},
+		{"deep", fstest.MapFS{"a.json": {Data: []byte(`{"Root": {"Node": "selector", "Children": [{"Node": "cooldown"}]}}`)}}},
+		{"twice", fstest.MapFS{
+			"a.json": {Data: []byte(`{"Name": "x", "Root": {"Node": "patrol"}}`)},
+			"b.json": {Data: []byte(`{"Name": "x", "Root": {"Node": "patrol"}}`)},
+		}},
+	}
+	for _, test := range tests {
+		if _, err := LoadBehaviorTrees(test.files); !errors.Is(err, ErrBehavior) {
+			t.Errorf("%s: got %v, want ErrBehavior", test.name, err)
+		}
+	}
+	if _, err := LoadBehaviorTrees(fstest.MapFS{"a.json": {Data: []byte(`{`)}}); err == nil {
+		t.Error("loaded a tree that isn't JSON")
+	}
+}
+
+func TestPatrolBehavior(t *testing.T) {
+	var instance, player = newBehaviorRange(t)
+	var home = geom.Vector{X: 3000, Y: 3000}
+	npc, err := instance.Spawn("cruiser", Position{Vector: home}, &AI{}, &Brain{Tree: "patrol", Team: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dump := think(t, instance, 10); !strings.Contains(dump, "patrol: selector > patrol (running)") {
+		t.Errorf("alone, dumped %q", dump)
+	}
+
+	var enemy, _ = instance.Position.GetComponent(player)
+	enemy.Vector = home.Add(geom.Vector{X: 300})
+	if dump := think(t, instance, 10); !strings.Contains(dump, "selector > sequence > engage (running)") {
+		t.Errorf("with an enemy near, dumped %q", dump)
+	}
+	if brain, _ := instance.Brain.GetComponent(npc); brain.Blackboard.Target != player || !brain.AI.WeaponsFree {
+		t.Errorf("engaging with %+v", brain.Blackboard)
+	}
+
+	var shields, _ = instance.Shields.GetComponent(npc)
+	shields.Strength = [4]float64{}
+	if dump := think(t, instance, 1); !strings.Contains(dump, "selector > sequence > retreat (running)") {
+		t.Errorf("with shields down, dumped %q", dump)
+	}
+	if brain, _ := instance.Brain.GetComponent(npc); brain.AI.WeaponsFree || brain.AI.Steering != SteerFlee {
+		t.Errorf("retreating with %+v", *brain.AI)
+	}
+}
+
+func TestRegroupBehavior(t *testing.T) {
+	var instance, _ = newBehaviorRange(t)
+	var first, second = geom.Vector{X: 3000, Y: 3000}, geom.Vector{X: 3500, Y: 3000}
+	a, err := instance.Spawn("scout", Position{Vector: first}, &AI{}, &Brain{Tree: "patrol", Team: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = instance.Spawn("scout", Position{Vector: second}, &AI{}, &Brain{Tree: "patrol", Team: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if dump := think(t, instance, 1); strings.Count(dump, "selector > sequence > regroup (running)") != 2 {
+		t.Errorf("500 apart, dumped %q", dump)
+	}
+	if brain, _ := instance.Brain.GetComponent(a); brain.AI.Steering != SteerArrive || brain.AI.Point != second {
+		t.Errorf("regrouping with %+v, want to arrive at its teammate", *brain.AI)
+	}
+	if dump := think(t, instance, 5*DefaultTickRate); strings.Contains(dump, "regroup") {
+		t.Errorf("still regrouping after 5s: %q", dump)
+	}
+}
+
+func TestCooldownBehavior(t *testing.T) {
+	var instance, _ = newBehaviorRange(t)
+	var err error
+	instance.Behaviors, err = LoadBehaviorTrees(fstest.MapFS{"wait.json": {Data: []byte(`{"Root": {"Node": "selector", "Children": [
+		{"Node": "cooldown", "Seconds": 0.5, "Children": [{"Node": "invert", "Children": [{"Node": "scattered", "Range": 10}]}]},
+		{"Node": "patrol"}
+	]}}`)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	npc, err := instance.Spawn("scout", Position{Vector: geom.Vector{X: 3000, Y: 3000}}, &AI{}, &Brain{Tree: "wait", Team: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with no teammates, scattered fails, so its inversion succeeds and
+	// starts the cooldown
+	var statuses []BehaviorStatus
+	for tick := 0; tick < DefaultTickRate; tick++ {
+		think(t, instance, 1)
+		var brain, _ = instance.Brain.GetComponent(npc)
+		statuses = append(statuses, brain.Status)
+	}
+	var successes int
+	for _, status := range statuses {
+		if status == BehaviorSuccess {
+			successes++
+		}
+	}
+	if statuses[0] != BehaviorSuccess || statuses[1] != BehaviorRunning || successes != 2 {
+		t.Errorf("statuses %v, want success once every half second and patrolling between", statuses)
+	}
+}
@@ -0,0 +1,248 @@
+package ecs
+
+import (
+	"encoding/binary"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"hash"
+	"hash/fnv"
+	"math"
+)
+
+type checksum struct {
+	hash.Hash64
+	buf [8]byte
+}
+
+func (c *checksum) uint64(n uint64) {
+	binary.LittleEndian.PutUint64(c.buf[:], n)
+	c.Write(c.buf[:])
+}
+func (c *checksum) float(f float64) {
+	c.uint64(math.Float64bits(f))
+}
+func (c *checksum) bool(b bool) {
+	if b {
+		c.uint64(1)
+	} else {
+		c.uint64(0)
+	}
+}
+func (c *checksum) vector(v geom.Vector) {
+	c.float(v.X)
+	c.float(v.Y)
+}
+func (c *checksum) entity(e ecstypes.EntityID) {
+	c.uint64(uint64(e))
+}
+func (c *checksum) string(text string) {
+	c.uint64(uint64(len(text)))
+	c.Write([]byte(text))
+}
+func (c *checksum) shape(shape geom.Shape) {
+	c.uint64(uint64(shape.Kind))
+	c.uint64(uint64(len(shape.Points)))
+	for _, point := range shape.Points {
+		c.vector(point)
+	}
+	c.float(shape.Radius)
+}
+
+// Checksum hashes the simulated state of the world: the tick counter and the
+// value fields of every component, visited in entity order. Pointers between
+// components and client-only state (sprites, players' keys and the sync
+// components) are left out, so two instances fed the same inputs produce the
+// same checksum every tick.
+func (i *Instance) Checksum() uint64 {
+	var c = checksum{Hash64: fnv.New64a()}
+	c.uint64(i.Counter)
+
+	for _, entity := range i.sortedEntities() {
+		c.entity(entity)
+	}
+
+	i.Position.EachSorted(func(e ecstypes.EntityID, comp *Position) {
+		c.entity(e)
+		c.vector(comp.Vector)
+		c.float(float64(comp.Angle))
+	})
+	i.Motion.EachSorted(func(e ecstypes.EntityID, comp *Motion) {
+		c.entity(e)
+		c.vector(comp.Velocity)
+	})
+	i.Helm.EachSorted(func(e ecstypes.EntityID, comp *Helm) {
+		c.entity(e)
+		c.bool(comp.Input.Left)
+		c.bool(comp.Input.Right)
+		c.bool(comp.Input.Thrust)
+		c.bool(comp.Input.FirePhaser)
+		c.bool(comp.Input.FireTorpedo)
+		c.bool(comp.Input.Warp)
+		c.float(comp.Thrust)
+		c.float(comp.TurnRate)
+		c.float(comp.MaxVelocity)
+	})
+	i.Collider.EachSorted(func(e ecstypes.EntityID, comp *Collider) {
+		c.entity(e)
+		c.shape(comp.Shape)
+		c.uint64(uint64(len(comp.Outline)))
+		for _, point := range comp.Outline {
+			c.vector(point)
+		}
+		c.uint64(uint64(comp.Layer))
+		c.uint64(uint64(comp.Mask))
+	})
+	i.Body.EachSorted(func(e ecstypes.EntityID, comp *Body) {
+		c.entity(e)
+		c.float(comp.Mass)
+		c.float(comp.Inertia)
+		c.float(comp.AngularVelocity)
+		c.float(comp.LinearDamping)
+		c.float(comp.AngularDamping)
+		c.float(comp.Restitution)
+		c.float(comp.MaxSpeed)
+		c.vector(comp.Force)
+		c.float(comp.Torque)
+	})
+	i.GravityWell.EachSorted(func(e ecstypes.EntityID, comp *GravityWell) {
+		c.entity(e)
+		c.float(comp.Mass)
+		c.float(comp.Radius)
+		c.float(comp.Softening)
+	})
+	i.Orbit.EachSorted(func(e ecstypes.EntityID, comp *Orbit) {
+		c.entity(e)
+		c.entity(comp.Primary)
+		c.float(comp.SemiMajorAxis)
+		c.float(comp.Eccentricity)
+		c.float(float64(comp.Periapsis))
+		c.float(float64(comp.MeanAnomaly))
+		c.uint64(comp.Epoch)
+		c.bool(comp.Retrograde)
+	})
+	i.Parent.EachSorted(func(e ecstypes.EntityID, comp *Parent) {
+		c.entity(e)
+		c.entity(comp.Parent)
+		c.vector(comp.Offset)
+		c.float(float64(comp.Angle))
+	})
+	i.Weapons.EachSorted(func(e ecstypes.EntityID, comp *Weapons) {
+		c.entity(e)
+		c.float(comp.Energy)
+		c.float(comp.MaxEnergy)
+		c.float(comp.Recharge)
+		c.uint64(uint64(len(comp.Hardpoints)))
+		for _, hardpoint := range comp.Hardpoints {
+			c.uint64(uint64(hardpoint.Kind))
+			c.vector(hardpoint.Offset)
+			c.float(float64(hardpoint.Angle))
+			c.float(hardpoint.Cooldown)
+			c.float(hardpoint.Energy)
+			c.float(hardpoint.Damage)
+			c.float(hardpoint.Range)
+			c.float(hardpoint.Speed)
+			c.float(hardpoint.Lifetime)
+			c.float(hardpoint.Ready)
+		}
+	})
+	i.Torpedo.EachSorted(func(e ecstypes.EntityID, comp *Torpedo) {
+		c.entity(e)
+		c.entity(comp.Owner)
+		c.float(comp.Damage)
+		c.float(comp.Lifetime)
+	})
+	i.Shields.EachSorted(func(e ecstypes.EntityID, comp *Shields) {
+		c.entity(e)
+		for _, strength := range comp.Strength {
+			c.float(strength)
+		}
+		c.float(comp.Max)
+		c.float(comp.Regen)
+		c.float(comp.Delay)
+		c.float(comp.SinceHit)
+	})
+	i.Hull.EachSorted(func(e ecstypes.EntityID, comp *Hull) {
+		c.entity(e)
+		c.float(comp.Integrity)
+		c.float(comp.MaxIntegrity)
+		c.float(comp.EngineDamage)
+		c.float(comp.HelmDamage)
+		c.bool(comp.Destroyed)
+	})
+	i.Reactor.EachSorted(func(e ecstypes.EntityID, comp *Reactor) {
+		c.entity(e)
+		c.float(comp.Output)
+		c.float(comp.Capacity)
+		for system := range comp.Pools {
+			c.float(comp.Allocation[system])
+			c.float(comp.Pools[system])
+		}
+	})
+	i.Warp.EachSorted(func(e ecstypes.EntityID, comp *Warp) {
+		c.entity(e)
+		c.uint64(uint64(comp.State))
+		c.float(comp.Factor)
+		c.float(comp.MaxFactor)
+		c.float(comp.ChargeTime)
+		c.float(comp.CooldownTime)
+		c.float(comp.TurnRate)
+		c.float(comp.Remaining)
+		c.bool(comp.Held)
+	})
+	i.AI.EachSorted(func(e ecstypes.EntityID, comp *AI) {
+		c.entity(e)
+		c.uint64(uint64(comp.Steering))
+		c.entity(comp.Target)
+		c.bool(comp.HasTarget)
+		c.vector(comp.Point)
+		c.float(comp.Radius)
+		c.vector(comp.Match)
+		c.float(comp.AvoidDistance)
+		c.bool(comp.WeaponsFree)
+	})
+	i.Brain.EachSorted(func(e ecstypes.EntityID, comp *Brain) {
+		c.entity(e)
+		c.string(comp.Tree)
+		c.uint64(uint64(comp.Team))
+		c.entity(comp.Blackboard.Target)
+		c.bool(comp.Blackboard.HasTarget)
+		c.vector(comp.Blackboard.Home)
+		c.uint64(uint64(comp.Blackboard.Waypoint))
+		c.uint64(uint64(len(comp.Blackboard.Cooldowns)))
+		for _, left := range comp.Blackboard.Cooldowns {
+			c.float(left)
+		}
+		c.string(comp.Active)
+		c.uint64(uint64(comp.Status))
+	})
+	i.Fleet.EachSorted(func(e ecstypes.EntityID, comp *Fleet) {
+		c.entity(e)
+		c.float(comp.Spacing)
+		c.vector(comp.Base)
+		c.uint64(uint64(comp.Order))
+		c.uint64(uint64(comp.Formation))
+		c.entity(comp.Target)
+		c.vector(comp.Point)
+		c.float(float64(comp.Angle))
+	})
+	i.Wingman.EachSorted(func(e ecstypes.EntityID, comp *Wingman) {
+		c.entity(e)
+		c.entity(comp.Leader)
+	})
+	i.Replicated.EachSorted(func(e ecstypes.EntityID, comp *Replicated) {
+		c.entity(e)
+		c.string(comp.Class)
+	})
+	return c.Sum64()
+}
+
+// quantize rounds positions and velocities to geom.Fixed precision.
+func (i *Instance) quantize() {
+	i.Position.EachSorted(func(_ ecstypes.EntityID, comp *Position) {
+		comp.Vector = comp.Vector.ToFixed().ToVector()
+		comp.Angle = geom.Angle(geom.FixedFromFloat(float64(comp.Angle)).Float())
+	})
+	i.Motion.EachSorted(func(_ ecstypes.EntityID, comp *Motion) {
+		comp.Velocity = comp.Velocity.ToFixed().ToVector()
+	})
+}
@@ -0,0 +1,139 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"testing"
+)
+
+// newShips returns an Instance with count ships spread across the screen.
+func newShips(t testing.TB, parameters Parameters, count int) *Instance {
+	t.Helper()
+	var instance = NewInstance(parameters)
+	for e := ecstypes.EntityID(0); e < ecstypes.EntityID(count); e++ {
+		var err = instance.AddEntity(e,
+			&Position{Vector: geom.Vector{X: 100 + 50*float64(e), Y: 100 + 30*float64(e)}, Angle: geom.Angle(e)},
+			new(Motion),
+			new(Helm),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return instance
+}
+
+// scriptedInput is a ship flying about, the same every run.
+func scriptedInput(e ecstypes.EntityID, tick int) HelmInput {
+	tick += 17 * int(e)
+	return HelmInput{
+		Left:   tick%90 < 20,
+		Right:  tick%150 > 130,
+		Thrust: tick%60 < 40,
+	}
+}
+
+func scriptedMessages(count, tick int) []ecstypes.ComponentMessage {
+	var msgs []ecstypes.ComponentMessage
+	for e := ecstypes.EntityID(0); e < ecstypes.EntityID(count); e++ {
+		msgs = append(msgs, ecstypes.ComponentMessage{Entity: e, Payload: scriptedInput(e, tick)})
+	}
+	return msgs
+}
+
+func TestSameInputsSameWorld(t *testing.T) {
+	for _, parameters := range []Parameters{
+		{Deterministic: true, Seed: 1},
+		{Deterministic: true, Seed: 2, FixedPoint: true},
+	} {
+		var a, b = newShips(t, parameters, 4), newShips(t, parameters, 4)
+		if a.Rand.Uint64() != b.Rand.Uint64() {
+			t.Fatalf("%+v: random numbers differ", parameters)
+		}
+		for tick := 0; tick < 600; tick++ {
+			var msgs = scriptedMessages(4, tick)
+			if err := a.Step(msgs); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Step(msgs); err != nil {
+				t.Fatal(err)
+			}
+			if a.LastChecksum != b.LastChecksum || a.LastChecksum != a.Checksum() {
+				t.Fatalf("%+v: worlds differ at tick %d", parameters, tick)
+			}
+		}
+	}
+}
+
+func TestDifferentInputsDifferentWorld(t *testing.T) {
+	var parameters = Parameters{Deterministic: true, Seed: 1}
+	var a, b = newShips(t, parameters, 1), newShips(t, parameters, 1)
+	if a.Checksum() != b.Checksum() {
+		t.Fatal("identical worlds have different checksums")
+	}
+	a.Step([]ecstypes.ComponentMessage{{Entity: 0, Payload: HelmInput{Thrust: true}}})
+	b.Step([]ecstypes.ComponentMessage{{Entity: 0, Payload: HelmInput{Left: true}}})
+	if a.LastChecksum == b.LastChecksum {
+		t.Fatal("different inputs gave the same checksum")
+	}
+}
+
+func TestFixedPointQuantizes(t *testing.T) {
+	var instance = newShips(t, Parameters{Deterministic: true, FixedPoint: true}, 2)
+	for tick := 0; tick < 100; tick++ {
+		instance.Step(scriptedMessages(2, tick))
+	}
+	instance.Position.EachSorted(func(e ecstypes.EntityID, comp *Position) {
+		if comp.Vector.ToFixed().ToVector() != comp.Vector {
+			t.Errorf("position of %d isn't fixed point: %v", e, comp.Vector)
+		}
+	})
+}
+
+func TestChecksumCoversComponents(t *testing.T) {
+	var instance, _ = newBehaviorRange(t)
+	var errs = []error{
+		instance.AddEntity(10, &Position{Vector: geom.Vector{X: 100, Y: 100}}, &GravityWell{Mass: 1e6, Radius: 20}),
+		instance.AddEntity(11, new(Position), &Orbit{Primary: 10, SemiMajorAxis: 80}),
+		instance.SpawnEntity(12, "cruiser", Position{Vector: geom.Vector{X: 500, Y: 500}}, new(Fleet)),
+		instance.SpawnEntity(13, "scout", Position{Vector: geom.Vector{X: 600, Y: 500}}, new(AI), &Brain{Tree: "patrol", Team: 1}),
+		instance.AddEntity(14, new(Position), &Parent{Parent: 12}),
+	}
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tests = []struct {
+		name   string
+		change func()
+	}{
+		{"GravityWell.Mass", func() { get(t, instance.GravityWell, 10).Mass++ }},
+		{"Orbit.Epoch", func() { get(t, instance.Orbit, 11).Epoch++ }},
+		{"Parent.Offset", func() { get(t, instance.Parent, 14).Offset.X++ }},
+		{"Shields.SinceHit", func() { get(t, instance.Shields, 12).SinceHit++ }},
+		{"Helm.Thrust", func() { get(t, instance.Helm, 12).Thrust++ }},
+		{"Warp.Held", func() { get(t, instance.Warp, 12).Held = true }},
+		{"Weapons.Damage", func() { get(t, instance.Weapons, 12).Hardpoints[0].Damage++ }},
+		{"Fleet.Spacing", func() { get(t, instance.Fleet, 12).Spacing++ }},
+		{"AI.Match", func() { get(t, instance.AI, 13).Match.X++ }},
+		{"Brain.Team", func() { get(t, instance.Brain, 13).Team++ }},
+	}
+	for _, test := range tests {
+		var before = instance.Checksum()
+		test.change()
+		if instance.Checksum() == before {
+			t.Errorf("changing %s didn't change the checksum", test.name)
+		}
+	}
+}
+
+// get returns e's component from system, failing the test if it has none.
+func get[T ecstypes.Component](t *testing.T, system *SMSystem[T], e ecstypes.EntityID) *T {
+	t.Helper()
+	comp, ok := system.GetComponent(e)
+	if !ok {
+		t.Fatalf("entity %d has no %T", e, *new(T))
+	}
+	return comp
+}
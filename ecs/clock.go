@@ -0,0 +1,56 @@
+package ecs
+
+import "time"
+
+const (
+	// DefaultTickRate is the simulation rate, in ticks per second, used when
+	// Parameters.TickRate is zero.
+	DefaultTickRate = 60
+	// maxCatchUp is the most ticks a Clock will ask for at once. After a long
+	// stall the rest of the backlog is dropped rather than simulated in a
+	// burst that would stall the next frame too.
+	maxCatchUp = 5
+)
+
+// Clock is a fixed-timestep accumulator. Wall time is added as it passes and
+// spent one TickDuration at a time, so the simulation runs at the same rate
+// whatever the frame rate; what is left over gives the interpolation alpha
+// for drawing between ticks.
+type Clock struct {
+	TickDuration time.Duration
+
+	last        time.Time
+	accumulated time.Duration
+}
+
+func NewClock(tickRate int) *Clock {
+	return &Clock{TickDuration: time.Second / time.Duration(tickRate)}
+}
+
+// Advance adds the time since the last call and returns how many ticks are
+// due. The first call starts the clock and returns one tick.
+func (c *Clock) Advance(now time.Time) int {
+	if c.last.IsZero() {
+		c.last = now
+		return 1
+	}
+	c.accumulated += now.Sub(c.last)
+	c.last = now
+	var ticks = int(c.accumulated / c.TickDuration)
+	c.accumulated -= time.Duration(ticks) * c.TickDuration
+	if ticks > maxCatchUp {
+		ticks = maxCatchUp
+		c.accumulated = 0
+	}
+	return ticks
+}
+
+// Alpha returns how far now is between the last tick and the next, from 0 to
+// 1. It is 1 before the clock starts, so positions are drawn as simulated.
+func (c *Clock) Alpha(now time.Time) float64 {
+	if c.last.IsZero() {
+		return 1
+	}
+	var alpha = float64(c.accumulated+now.Sub(c.last)) / float64(c.TickDuration)
+	return max(0, min(alpha, 1))
+}
@@ -0,0 +1,38 @@
+package ecs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockAdvance(t *testing.T) {
+	var start = time.Unix(1000, 0)
+	for _, test := range []struct {
+		name      string
+		elapsed   []time.Duration
+		wantTicks int
+		wantAlpha float64
+	}{
+		{"first call ticks once", nil, 1, 0},
+		{"less than a tick", []time.Duration{5 * time.Millisecond}, 0, 0.5},
+		{"leftovers add up", []time.Duration{6 * time.Millisecond, 6 * time.Millisecond}, 1, 0.2},
+		{"several ticks at once", []time.Duration{32 * time.Millisecond}, 3, 0.2},
+		{"long stall is capped", []time.Duration{time.Second}, maxCatchUp, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var clock = NewClock(100)
+			var now = start
+			var ticks = clock.Advance(now)
+			for _, elapsed := range test.elapsed {
+				now = now.Add(elapsed)
+				ticks = clock.Advance(now)
+			}
+			if ticks != test.wantTicks {
+				t.Errorf("%d ticks, want %d", ticks, test.wantTicks)
+			}
+			if alpha := clock.Alpha(now); alpha < test.wantAlpha-1e-9 || alpha > test.wantAlpha+1e-9 {
+				t.Errorf("alpha %v, want %v", alpha, test.wantAlpha)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package ecs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+)
+
+var ErrCodec = errors.New("codec error")
+
+// MessageType tags the payload of an encoded ComponentMessage.
+type MessageType uint8
+
+const (
+	MessageHelmInput MessageType = iota + 1
+	MessageSyncInput
+	MessageRollbackInput
+	MessagePhaserBeam
+	MessageTorpedoLaunch
+	MessageDespawned
+	MessageDestroyed
+	MessagePowerInput
+	MessagePowerAllocation
+	MessageWarpStatus
+	MessageSectorChanged
+	MessageSquadCommand
+	MessageSquadOrders
+	MessageShipState
+)
+
+// EncodeMessage writes msg as: entity (uint64), payload type (uint8), payload.
+// Payloads are fixed-size structs written little-endian.
+func EncodeMessage(msg ecstypes.ComponentMessage) ([]byte, error) {
+	var msgType MessageType
+	switch msg.Payload.(type) {
+	case HelmInput:
+		msgType = MessageHelmInput
+	case SyncInput:
+		msgType = MessageSyncInput
+	case RollbackInput:
+		msgType = MessageRollbackInput
+	case PhaserBeam:
+		msgType = MessagePhaserBeam
+	case TorpedoLaunch:
+		msgType = MessageTorpedoLaunch
+	case Despawned:
+		msgType = MessageDespawned
+	case Destroyed:
+		msgType = MessageDestroyed
+	case PowerInput:
+		msgType = MessagePowerInput
+	case PowerAllocation:
+		msgType = MessagePowerAllocation
+	case WarpStatus:
+		msgType = MessageWarpStatus
+	case SectorChanged:
+		msgType = MessageSectorChanged
+	case SquadCommand:
+		msgType = MessageSquadCommand
+	case SquadOrders:
+		msgType = MessageSquadOrders
+	case ShipState:
+		msgType = MessageShipState
+	default:
+		return nil, fmt.Errorf("unknown payload %T: %w", msg.Payload, ErrCodec)
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, uint64(msg.Entity)); err != nil {
+		return nil, fmt.Errorf("writing entity: %w", err)
+	}
+	buf.WriteByte(byte(msgType))
+	if err := binary.Write(&buf, binary.LittleEndian, msg.Payload); err != nil {
+		return nil, fmt.Errorf("writing payload: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func DecodeMessage(data []byte) (ecstypes.ComponentMessage, error) {
+	var msg ecstypes.ComponentMessage
+	var entity uint64
+	reader := bytes.NewReader(data)
+	if err := binary.Read(reader, binary.LittleEndian, &entity); err != nil {
+		return msg, fmt.Errorf("reading entity: %w", err)
+	}
+	msg.Entity = ecstypes.EntityID(entity)
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return msg, fmt.Errorf("reading payload type: %w", err)
+	}
+	switch MessageType(tag) {
+	case MessageHelmInput:
+		msg.Payload, err = decodePayload[HelmInput](reader)
+	case MessageSyncInput:
+		msg.Payload, err = decodePayload[SyncInput](reader)
+	case MessageRollbackInput:
+		msg.Payload, err = decodePayload[RollbackInput](reader)
+	case MessagePhaserBeam:
+		msg.Payload, err = decodePayload[PhaserBeam](reader)
+	case MessageTorpedoLaunch:
+		msg.Payload, err = decodePayload[TorpedoLaunch](reader)
+	case MessageDespawned:
+		msg.Payload, err = decodePayload[Despawned](reader)
+	case MessageDestroyed:
+		msg.Payload, err = decodePayload[Destroyed](reader)
+	case MessagePowerInput:
+		msg.Payload, err = decodePayload[PowerInput](reader)
+	case MessagePowerAllocation:
+		msg.Payload, err = decodePayload[PowerAllocation](reader)
+	case MessageWarpStatus:
+		msg.Payload, err = decodePayload[WarpStatus](reader)
+	case MessageSectorChanged:
+		msg.Payload, err = decodePayload[SectorChanged](reader)
+	case MessageSquadCommand:
+		msg.Payload, err = decodePayload[SquadCommand](reader)
+	case MessageSquadOrders:
+		msg.Payload, err = decodePayload[SquadOrders](reader)
+	case MessageShipState:
+		msg.Payload, err = decodePayload[ShipState](reader)
+	default:
+		return msg, fmt.Errorf("unknown payload type %d: %w", tag, ErrCodec)
+	}
+	if err != nil {
+		return msg, err
+	}
+	if reader.Len() != 0 {
+		return msg, fmt.Errorf("%d trailing bytes: %w", reader.Len(), ErrCodec)
+	}
+	return msg, nil
+}
+
+func decodePayload[T any](reader *bytes.Reader) (T, error) {
+	var payload T
+	if err := binary.Read(reader, binary.LittleEndian, &payload); err != nil {
+		return payload, fmt.Errorf("reading %T: %w", payload, err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,63 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	var payloads = []any{
+		HelmInput{Left: true, Thrust: true},
+		SyncInput{Position: geom.Vector{X: 1, Y: 2}, Velocity: geom.Vector{X: -3, Y: 4}, Angle: 0.5},
+		RollbackInput{Frame: 99, Input: HelmInput{Right: true}},
+		HelmInput{FirePhaser: true, FireTorpedo: true},
+		PhaserBeam{From: geom.Vector{X: 1, Y: 2}, To: geom.Vector{X: 300, Y: 2}},
+		TorpedoLaunch{Owner: 3, Position: geom.Vector{X: 5, Y: 6}, Velocity: geom.Vector{X: 400}, Angle: 1, Damage: 40, Lifetime: 2},
+		Despawned{},
+		Destroyed{Source: 4, Position: geom.Vector{X: 7, Y: 8}},
+		PowerInput{Allocation: [4]float64{3, 1, 0, 0}},
+		PowerAllocation{Allocation: [4]float64{0.75, 0.25, 0, 0}},
+		HelmInput{Thrust: true, Warp: true},
+		WarpStatus{State: WarpEngaged, Factor: 2.5, Remaining: 1},
+		SectorChanged{Sector: SectorCoord{X: -1, Y: 7}},
+		SquadCommand{Order: OrderAttack, Formation: FormationRing, Target: 9, HasTarget: true},
+		SquadOrders{Order: OrderHold, Formation: FormationWedge, Target: 2, Point: geom.Vector{X: 3, Y: 4}, Angle: 1},
+		ShipState{Class: NewClassName("scout"), Position: geom.Vector{X: 1, Y: 2}, Velocity: geom.Vector{X: 3}, Angle: 0.5},
+	}
+	for _, payload := range payloads {
+		var msg = ecstypes.ComponentMessage{Entity: 1<<40 + 3, Payload: payload}
+		data, err := EncodeMessage(msg)
+		if err != nil {
+			t.Fatalf("encoding %T: %v", payload, err)
+		}
+		decoded, err := DecodeMessage(data)
+		if err != nil {
+			t.Fatalf("decoding %T: %v", payload, err)
+		}
+		if decoded != msg {
+			t.Fatalf("%T round-tripped to %+v, want %+v", payload, decoded, msg)
+		}
+	}
+}
+
+func TestMessageErrors(t *testing.T) {
+	if _, err := EncodeMessage(ecstypes.ComponentMessage{Payload: "text"}); !errors.Is(err, ErrCodec) {
+		t.Fatalf("encoding an unknown payload: got %v, want ErrCodec", err)
+	}
+	data, err := EncodeMessage(ecstypes.ComponentMessage{Entity: 1, Payload: HelmInput{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecodeMessage(append(data, 0)); !errors.Is(err, ErrCodec) {
+		t.Fatalf("decoding trailing bytes: got %v, want ErrCodec", err)
+	}
+	data[8] = 0xff
+	if _, err = DecodeMessage(data); !errors.Is(err, ErrCodec) {
+		t.Fatalf("decoding an unknown type: got %v, want ErrCodec", err)
+	}
+	if _, err = DecodeMessage(data[:4]); err == nil {
+		t.Fatal("decoding a truncated message succeeded")
+	}
+}
@@ -0,0 +1,223 @@
+package ecs
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/spatial"
+	"github.com/StCredZero/vectrek/vterr"
+	"slices"
+)
+
+// Collision layers. A Collider is on the layers in Layer and collides with
+// colliders on the layers in Mask.
+const (
+	LayerShip uint32 = 1 << iota
+	LayerProjectile
+	LayerObstacle
+	LayerAll uint32 = 1<<32 - 1
+)
+
+// collisionCellSize is the broad phase grid size; about the size of a ship.
+const collisionCellSize = 32
+
+// Collider gives an entity a Shape in its local frame, placed in the world by
+// its Position. A zero Layer means LayerShip and a zero Mask means LayerAll.
+//
+// An Outline, if given, is used instead of Shape: it may be concave, and is
+// split into convex Parts at Init. Giving a ship's Sprite and Collider the
+// same Outline makes it collide as drawn.
+type Collider struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+	Shape    geom.Shape
+	Outline  geom.Outline
+	Layer    uint32
+	Mask     uint32
+
+	Parts []geom.Shape `json:"-"`
+}
+
+func (comp Collider) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if comp.Layer == 0 {
+		comp.Layer = LayerShip
+	}
+	if comp.Mask == 0 {
+		comp.Mask = LayerAll
+	}
+	if len(comp.Outline) >= 3 {
+		comp.Parts = comp.Outline.Shapes()
+	} else {
+		comp.Parts = []geom.Shape{comp.Shape}
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding collider: %w", err)
+	}
+	return nil
+}
+func (comp Collider) Update(_ ecstypes.SystemManager) (Collider, error) {
+	return comp, nil
+}
+func (comp Collider) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemCollider
+}
+
+// BoundingRadius is the radius of the circle around the Position that
+// contains every part.
+func (comp Collider) BoundingRadius() float64 {
+	var result float64
+	for _, part := range comp.Parts {
+		result = max(result, part.BoundingRadius())
+	}
+	return result
+}
+
+// WorldShapes returns the parts placed at the collider's Position, offset by
+// the given displacement (used to place them across a world edge).
+func (comp Collider) WorldShapes(offset geom.Vector) []geom.Shape {
+	var pose = geom.Pose(comp.Position.Vector.Add(offset), comp.Position.Angle)
+	var result = make([]geom.Shape, len(comp.Parts))
+	for index, part := range comp.Parts {
+		result[index] = part.Transform(pose)
+	}
+	return result
+}
+
+// collide returns the deepest contact between any parts of two colliders,
+// the second offset by the given displacement.
+func (comp Collider) collide(other Collider, offset geom.Vector) (geom.Contact, bool) {
+	var best geom.Contact
+	var found bool
+	var others = other.WorldShapes(offset)
+	for _, a := range comp.WorldShapes(geom.Vector{}) {
+		for _, b := range others {
+			if contact, ok := geom.Collide(a, b); ok && (!found || contact.Depth > best.Depth) {
+				best, found = contact, true
+			}
+		}
+	}
+	return best, found
+}
+
+// Interacts reports whether two colliders' layers and masks let them touch.
+func (comp Collider) Interacts(other Collider) bool {
+	return comp.Mask&other.Layer != 0 || other.Mask&comp.Layer != 0
+}
+
+// detectCollisions finds every touching pair of colliders: a wrap-aware grid
+// over bounding circles finds candidates, then the shapes are tested with the
+// second one moved next to the first across any world edge between them.
+func (i *Instance) detectCollisions() {
+	clear(i.collisions)
+	i.Collisions = i.Collisions[:0]
+	if i.Collider.Map.Len() < 2 {
+		return
+	}
+	if i.collisionGrid == nil || i.collisionGrid.World != i.World {
+		i.collisionGrid = spatial.NewGrid(i.World, collisionCellSize)
+	}
+	var grid = i.collisionGrid
+	grid.Reset()
+	i.Collider.EachSorted(func(e ecstypes.EntityID, comp *Collider) {
+		grid.Insert(spatial.Item{
+			ID:       uint64(e),
+			Position: comp.Position.Vector,
+			Radius:   comp.BoundingRadius(),
+		})
+	})
+	grid.Pairs(func(a, b spatial.Item) {
+		var ca, _ = i.Collider.GetComponent(ecstypes.EntityID(a.ID))
+		var cb, _ = i.Collider.GetComponent(ecstypes.EntityID(b.ID))
+		if !ca.Interacts(*cb) {
+			return
+		}
+		var offset = i.World.Delta(ca.Position.Vector, cb.Position.Vector).Sub(cb.Position.Vector.Sub(ca.Position.Vector))
+		contact, ok := ca.collide(*cb, offset)
+		if !ok {
+			return
+		}
+		contact.Point = i.World.Wrap(contact.Point)
+		var collision = ecstypes.Collision{Entity: ca.Entity, Other: cb.Entity, Contact: contact}
+		i.Collisions = append(i.Collisions, collision)
+		i.collisions[ca.Entity] = append(i.collisions[ca.Entity], collision)
+		collision.Entity, collision.Other = cb.Entity, ca.Entity
+		collision.Normal = collision.Normal.Negate()
+		i.collisions[cb.Entity] = append(i.collisions[cb.Entity], collision)
+	})
+}
+
+// GetCollisions returns this tick's collisions involving e, each seen from e.
+func (i *Instance) GetCollisions(e ecstypes.EntityID) []ecstypes.Collision {
+	return i.collisions[e]
+}
+
+// Raycast tests the ray against the Collider shapes of every entity the
+// spatial index puts along it, placing each across the world's edges where
+// the ray reaches it.
+func (i *Instance) Raycast(ray ecstypes.Ray) []ecstypes.RayHit {
+	var mask = ray.Mask
+	if mask == 0 {
+		mask = LayerAll
+	}
+	var direction = ray.Direction.Normalize()
+	var hits []ecstypes.RayHit
+	for _, candidate := range i.spatialIndex.Ray(ray.Origin, direction, ray.Length) {
+		if slices.Contains(ray.Ignore, candidate.ID) {
+			continue
+		}
+		collider, ok := i.Collider.GetComponent(candidate.ID)
+		if !ok || collider.Layer&mask == 0 {
+			continue
+		}
+		var near = ray.Origin.Add(direction.Multiply(candidate.Distance))
+		var offset = near.Add(i.World.Delta(near, collider.Position.Vector)).Sub(collider.Position.Vector)
+		var hit geom.RayHit
+		var found bool
+		for _, part := range collider.WorldShapes(offset) {
+			if partHit, ok := part.Raycast(ray.Origin, direction, ray.Length); ok && (!found || partHit.Distance < hit.Distance) {
+				hit, found = partHit, true
+			}
+		}
+		if !found {
+			continue
+		}
+		hit.Point = i.World.Wrap(hit.Point)
+		hits = append(hits, ecstypes.RayHit{Entity: candidate.ID, RayHit: hit})
+	}
+	slices.SortFunc(hits, func(a, b ecstypes.RayHit) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Entity, b.Entity)
+	})
+	return hits
+}
+
+// RaycastFirst returns the nearest Collider the ray hits.
+func (i *Instance) RaycastFirst(ray ecstypes.Ray) (ecstypes.RayHit, bool) {
+	var hits = i.Raycast(ray)
+	if len(hits) == 0 {
+		return ecstypes.RayHit{}, false
+	}
+	return hits[0], true
+}
+
+// LineOfSight reports whether a segment between the Positions of two
+// entities is clear of Colliders on the mask's layers other than their own.
+// An entity without a Position can't be seen.
+func (i *Instance) LineOfSight(from, to ecstypes.EntityID, mask uint32) bool {
+	a, okA := i.Position.GetComponent(from)
+	b, okB := i.Position.GetComponent(to)
+	if !okA || !okB {
+		return false
+	}
+	var ray = ecstypes.SegmentRay(i.World, a.Vector, b.Vector, mask)
+	ray.Ignore = []ecstypes.EntityID{from, to}
+	return len(i.Raycast(ray)) == 0
+}
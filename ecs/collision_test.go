@@ -0,0 +1,203 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func addCollider(t testing.TB, instance *Instance, e ecstypes.EntityID, at geom.Vector, collider *Collider, extra ...ecstypes.Component) ecstypes.EntityID {
+	t.Helper()
+	var components = append([]ecstypes.Component{&Position{Vector: at}, new(Motion), collider}, extra...)
+	if err := instance.AddEntity(e, components...); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestCollisionEvents(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	var a = addCollider(t, instance, 0, geom.Vector{X: 500, Y: 500}, &Collider{Shape: geom.Circle(10), Mask: LayerShip})
+	var b = addCollider(t, instance, 1, geom.Vector{X: 515, Y: 500}, &Collider{Shape: geom.Circle(10), Mask: LayerShip})
+	// touching a and b, but on a layer neither collides with
+	var ghost = addCollider(t, instance, 2, geom.Vector{X: 507, Y: 500}, &Collider{Shape: geom.Circle(10), Layer: LayerProjectile, Mask: LayerProjectile})
+	// across the world's corner from each other
+	var c = addCollider(t, instance, 3, geom.Vector{X: 2, Y: 2}, &Collider{Shape: geom.Circle(5)})
+	var d = addCollider(t, instance, 4, geom.Vector{X: 996, Y: 998}, &Collider{Shape: geom.Circle(5)})
+	instance.detectCollisions()
+
+	if len(instance.Collisions) != 2 {
+		t.Fatalf("%d collisions, want 2: %+v", len(instance.Collisions), instance.Collisions)
+	}
+	if got := instance.GetCollisions(ghost); len(got) != 0 {
+		t.Errorf("masked collider collided: %+v", got)
+	}
+	for _, pair := range [][2]ecstypes.EntityID{{a, b}, {b, a}, {c, d}, {d, c}} {
+		var got = instance.GetCollisions(pair[0])
+		if len(got) != 1 || got[0].Entity != pair[0] || got[0].Other != pair[1] {
+			t.Fatalf("collisions of %d: %+v", pair[0], got)
+		}
+	}
+	// each side's normal points at the other
+	var ab, ba = instance.GetCollisions(a)[0], instance.GetCollisions(b)[0]
+	if ab.Normal.X <= 0 || ba.Normal != ab.Normal.Negate() || ab.Depth != 5 {
+		t.Errorf("a sees %+v, b sees %+v", ab.Contact, ba.Contact)
+	}
+	if cd := instance.GetCollisions(c)[0]; cd.Normal.X >= 0 || cd.Normal.Y >= 0 {
+		t.Errorf("normal from c to d doesn't cross the corner: %+v", cd.Contact)
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestConcaveCollider(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	// an L 100 across with its notch's corner at (550, 550)
+	var ell = geom.Outline{{X: -50, Y: -50}, {X: 50, Y: -50}, {X: 50}, {}, {Y: 50}, {X: -50, Y: 50}}
+	var wall = addCollider(t, instance, 0, geom.Vector{X: 500, Y: 500}, &Collider{Outline: ell})
+	// inside the notch, within the L's bounding circle but clear of it
+	var notched = addCollider(t, instance, 1, geom.Vector{X: 530, Y: 530}, &Collider{Shape: geom.Circle(10)})
+	var touching = addCollider(t, instance, 2, geom.Vector{X: 460, Y: 560}, &Collider{Shape: geom.Circle(20)})
+	instance.detectCollisions()
+
+	if got := instance.GetCollisions(notched); len(got) != 0 {
+		t.Errorf("circle in the notch collided: %+v", got)
+	}
+	if got := instance.GetCollisions(touching); len(got) != 1 || got[0].Other != wall {
+		t.Errorf("circle over the L's edge collided with %+v", got)
+	}
+}
+
+func TestBodiesBounce(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		massA, massB float64
+		wantA, wantB float64
+	}{
+		{"equal masses swap velocities", 1, 1, -2, 2},
+		{"immovable b reflects a", 1, 0, -2, 0},
+		{"heavier a stops", 3, 1, 0, 4},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+			var a = addCollider(t, instance, 0, geom.Vector{X: 491, Y: 500}, &Collider{Shape: geom.Circle(10)}, &Body{Mass: test.massA, Restitution: 1})
+			var b = addCollider(t, instance, 1, geom.Vector{X: 509, Y: 500}, &Collider{Shape: geom.Circle(10)}, &Body{Mass: test.massB, Restitution: 1})
+			var motionA, _ = instance.Motion.GetComponent(a)
+			var motionB, _ = instance.Motion.GetComponent(b)
+			motionA.Velocity = geom.Vector{X: 2}
+			if test.massB > 0 {
+				motionB.Velocity = geom.Vector{X: -2}
+			}
+			var momentum = test.massA*motionA.Velocity.X + test.massB*motionB.Velocity.X
+			instance.detectCollisions()
+			instance.resolveCollisions()
+
+			if !near(motionA.Velocity.X, test.wantA) || !near(motionB.Velocity.X, test.wantB) {
+				t.Errorf("velocities %v and %v, want %v and %v", motionA.Velocity.X, motionB.Velocity.X, test.wantA, test.wantB)
+			}
+			if test.massB > 0 && !near(test.massA*motionA.Velocity.X+test.massB*motionB.Velocity.X, momentum) {
+				t.Error("momentum isn't conserved")
+			}
+			var positionA, _ = instance.Position.GetComponent(a)
+			var positionB, _ = instance.Position.GetComponent(b)
+			if positionB.X-positionA.X <= 18 {
+				t.Error("bodies weren't pushed out of overlap")
+			}
+		})
+	}
+}
+
+func TestRaycast(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	var front = addCollider(t, instance, 0, geom.Vector{X: 200, Y: 500}, &Collider{Shape: geom.Circle(10)})
+	var back = addCollider(t, instance, 1, geom.Vector{X: 400, Y: 500}, &Collider{Shape: geom.Circle(10)})
+	var projectile = addCollider(t, instance, 2, geom.Vector{X: 300, Y: 500}, &Collider{Shape: geom.Circle(10), Layer: LayerProjectile})
+	// across the world's left edge from the origin
+	var wrapped = addCollider(t, instance, 3, geom.Vector{X: 950, Y: 500}, &Collider{Shape: geom.Circle(10)})
+	instance.updateSpatialIndex()
+
+	var tests = []struct {
+		name string
+		ray  ecstypes.Ray
+		want []ecstypes.EntityID
+	}{
+		{"nearest first", ecstypes.Ray{Origin: geom.Vector{X: 100, Y: 500}, Direction: geom.Vector{X: 2}, Length: 500}, []ecstypes.EntityID{front, projectile, back}},
+		{"masked", ecstypes.Ray{Origin: geom.Vector{X: 100, Y: 500}, Direction: geom.Vector{X: 1}, Length: 500, Mask: LayerShip}, []ecstypes.EntityID{front, back}},
+		{"ignored", ecstypes.Ray{Origin: geom.Vector{X: 100, Y: 500}, Direction: geom.Vector{X: 1}, Length: 500, Ignore: []ecstypes.EntityID{front}}, []ecstypes.EntityID{projectile, back}},
+		{"across the edge", ecstypes.Ray{Origin: geom.Vector{X: 100, Y: 500}, Direction: geom.Vector{X: -1}, Length: 500}, []ecstypes.EntityID{wrapped}},
+		{"short", ecstypes.Ray{Origin: geom.Vector{X: 100, Y: 500}, Direction: geom.Vector{X: 1}, Length: 50}, nil},
+	}
+	for _, test := range tests {
+		var got []ecstypes.EntityID
+		for _, hit := range instance.Raycast(test.ray) {
+			got = append(got, hit.Entity)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: hit %v, want %v", test.name, got, test.want)
+		}
+	}
+	if hit, ok := instance.RaycastFirst(tests[3].ray); !ok || !near(hit.Distance, 140) || !near(hit.Point.X, 960) {
+		t.Errorf("hit across the edge at %+v", hit)
+	}
+
+	if instance.LineOfSight(front, back, LayerAll) {
+		t.Error("line of sight through a projectile")
+	}
+	if !instance.LineOfSight(front, back, LayerShip) {
+		t.Error("no line of sight past a masked projectile")
+	}
+	if !instance.LineOfSight(front, wrapped, LayerAll) {
+		t.Error("no line of sight the short way across the edge")
+	}
+	if instance.LineOfSight(front, 99, LayerAll) {
+		t.Error("line of sight to an entity that doesn't exist")
+	}
+}
+
+// benchmarkBodies fills a world with count ships' worth of colliding bodies,
+// dense enough that a few percent touch at any time.
+func benchmarkBodies(b *testing.B, count int) *Instance {
+	var instance = NewInstance(Parameters{ScreenWidth: 8000, ScreenHeight: 8000, Deterministic: true})
+	instance.SetSender(Discard{})
+	var r = rand.New(rand.NewPCG(1, 2))
+	var outline = geom.Outline{{X: 15}, {X: -7.5, Y: 13}, {X: -7.5, Y: -13}}
+	for n := 0; n < count; n++ {
+		var at = geom.Vector{X: r.Float64() * instance.World.Width, Y: r.Float64() * instance.World.Height}
+		var e = addCollider(b, instance, ecstypes.EntityID(n), at, &Collider{Outline: outline}, &Body{Mass: 1, Restitution: 0.5})
+		var motion, _ = instance.Motion.GetComponent(e)
+		motion.Velocity = geom.Angle(r.Float64() * 6.3).ToVector().Multiply(100)
+	}
+	return instance
+}
+
+func BenchmarkDetectCollisions(b *testing.B) {
+	for _, count := range []int{1000, 5000} {
+		b.Run(fmt.Sprint(count), func(b *testing.B) {
+			var instance = benchmarkBodies(b, count)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				instance.detectCollisions()
+			}
+		})
+	}
+}
+
+func BenchmarkStepBodies(b *testing.B) {
+	for _, count := range []int{1000, 5000} {
+		b.Run(fmt.Sprint(count), func(b *testing.B) {
+			var instance = benchmarkBodies(b, count)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := instance.Step(nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
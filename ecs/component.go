@@ -3,14 +3,10 @@ package ecs
 import (
 	"errors"
 	"fmt"
-	"github.com/StCredZero/vectrek/constants"
 	"github.com/StCredZero/vectrek/ecstypes"
 	"github.com/StCredZero/vectrek/geom"
-	"github.com/StCredZero/vectrek/globals"
 	"github.com/StCredZero/vectrek/vterr"
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/vector"
-	"math"
+	"image/color"
 )
 
 var ErrType = errors.New("type error")
@@ -19,25 +15,38 @@ type Position struct {
 	Entity ecstypes.EntityID
 	geom.Vector
 	geom.Angle
+	// Previous and PreviousAngle are the pose at the start of the tick, for
+	// interpolating between ticks when drawing.
+	Previous      geom.Vector `json:"-"`
+	PreviousAngle geom.Angle  `json:"-"`
 }
 
 func (comp Position) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
 	comp.Entity = entity
+	comp.Previous, comp.PreviousAngle = comp.Vector, comp.Angle
 	if err := sm.AddComponent(entity, comp); err != nil {
 		return fmt.Errorf("adding position: %w", err)
 	}
 	return nil
 }
 func (comp Position) Update(_ ecstypes.SystemManager) (Position, error) {
+	comp.Previous, comp.PreviousAngle = comp.Vector, comp.Angle
 	return comp, nil
 }
+
+// Interpolate returns the pose alpha of the way from the start of the tick to
+// now, the short way round across the world's edges.
+func (comp *Position) Interpolate(world geom.Torus, alpha float64) (geom.Vector, geom.Angle) {
+	var vector = world.Wrap(comp.Previous.Add(world.Delta(comp.Previous, comp.Vector).Multiply(alpha)))
+	return vector, comp.PreviousAngle.Lerp(comp.Angle, alpha)
+}
 func (comp Position) SystemID() ecstypes.SystemID {
 	return ecstypes.SystemPosition
 }
 
 type Motion struct {
 	Entity   ecstypes.EntityID
-	Position *Position
+	Position *Position `json:"-"`
 	Velocity geom.Vector
 }
 
@@ -52,31 +61,29 @@ func (comp Motion) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) err
 	}
 	return nil
 }
-func (comp Motion) Update(_ ecstypes.SystemManager) (Motion, error) {
-	comp.Position.Vector = comp.Position.Vector.Add(comp.Velocity)
-
-	// Wrap around screen edges (toroidal topology)
-	if comp.Position.X < 0 {
-		comp.Position.X += constants.ScreenWidth
-	} else if comp.Position.X >= constants.ScreenWidth {
-		comp.Position.X -= constants.ScreenWidth
-	}
-	if comp.Position.Y < 0 {
-		comp.Position.Y += constants.ScreenHeight
-	} else if comp.Position.Y >= constants.ScreenHeight {
-		comp.Position.Y -= constants.ScreenHeight
-	}
+func (comp Motion) Update(sm ecstypes.SystemManager) (Motion, error) {
+	// Semi-implicit Euler: Helm and Body have already updated the velocity
+	// for this tick. Wrap around screen edges (toroidal topology).
+	var step = comp.Velocity.Multiply(sm.GetTimeStep())
+	comp.Position.Vector = sm.GetWorld().Wrap(comp.Position.Vector.Add(step))
 	return comp, nil
 }
 func (comp Motion) SystemID() ecstypes.SystemID {
 	return ecstypes.SystemMotion
 }
 
+// Helm steers a ship by its Input. Thrust, TurnRate and MaxVelocity are the
+// ship's performance, per second; zero means ThrustAccel, TurnRate and
+// MaxVelocity.
 type Helm struct {
 	Entity   ecstypes.EntityID
-	Position *Position
-	Motion   *Motion
+	Position *Position `json:"-"`
+	Motion   *Motion   `json:"-"`
 	Input    HelmInput
+
+	Thrust      float64
+	TurnRate    float64
+	MaxVelocity float64
 }
 
 func (comp Helm) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
@@ -88,35 +95,95 @@ func (comp Helm) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error
 	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
 		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
 	}
+	if comp.Thrust == 0 {
+		comp.Thrust = ThrustAccel
+	}
+	if comp.TurnRate == 0 {
+		comp.TurnRate = TurnRate
+	}
+	if comp.MaxVelocity == 0 {
+		comp.MaxVelocity = MaxVelocity
+	}
 	if err = sm.AddComponent(entity, comp); err != nil {
 		return fmt.Errorf("adding motion: %w", err)
 	}
 	return nil
 }
-func (comp Helm) Update(_ ecstypes.SystemManager) (Helm, error) {
+func (comp Helm) Update(sm ecstypes.SystemManager) (Helm, error) {
 	input := comp.Input
+	var turn float64
 	if input.Left {
-		comp.Position.Angle -= 3 * (math.Pi / 180)
+		turn -= comp.TurnRate
 	}
 	if input.Right {
-		comp.Position.Angle += 3 * (math.Pi / 180)
+		turn += comp.TurnRate
 	}
+	var thrust geom.Vector
 	if input.Thrust {
-		// Update velocity based on velocity and angle
-		comp.Motion.Velocity = comp.Motion.Velocity.Add(comp.Position.Angle.ToVector().Multiply(ThrustAccel))
+		thrust = comp.Position.Angle.ToVector().Multiply(comp.Thrust)
+	}
+
+	// At impulse, ships with a Body are steered through forces so that
+	// collisions and mass affect them; the rest move directly.
+	body, err := GetComponent[Body](sm, comp.Entity)
+	if err != nil {
+		return comp, err
+	}
+	hull, err := GetComponent[Hull](sm, comp.Entity)
+	if err != nil {
+		return comp, err
+	}
+	if hull != nil {
+		thrust = thrust.Multiply(hull.ThrustFactor())
+		turn *= hull.TurnFactor()
+	}
+	warp, err := GetComponent[Warp](sm, comp.Entity)
+	if err != nil {
+		return comp, err
 	}
+	if warp != nil && warp.Engaged() {
+		return comp, comp.warpHelm(sm, warp, turn)
+	}
+	var dt = sm.GetTimeStep()
+	if input.Thrust {
+		// short of power the engines give what thrust they can
+		power, err := drawPower(sm, comp.Entity, PowerEngines, ThrustPower*dt)
+		if err != nil {
+			return comp, err
+		}
+		thrust = thrust.Multiply(power / (ThrustPower * dt))
+	}
+	if body != nil {
+		body.ApplyForce(thrust.Multiply(body.Mass))
+		// the torque that brings the turn rate to the helm's in one tick
+		body.ApplyTorque((turn - body.AngularVelocity) * body.Inertia / dt)
+		return comp, nil
+	}
+	comp.Position.Angle += geom.Angle(turn * dt)
+	// Update velocity based on velocity and angle
+	comp.Motion.Velocity = comp.Motion.Velocity.Add(thrust.Multiply(dt)).ClampLength(comp.MaxVelocity)
 	return comp, nil
 }
 func (comp Helm) SystemID() ecstypes.SystemID {
 	return ecstypes.SystemHelm
 }
 
+// ShipOutline is the default ship, a triangle pointing along the x axis.
+var ShipOutline = geom.Outline{
+	geom.Vector{X: 15},
+	geom.Degrees(120).ToVector().Multiply(15),
+	geom.Degrees(-120).ToVector().Multiply(15),
+}
+
+// Sprite draws an entity as its Outline, or ShipOutline if it has none, in
+// its Color, or white if that's zero.
 type Sprite struct {
-	Entity   ecstypes.EntityID
-	Motion   *Motion
-	Position *Position
-	Vertices []ebiten.Vertex
-	Indices  []uint16
+	Entity     ecstypes.EntityID
+	Outline    geom.Outline
+	Color      color.RGBA
+	Motion     *Motion   `json:"-"`
+	Position   *Position `json:"-"`
+	spriteMesh `json:"-"`
 }
 
 func (comp Sprite) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
@@ -136,63 +203,19 @@ func (comp Sprite) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) err
 func (comp Sprite) Update(_ ecstypes.SystemManager) (Sprite, error) {
 	return comp, nil
 }
-func (comp *Sprite) Draw(screen *ebiten.Image, aa bool, line bool) {
-	var path vector.Path
-
-	// Define ship as a triangle
-	length := float32(15.0)
-	theta := float32(comp.Position.Angle)
-
-	// Front point
-	path.MoveTo(
-		float32(comp.Motion.Position.X)+length*float32(math.Cos(float64(theta))),
-		float32(comp.Motion.Position.Y)+length*float32(math.Sin(float64(theta))),
-	)
-
-	// Right point (120 degrees from front)
-	path.LineTo(
-		float32(comp.Motion.Position.X)+length*float32(math.Cos(float64(theta)+2.0944)), // 2.0944 rad = 120 deg
-		float32(comp.Motion.Position.Y)+length*float32(math.Sin(float64(theta)+2.0944)),
-	)
-
-	// Left point (-120 degrees from front)
-	path.LineTo(
-		float32(comp.Motion.Position.X)+length*float32(math.Cos(float64(theta)-2.0944)),
-		float32(comp.Motion.Position.Y)+length*float32(math.Sin(float64(theta)-2.0944)),
-	)
-
-	path.Close()
 
-	if line {
-		op := &vector.StrokeOptions{}
-		op.Width = 2
-		op.LineJoin = vector.LineJoinRound
-		comp.Vertices, comp.Indices = path.AppendVerticesAndIndicesForStroke(comp.Vertices[:0], comp.Indices[:0], op)
-	} else {
-		comp.Vertices, comp.Indices = path.AppendVerticesAndIndicesForFilling(comp.Vertices[:0], comp.Indices[:0])
-	}
-
-	for i := range comp.Vertices {
-		comp.Vertices[i].SrcX = 1
-		comp.Vertices[i].SrcY = 1
-		comp.Vertices[i].ColorR = 1
-		comp.Vertices[i].ColorG = 1
-		comp.Vertices[i].ColorB = 1
-		comp.Vertices[i].ColorA = 1
-	}
-
-	op := &ebiten.DrawTrianglesOptions{}
-	op.AntiAlias = aa
-	op.FillRule = ebiten.FillRuleNonZero
-	screen.DrawTriangles(comp.Vertices, comp.Indices, globals.WhiteSubImage, op)
-}
 func (comp Sprite) SystemID() ecstypes.SystemID {
 	return ecstypes.SystemSprite
 }
 
+// powerStep is how much of the reactor's output a power key shifts.
+const powerStep = 0.1
+
 type Player struct {
 	Entity       ecstypes.EntityID
 	CurrentInput HelmInput
+	PowerKeys    [5]bool
+	SquadKeys    [5]bool
 }
 
 func (comp Player) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
@@ -204,23 +227,65 @@ func (comp Player) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) err
 	return nil
 }
 func (comp Player) Update(sm ecstypes.SystemManager) (Player, error) {
-	var shipInput HelmInput
-	if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
-		shipInput.Left = true
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
-		shipInput.Right = true
-	}
-	if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
-		shipInput.Thrust = true
-	}
+	var shipInput = KeyboardHelmInput()
 	if comp.CurrentInput != shipInput {
 		comp.CurrentInput = shipInput
 		sm.GetSender().Send(ecstypes.ComponentMessage{
-			Entity:  ecstypes.EntityID(0),
+			Entity:  comp.Entity,
 			Payload: comp.CurrentInput,
 		})
 	}
+	var allocation = NormalizeAllocation([4]float64{})
+	reactor, err := GetComponent[Reactor](sm, comp.Entity)
+	if err != nil {
+		return comp, err
+	}
+	if reactor != nil {
+		allocation = reactor.Allocation
+	}
+	// a key acts once when it goes down, however many ticks it's held
+	var held = KeyboardPowerKeys()
+	for index, down := range held {
+		if !down || comp.PowerKeys[index] {
+			continue
+		}
+		var input PowerInput
+		if index < len(allocation) {
+			input.Allocation = allocation
+			input.Allocation[index] += powerStep
+		}
+		sm.GetSender().Send(ecstypes.ComponentMessage{
+			Entity:  comp.Entity,
+			Payload: input,
+		})
+	}
+	comp.PowerKeys = held
+
+	fleet, err := GetComponent[Fleet](sm, comp.Entity)
+	if err != nil || fleet == nil {
+		return comp, err
+	}
+	held = KeyboardSquadKeys()
+	for index, down := range held {
+		if !down || comp.SquadKeys[index] {
+			continue
+		}
+		var command = SquadCommand{Order: fleet.Order, Formation: fleet.Formation}
+		if index < len(held)-1 {
+			command.Order = SquadOrder(index)
+		} else {
+			command.Formation = command.Formation.Next()
+		}
+		if command.Order == OrderAttack && command.Formation != fleet.Formation {
+			// keep after the same target
+			command.Target, command.HasTarget = fleet.Target, true
+		}
+		sm.GetSender().Send(ecstypes.ComponentMessage{
+			Entity:  comp.Entity,
+			Payload: command,
+		})
+	}
+	comp.SquadKeys = held
 	return comp, nil
 }
 func (comp Player) SystemID() ecstypes.SystemID {
@@ -229,9 +294,9 @@ func (comp Player) SystemID() ecstypes.SystemID {
 
 type SyncReceiver struct {
 	Entity   ecstypes.EntityID
-	Input    chan SyncInput
-	Motion   *Motion
-	Position *Position
+	Input    chan SyncInput `json:"-"`
+	Motion   *Motion        `json:"-"`
+	Position *Position      `json:"-"`
 }
 
 func (comp SyncReceiver) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
@@ -253,10 +318,11 @@ func (comp SyncReceiver) Update(sm ecstypes.SystemManager) (SyncReceiver, error)
 	for done := false; !done; {
 		select {
 		case input := <-comp.Input:
-			var delta = input.Velocity.Add(geom.Vector{
-				X: (input.Position.X - comp.Position.X) / 3,
-				Y: (input.Position.Y - comp.Position.Y) / 3,
-			})
+			// correct a third of the way to the server position each tick,
+			// the short way round, so a ship crossing an edge isn't pulled
+			// back across the screen
+			var correction = sm.GetWorld().Delta(comp.Position.Vector, input.Position)
+			var delta = input.Velocity.Add(correction.Multiply(1 / (3 * sm.GetTimeStep())))
 			comp.Motion.Velocity = delta
 			comp.Position.Angle = input.Angle
 		default:
@@ -271,8 +337,8 @@ func (comp SyncReceiver) SystemID() ecstypes.SystemID {
 
 type SyncSender struct {
 	Entity   ecstypes.EntityID
-	Motion   *Motion
-	Position *Position
+	Motion   *Motion   `json:"-"`
+	Position *Position `json:"-"`
 }
 
 func (comp SyncSender) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
@@ -0,0 +1,201 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+	"math"
+)
+
+// ShieldFacing is one of the four arcs a ship's shields are divided into.
+type ShieldFacing int
+
+const (
+	ShieldFore ShieldFacing = iota
+	ShieldAft
+	ShieldPort
+	ShieldStarboard
+)
+
+// subsystemDamage is how much harder hits strike subsystems than the hull:
+// a hit taking a tenth of the hull's integrity takes a fifth of a subsystem.
+const subsystemDamage = 2
+
+// Shields absorb damage before it reaches the Hull, each facing from its own
+// Strength. Facings recharge at Regen per second once Delay seconds have
+// passed since the last hit. A ship added with no Strength at all starts
+// fully charged.
+type Shields struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+
+	Strength [4]float64
+	Max      float64
+	Regen    float64
+	Delay    float64
+	SinceHit float64
+}
+
+func (comp Shields) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if comp.Strength == [4]float64{} {
+		comp.Strength = [4]float64{comp.Max, comp.Max, comp.Max, comp.Max}
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding shields: %w", err)
+	}
+	return nil
+}
+func (comp Shields) Update(sm ecstypes.SystemManager) (Shields, error) {
+	var dt = sm.GetTimeStep()
+	comp.SinceHit += dt
+	if comp.SinceHit >= comp.Delay {
+		for facing := range comp.Strength {
+			power, err := drawPower(sm, comp.Entity, PowerShields, min(comp.Regen*dt, comp.Max-comp.Strength[facing]))
+			if err != nil {
+				return comp, err
+			}
+			comp.Strength[facing] += power
+		}
+	}
+	return comp, nil
+}
+func (comp Shields) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemShields
+}
+
+// Facing returns which facing a hit at point, in the world, strikes: the
+// ship's bow and stern take the 90° arcs ahead and behind, and the sides
+// the rest.
+func (comp *Shields) Facing(world geom.Torus, point geom.Vector) ShieldFacing {
+	var local = world.Delta(comp.Position.Vector, point).Rotate(-comp.Position.Angle)
+	var bearing = math.Abs(float64(local.Angle()))
+	switch {
+	case bearing <= math.Pi/4:
+		return ShieldFore
+	case bearing >= 3*math.Pi/4:
+		return ShieldAft
+	case local.Y > 0:
+		// y points down the screen, so with the bow along x, +y is to the
+		// right
+		return ShieldStarboard
+	default:
+		return ShieldPort
+	}
+}
+
+// Absorb takes what it can of amount from the facing hit and returns the
+// rest.
+func (comp *Shields) Absorb(facing ShieldFacing, amount float64) float64 {
+	var absorbed = min(comp.Strength[facing], amount)
+	comp.Strength[facing] -= absorbed
+	comp.SinceHit = 0
+	return amount - absorbed
+}
+
+// Hull is the structure of a ship. Hits that get through the Shields reduce
+// Integrity and damage the subsystem behind the facing hit: the engines aft,
+// which lowers thrust, and the helm thrusters on either side, which lowers
+// the turn rate. EngineDamage and HelmDamage run from 0, intact, to 1, out
+// of action.
+//
+// At zero Integrity the ship is destroyed: Destroyed is broadcast and the
+// entity is despawned. A Hull added with no Integrity starts intact, and a
+// zero MaxIntegrity means 100.
+type Hull struct {
+	Entity ecstypes.EntityID
+
+	Integrity    float64
+	MaxIntegrity float64
+	EngineDamage float64
+	HelmDamage   float64
+	Destroyed    bool
+}
+
+func (comp Hull) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	comp.Entity = entity
+	if comp.MaxIntegrity == 0 {
+		comp.MaxIntegrity = 100
+	}
+	if comp.Integrity == 0 && !comp.Destroyed {
+		comp.Integrity = comp.MaxIntegrity
+	}
+	if err := sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding hull: %w", err)
+	}
+	return nil
+}
+func (comp Hull) Update(_ ecstypes.SystemManager) (Hull, error) {
+	return comp, nil
+}
+func (comp Hull) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemHull
+}
+
+// ThrustFactor and TurnFactor scale a ship's thrust and turn rate for the
+// damage to its engines and helm.
+func (comp *Hull) ThrustFactor() float64 {
+	return 1 - comp.EngineDamage
+}
+
+func (comp *Hull) TurnFactor() float64 {
+	return 1 - comp.HelmDamage
+}
+
+// Destroyed is broadcast when a Hull is destroyed, just before its entity is
+// despawned. Source is the entity that dealt the final blow.
+type Destroyed struct {
+	Source   ecstypes.EntityID
+	Position geom.Vector
+}
+
+// applyDamage deals this tick's damage in the order it was dealt: through
+// the facing of the Shields hit, then to the Hull and the subsystem behind
+// that facing.
+func (i *Instance) applyDamage() {
+	for _, damage := range i.Damage {
+		if warp, ok := i.Warp.GetComponent(damage.Target); ok {
+			warp.Drop(i)
+		}
+		hull, ok := i.Hull.GetComponent(damage.Target)
+		if !ok || hull.Destroyed {
+			continue
+		}
+		var amount = damage.Amount
+		var facing = ShieldFore
+		if shields, ok := i.Shields.GetComponent(damage.Target); ok {
+			facing = shields.Facing(i.World, damage.Point)
+			amount = shields.Absorb(facing, amount)
+		} else if position, ok := i.Position.GetComponent(damage.Target); ok {
+			facing = (&Shields{Position: position}).Facing(i.World, damage.Point)
+		}
+		if amount <= 0 {
+			continue
+		}
+
+		hull.Integrity -= amount
+		var struck = amount / hull.MaxIntegrity * subsystemDamage
+		switch facing {
+		case ShieldAft:
+			hull.EngineDamage = min(1, hull.EngineDamage+struck)
+		case ShieldPort, ShieldStarboard:
+			hull.HelmDamage = min(1, hull.HelmDamage+struck)
+		}
+
+		if hull.Integrity <= 0 {
+			hull.Integrity = 0
+			hull.Destroyed = true
+			var destroyed = Destroyed{Source: damage.Source}
+			if position, ok := i.Position.GetComponent(damage.Target); ok {
+				destroyed.Position = position.Vector
+			}
+			i.Broadcast(ecstypes.ComponentMessage{Entity: damage.Target, Payload: destroyed})
+			i.Despawn(damage.Target)
+		}
+	}
+}
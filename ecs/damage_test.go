@@ -0,0 +1,126 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"testing"
+)
+
+func TestShieldFacing(t *testing.T) {
+	var world = geom.Torus{Width: 1000, Height: 1000}
+	var center = geom.Vector{X: 500, Y: 500}
+	var tests = []struct {
+		name  string
+		at    geom.Vector
+		angle geom.Angle
+		point geom.Vector
+		want  ShieldFacing
+	}{
+		{"ahead", center, 0, geom.Vector{X: 520, Y: 505}, ShieldFore},
+		{"behind", center, 0, geom.Vector{X: 480, Y: 495}, ShieldAft},
+		{"right", center, 0, geom.Vector{X: 505, Y: 520}, ShieldStarboard},
+		{"left", center, 0, geom.Vector{X: 495, Y: 480}, ShieldPort},
+		{"ahead facing down", center, math.Pi / 2, geom.Vector{X: 500, Y: 520}, ShieldFore},
+		{"left facing down", center, math.Pi / 2, geom.Vector{X: 520, Y: 500}, ShieldPort},
+		{"ahead across the edge", geom.Vector{X: 5, Y: 500}, math.Pi, geom.Vector{X: 990, Y: 500}, ShieldFore},
+	}
+	for _, test := range tests {
+		var shields = Shields{Position: &Position{Vector: test.at, Angle: test.angle}}
+		if got := shields.Facing(world, test.point); got != test.want {
+			t.Errorf("%s: facing %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestApplyDamage(t *testing.T) {
+	// hits on a ship at (500, 500) facing along +x
+	var ahead, behind, beside = geom.Vector{X: 520, Y: 500}, geom.Vector{X: 480, Y: 500}, geom.Vector{X: 500, Y: 520}
+	var tests = []struct {
+		name      string
+		shields   bool
+		hits      []ecstypes.Damage
+		strength  [4]float64
+		integrity float64
+		engine    float64
+		helm      float64
+	}{
+		{"absorbed", true, []ecstypes.Damage{{Amount: 30, Point: ahead}}, [4]float64{20, 50, 50, 50}, 100, 0, 0},
+		{"only the facing hit", true, []ecstypes.Damage{{Amount: 30, Point: ahead}, {Amount: 30, Point: ahead}}, [4]float64{0, 50, 50, 50}, 90, 0, 0},
+		{"through to the engines", true, []ecstypes.Damage{{Amount: 60, Point: behind}}, [4]float64{50, 0, 50, 50}, 90, 0.2, 0},
+		{"no shields", false, []ecstypes.Damage{{Amount: 25, Point: beside}}, [4]float64{}, 75, 0, 0.5},
+	}
+	for _, test := range tests {
+		var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+		var components = []ecstypes.Component{&Position{Vector: geom.Vector{X: 500, Y: 500}}, new(Hull)}
+		if test.shields {
+			components = append(components, &Shields{Max: 50, Regen: 10, Delay: 1})
+		}
+		if err := instance.AddEntity(0, components...); err != nil {
+			t.Fatal(err)
+		}
+		for _, hit := range test.hits {
+			hit.Target = 0
+			instance.ApplyDamage(hit)
+		}
+		instance.applyDamage()
+
+		if shields, ok := instance.Shields.GetComponent(0); ok && shields.Strength != test.strength {
+			t.Errorf("%s: shields %v, want %v", test.name, shields.Strength, test.strength)
+		}
+		var hull, _ = instance.Hull.GetComponent(0)
+		if !near(hull.Integrity, test.integrity) || !near(hull.EngineDamage, test.engine) || !near(hull.HelmDamage, test.helm) {
+			t.Errorf("%s: hull %+v, want integrity %v, engines %v and helm %v", test.name, *hull, test.integrity, test.engine, test.helm)
+		}
+	}
+}
+
+func TestShieldsRecharge(t *testing.T) {
+	var shields = Shields{Strength: [4]float64{0, 50, 50, 50}, Max: 50, Regen: 30, Delay: 1}
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	for tick := 1; tick < DefaultTickRate; tick++ {
+		shields, _ = shields.Update(instance)
+	}
+	if shields.Strength[ShieldFore] != 0 {
+		t.Fatalf("recharged to %v before the delay", shields.Strength[ShieldFore])
+	}
+	for tick := 0; tick < DefaultTickRate; tick++ {
+		shields, _ = shields.Update(instance)
+	}
+	if got := shields.Strength[ShieldFore]; got < 25 || got > 35 {
+		t.Fatalf("recharged to %v a second after the delay, want about 30", got)
+	}
+	if shields.Strength[ShieldAft] != 50 {
+		t.Fatalf("recharged past Max to %v", shields.Strength[ShieldAft])
+	}
+}
+
+func TestDestroyed(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	var sent = NewPipe()
+	instance.SetSender(sent)
+	if err := instance.AddEntity(0, &Position{Vector: geom.Vector{X: 500, Y: 500}}, &Hull{MaxIntegrity: 20}); err != nil {
+		t.Fatal(err)
+	}
+	instance.ApplyDamage(ecstypes.Damage{Target: 0, Source: 7, Amount: 15})
+	instance.ApplyDamage(ecstypes.Damage{Target: 0, Source: 8, Amount: 15})
+	// a third hit on the wreck changes nothing
+	instance.ApplyDamage(ecstypes.Damage{Target: 0, Source: 9, Amount: 15})
+	instance.applyDamage()
+	if err := instance.flushBroadcast(); err != nil {
+		t.Fatal(err)
+	}
+	var msgs = drain(sent)
+	if len(msgs) != 2 {
+		t.Fatalf("sent %+v, want destroyed and despawned", msgs)
+	}
+	if destroyed, ok := msgs[0].Payload.(Destroyed); !ok || destroyed.Source != 8 || destroyed.Position != (geom.Vector{X: 500, Y: 500}) {
+		t.Errorf("sent %+v, want destroyed by 8", msgs[0])
+	}
+	if _, ok := msgs[1].Payload.(Despawned); !ok {
+		t.Errorf("sent %+v, want despawned", msgs[1])
+	}
+	if _, ok := instance.Hull.GetComponent(0); ok {
+		t.Error("destroyed ship wasn't removed")
+	}
+}
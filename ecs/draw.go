@@ -0,0 +1,174 @@
+//go:build !headless
+
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/constants"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/globals"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"image/color"
+	"time"
+)
+
+// Drawing and the keyboard need ebiten, and a display to run it. Build with
+// the headless tag to simulate without them, as a server or test does.
+
+var beamColor = color.RGBA{R: 0xff, G: 0x80, B: 0x20, A: 0xff}
+
+// edgeColor marks the edges of a world larger than the screen.
+var edgeColor = color.RGBA{R: 0x40, G: 0x40, B: 0x60, A: 0xff}
+
+// spriteMesh is the triangles a Sprite was last drawn with, kept to reuse
+// their storage.
+type spriteMesh struct {
+	Vertices []ebiten.Vertex
+	Indices  []uint16
+}
+
+// Draw draws the ship alpha of the way between its previous and current pose,
+// as seen with the world point origin at the top left of the screen.
+func (comp *Sprite) Draw(screen *ebiten.Image, world geom.Torus, origin geom.Vector, alpha float64, aa bool, line bool) {
+	var path vector.Path
+
+	// Draw the outline, again on the far side of any edge it straddles
+	outline := comp.Outline
+	if len(outline) == 0 {
+		outline = ShipOutline
+	}
+	var radius float64
+	for _, p := range outline {
+		radius = max(radius, p.Length())
+	}
+	center, angle := comp.Position.Interpolate(world, alpha)
+	center = world.Wrap(center.Sub(origin))
+	for _, position := range world.Ghosts(center, radius) {
+		for index, p := range outline.Transform(geom.Pose(position, angle)) {
+			if index == 0 {
+				path.MoveTo(float32(p.X), float32(p.Y))
+			} else {
+				path.LineTo(float32(p.X), float32(p.Y))
+			}
+		}
+		path.Close()
+	}
+
+	if line {
+		op := &vector.StrokeOptions{}
+		op.Width = 2
+		op.LineJoin = vector.LineJoinRound
+		comp.Vertices, comp.Indices = path.AppendVerticesAndIndicesForStroke(comp.Vertices[:0], comp.Indices[:0], op)
+	} else {
+		comp.Vertices, comp.Indices = path.AppendVerticesAndIndicesForFilling(comp.Vertices[:0], comp.Indices[:0])
+	}
+
+	var tint = comp.Color
+	if tint == (color.RGBA{}) {
+		tint = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	}
+	for i := range comp.Vertices {
+		comp.Vertices[i].SrcX = 1
+		comp.Vertices[i].SrcY = 1
+		comp.Vertices[i].ColorR = float32(tint.R) / 0xff
+		comp.Vertices[i].ColorG = float32(tint.G) / 0xff
+		comp.Vertices[i].ColorB = float32(tint.B) / 0xff
+		comp.Vertices[i].ColorA = float32(tint.A) / 0xff
+	}
+
+	op := &ebiten.DrawTrianglesOptions{}
+	op.AntiAlias = aa
+	op.FillRule = ebiten.FillRuleNonZero
+	screen.DrawTriangles(comp.Vertices, comp.Indices, globals.WhiteSubImage, op)
+}
+
+// Draw draws every Sprite interpolated between the last two ticks by the
+// Clock's alpha, so motion is smooth at frame rates above the tick rate. A
+// world larger than the screen is seen through a camera following the
+// player's ship, with the world's edges drawn in.
+func (i *Instance) Draw(screen *ebiten.Image) {
+	var alpha = i.Clock.Alpha(time.Now())
+	var origin, follow = i.camera(alpha)
+	if follow {
+		var edge = i.World.Wrap(origin.Negate())
+		vector.StrokeLine(screen, float32(edge.X), 0, float32(edge.X), constants.ScreenHeight, 1, edgeColor, false)
+		vector.StrokeLine(screen, 0, float32(edge.Y), constants.ScreenWidth, float32(edge.Y), 1, edgeColor, false)
+	}
+	i.Sprite.doIterate(func(sprite Sprite) (Sprite, error) {
+		sprite.Draw(screen, i.World, origin, alpha, false, false)
+		return sprite, nil
+	})
+	for _, beam := range i.Beams {
+		// drawn from both ends, so a beam across an edge shows on each side
+		var from, to = i.World.Wrap(beam.From.Sub(origin)), i.World.Wrap(beam.To.Sub(origin))
+		var delta = i.World.Delta(beam.From, beam.To)
+		for _, line := range [][2]geom.Vector{{from, from.Add(delta)}, {to.Sub(delta), to}} {
+			vector.StrokeLine(screen, float32(line[0].X), float32(line[0].Y), float32(line[1].X), float32(line[1].Y), 2, beamColor, true)
+		}
+	}
+	i.Player.EachSorted(func(e ecstypes.EntityID, _ *Player) {
+		var status = fmt.Sprintf("SECTOR %d,%d", i.Sector.X, i.Sector.Y)
+		if warp, ok := i.Warp.GetComponent(e); ok {
+			status += "\n" + warp.String()
+		}
+		if fleet, ok := i.Fleet.GetComponent(e); ok {
+			status += "\n" + fleet.String()
+		}
+		ebitenutil.DebugPrintAt(screen, status, 0, 0)
+	})
+}
+
+// KeyboardHelmInput reads the arrow keys, Space for phasers, Enter for
+// torpedoes and W for warp.
+func KeyboardHelmInput() HelmInput {
+	var shipInput HelmInput
+	if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
+		shipInput.Left = true
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
+		shipInput.Right = true
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
+		shipInput.Thrust = true
+	}
+	if ebiten.IsKeyPressed(ebiten.KeySpace) {
+		shipInput.FirePhaser = true
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyEnter) {
+		shipInput.FireTorpedo = true
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyW) {
+		shipInput.Warp = true
+	}
+	return shipInput
+}
+
+// PowerKeys are the keys that shift power to engines, shields, weapons and
+// sensors in turn, and the one that splits it evenly again.
+var PowerKeys = [5]ebiten.Key{ebiten.Key1, ebiten.Key2, ebiten.Key3, ebiten.Key4, ebiten.Key0}
+
+// KeyboardPowerKeys reads which of the PowerKeys are held.
+func KeyboardPowerKeys() [5]bool {
+	var held [5]bool
+	for index, key := range PowerKeys {
+		held[index] = ebiten.IsKeyPressed(key)
+	}
+	return held
+}
+
+// SquadKeys are the keys that order the player's fleet to follow, hold,
+// attack the nearest ship and return to base, and the one that changes its
+// formation.
+var SquadKeys = [5]ebiten.Key{ebiten.KeyF, ebiten.KeyH, ebiten.KeyA, ebiten.KeyB, ebiten.KeyV}
+
+// KeyboardSquadKeys reads which of the SquadKeys are held.
+func KeyboardSquadKeys() [5]bool {
+	var held [5]bool
+	for index, key := range SquadKeys {
+		held[index] = ebiten.IsKeyPressed(key)
+	}
+	return held
+}
@@ -0,0 +1,18 @@
+//go:build headless
+
+package ecs
+
+// Without ebiten there is nothing to draw with and no keyboard: nothing is
+// ever held.
+
+type spriteMesh struct{}
+
+func KeyboardHelmInput() HelmInput {
+	return HelmInput{}
+}
+func KeyboardPowerKeys() [5]bool {
+	return [5]bool{}
+}
+func KeyboardSquadKeys() [5]bool {
+	return [5]bool{}
+}
@@ -1,13 +1,19 @@
 package ecs
 
-import "github.com/StCredZero/vectrek/geom"
+import (
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+)
 
 // Entity represents the player's spaceship with position, rotation, and movement
 
 type HelmInput struct {
-	Left   bool
-	Right  bool
-	Thrust bool
+	Left        bool
+	Right       bool
+	Thrust      bool
+	FirePhaser  bool
+	FireTorpedo bool
+	Warp        bool
 }
 
 type SyncInput struct {
@@ -16,8 +22,18 @@ type SyncInput struct {
 	Angle    geom.Angle
 }
 
-// Entity thrust
+// Despawned is broadcast when an entity is removed.
+type Despawned struct{}
+
+// RollbackInput is a peer's HelmInput for one frame of a Rollback session.
+type RollbackInput struct {
+	Frame uint64
+	Input HelmInput
+}
+
+// Entity thrust (pixels/s²), top speed (pixels/s) and turning (radians/s)
 const (
-	ThrustAccel = 0.2
-	MaxVelocity = 5.0
+	ThrustAccel = 720.0
+	MaxVelocity = 300.0
+	TurnRate    = math.Pi
 )
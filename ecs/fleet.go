@@ -0,0 +1,311 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+	"math"
+)
+
+// Formation is how a fleet's wingmen are arranged round their leader.
+type Formation uint8
+
+const (
+	// FormationLine puts wingmen abreast of the leader, alternately to
+	// either side.
+	FormationLine Formation = iota
+	// FormationWedge puts wingmen back and to either side of the leader.
+	FormationWedge
+	// FormationRing spaces wingmen evenly on a circle round the leader.
+	FormationRing
+
+	formationCount
+)
+
+func (formation Formation) String() string {
+	switch formation {
+	case FormationLine:
+		return "LINE"
+	case FormationWedge:
+		return "WEDGE"
+	case FormationRing:
+		return "RING"
+	}
+	return fmt.Sprintf("Formation(%d)", uint8(formation))
+}
+
+// Next returns the formation after this one, round to the first.
+func (formation Formation) Next() Formation {
+	return (formation + 1) % formationCount
+}
+
+// Slot returns where the index'th of count wingmen flies, in the leader's
+// frame: x ahead and y to the right.
+func (formation Formation) Slot(index, count int, spacing float64) geom.Vector {
+	var rank, side = float64(index/2 + 1), 1.0
+	if index%2 == 1 {
+		side = -1
+	}
+	switch formation {
+	case FormationWedge:
+		return geom.Vector{X: -rank * spacing, Y: side * rank * spacing}
+	case FormationRing:
+		return geom.Vector{X: 1.5 * spacing}.Rotate(geom.Angle(2 * math.Pi * float64(index) / float64(count)))
+	}
+	return geom.Vector{Y: side * rank * spacing}
+}
+
+// SquadOrder is what a fleet's wingmen are told to do.
+type SquadOrder uint8
+
+const (
+	// OrderFollow keeps formation on the leader.
+	OrderFollow SquadOrder = iota
+	// OrderHold keeps formation where the leader was when ordered.
+	OrderHold
+	// OrderAttack goes after the fleet's Target, and follows again once
+	// it's gone.
+	OrderAttack
+	// OrderReturn keeps formation at the fleet's Base.
+	OrderReturn
+)
+
+func (order SquadOrder) String() string {
+	switch order {
+	case OrderFollow:
+		return "FOLLOW"
+	case OrderHold:
+		return "HOLD"
+	case OrderAttack:
+		return "ATTACK"
+	case OrderReturn:
+		return "RETURN"
+	}
+	return fmt.Sprintf("SquadOrder(%d)", uint8(order))
+}
+
+// squadAttackRange is how far from its leader a fleet looks for a target
+// when told to attack without one.
+const squadAttackRange = 1000.0
+
+// SquadCommand is a player ordering their fleet. Without a target, attack
+// goes after the nearest ship outside the fleet.
+type SquadCommand struct {
+	Order     SquadOrder
+	Formation Formation
+	Target    ecstypes.EntityID
+	HasTarget bool
+}
+
+// SquadOrders are the orders a fleet is under: where it holds or returns to
+// is Point, facing Angle.
+type SquadOrders struct {
+	Order     SquadOrder
+	Formation Formation
+	Target    ecstypes.EntityID
+	Point     geom.Vector
+	Angle     geom.Angle
+}
+
+// Fleet makes a ship the leader of the ships whose Wingman names it.
+// Wingmen take slots in the orders' Formation Spacing apart, in entity
+// order, and carry them out. Base is where the fleet was added, unless set.
+type Fleet struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+
+	Spacing float64
+	Base    geom.Vector
+	SquadOrders
+}
+
+func (comp Fleet) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if comp.Spacing == 0 {
+		comp.Spacing = 80
+	}
+	if comp.Base == (geom.Vector{}) {
+		comp.Base = comp.Position.Vector
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding fleet: %w", err)
+	}
+	return nil
+}
+func (comp Fleet) Update(_ ecstypes.SystemManager) (Fleet, error) {
+	return comp, nil
+}
+func (comp Fleet) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemFleet
+}
+
+// String describes the fleet for the HUD.
+func (comp *Fleet) String() string {
+	return fmt.Sprintf("FLEET %s %s", comp.Formation, comp.Order)
+}
+
+// Wingman puts a ship in the Fleet of Leader, flying its AI. When the leader
+// is removed the wingman breaks formation: its Wingman component is removed
+// and its AI left idle.
+type Wingman struct {
+	Entity ecstypes.EntityID
+	AI     *AI `json:"-"`
+
+	Leader ecstypes.EntityID
+}
+
+func (comp Wingman) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Leader == entity {
+		return fmt.Errorf("entity %d can't be its own wingman: %w", entity, ErrType)
+	}
+	if comp.AI, err = GetComponent[AI](sm, entity); comp.AI == nil {
+		return fmt.Errorf("no AI found: %w", vterr.ErrMissing)
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding wingman: %w", err)
+	}
+	return nil
+}
+func (comp Wingman) Update(_ ecstypes.SystemManager) (Wingman, error) {
+	return comp, nil
+}
+func (comp Wingman) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemWingman
+}
+
+// commandSquad turns a player's command into orders for their fleet. Only a
+// player's ship, which the server replicates with a SyncSender, can be
+// commanded: NPC fleets follow their leader's Brain.
+func (i *Instance) commandSquad(leader ecstypes.EntityID, command SquadCommand) {
+	fleet, ok := i.Fleet.GetComponent(leader)
+	if !ok {
+		return
+	}
+	if _, ok := i.SyncSender.GetComponent(leader); !ok {
+		return
+	}
+	var orders = SquadOrders{
+		Order:     command.Order,
+		Formation: command.Formation % formationCount,
+		Point:     fleet.Position.Vector,
+		Angle:     fleet.Position.Angle,
+	}
+	switch command.Order {
+	case OrderAttack:
+		if _, ok := i.Position.GetComponent(command.Target); command.HasTarget && ok {
+			orders.Target = command.Target
+		} else if orders.Target, ok = i.squadTarget(leader); !ok {
+			return
+		}
+	case OrderReturn:
+		orders.Point = fleet.Base
+	}
+	i.Broadcast(ecstypes.ComponentMessage{Entity: leader, Payload: orders})
+}
+
+// squadTarget returns the nearest ship to leader within squadAttackRange, as
+// the leader's sensors see it, that isn't in its fleet or on its team.
+func (i *Instance) squadTarget(leader ecstypes.EntityID) (ecstypes.EntityID, bool) {
+	here, ok := i.Position.GetComponent(leader)
+	if !ok {
+		return 0, false
+	}
+	var team = i.team(leader)
+	var nearest ecstypes.EntityID
+	var found bool
+	var reach = i.sensorRange(leader, squadAttackRange)
+	i.Helm.EachSorted(func(e ecstypes.EntityID, helm *Helm) {
+		if e == leader || i.leaderOf(e) == leader || i.team(e) == team {
+			return
+		}
+		var distance = i.World.Delta(here.Vector, helm.Position.Vector).Length()
+		if distance <= reach {
+			nearest, found, reach = e, true, distance
+		}
+	})
+	return nearest, found
+}
+
+// team returns the team of e: its Brain's, or its leader's if it's a
+// wingman, or 0.
+func (i *Instance) team(e ecstypes.EntityID) uint32 {
+	if brain, ok := i.Brain.GetComponent(i.leaderOf(e)); ok {
+		return brain.Team
+	}
+	return 0
+}
+
+// leaderOf returns the leader of e's fleet: e itself if it isn't a wingman.
+func (i *Instance) leaderOf(e ecstypes.EntityID) ecstypes.EntityID {
+	if wingman, ok := i.Wingman.GetComponent(e); ok {
+		return wingman.Leader
+	}
+	return e
+}
+
+// flyFormations gives every wingman's AI its orders for this tick, which
+// override any Brain's.
+func (i *Instance) flyFormations() {
+	var counts = make(map[ecstypes.EntityID]int)
+	i.Wingman.EachSorted(func(_ ecstypes.EntityID, wingman *Wingman) {
+		counts[wingman.Leader]++
+	})
+	var slots = make(map[ecstypes.EntityID]int)
+	var orphans []ecstypes.EntityID
+	i.Wingman.EachSorted(func(e ecstypes.EntityID, wingman *Wingman) {
+		var ai = wingman.AI
+		var slot = slots[wingman.Leader]
+		slots[wingman.Leader]++
+		fleet, ok := i.Fleet.GetComponent(wingman.Leader)
+		if !ok {
+			orphans = append(orphans, e)
+			ai.Order(AI{})
+			return
+		}
+		var orders AI
+		var order, target = fleet.Order, fleet.Target
+		if order == OrderAttack {
+			if _, ok := i.Position.GetComponent(target); !ok {
+				// the target's gone: back to the leader, once
+				fleet.Order = OrderFollow
+				i.Broadcast(ecstypes.ComponentMessage{Entity: fleet.Entity, Payload: fleet.SquadOrders})
+				order = OrderFollow
+			}
+		}
+		if leaderAI, ok := i.AI.GetComponent(fleet.Entity); ok && order == OrderFollow && leaderAI.WeaponsFree && leaderAI.HasTarget {
+			// an NPC leader's fight is its wingmen's
+			order, target = OrderAttack, leaderAI.Target
+		}
+		switch order {
+		case OrderAttack:
+			orders.Steering, orders.Target, orders.HasTarget, orders.WeaponsFree = SteerPursue, target, true, true
+			if position, ok := i.Position.GetComponent(target); ok &&
+				i.World.Delta(ai.Position.Vector, position.Vector).Length() < 3*fleet.Spacing {
+				orders.Steering, orders.Radius = SteerOrbit, 1.5*fleet.Spacing
+			}
+		case OrderFollow:
+			var pose = geom.Pose(fleet.Position.Vector, fleet.Position.Angle)
+			orders.Steering, orders.Radius = SteerArrive, fleet.Spacing
+			orders.Point = pose.Apply(fleet.Formation.Slot(slot, counts[fleet.Entity], fleet.Spacing))
+			if motion, ok := i.Motion.GetComponent(fleet.Entity); ok {
+				orders.Match = motion.Velocity
+			}
+			orders.Point = i.World.Wrap(orders.Point)
+		default:
+			var pose = geom.Pose(fleet.Point, fleet.Angle)
+			orders.Steering, orders.Radius = SteerArrive, fleet.Spacing
+			orders.Point = i.World.Wrap(pose.Apply(fleet.Formation.Slot(slot, counts[fleet.Entity], fleet.Spacing)))
+		}
+		ai.Order(orders)
+	})
+	for _, e := range orphans {
+		i.Wingman.RemoveComponent(e)
+	}
+}
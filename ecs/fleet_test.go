@@ -0,0 +1,157 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"testing"
+)
+
+// newFleet returns an Instance with the stock ship classes and behaviors, a
+// player's cruiser leading a fleet at (2000, 2000), and its two scout
+// wingmen behind it. Set player false and the cruiser is an NPC's.
+func newFleet(t *testing.T, player bool) (instance *Instance, leader ecstypes.EntityID, wingmen []ecstypes.EntityID) {
+	t.Helper()
+	instance, _ = newBehaviorRange(t)
+	instance.SetSender(Discard{})
+	var extra = []ecstypes.Component{&Fleet{Base: geom.Vector{X: 2500, Y: 2000}}}
+	if player {
+		extra = append(extra, new(SyncSender))
+	}
+	leader, err := instance.Spawn("cruiser", Position{Vector: geom.Vector{X: 2000, Y: 2000}}, extra...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 2; n++ {
+		var at = geom.Vector{X: 1900, Y: 1950 + 100*float64(n)}
+		wingman, err := instance.Spawn("scout", Position{Vector: at}, &AI{}, &Wingman{Leader: leader})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wingmen = append(wingmen, wingman)
+	}
+	return instance, leader, wingmen
+}
+
+func TestFormationSlot(t *testing.T) {
+	var tests = []struct {
+		formation    Formation
+		index, count int
+		want         geom.Vector
+	}{
+		{FormationLine, 0, 3, geom.Vector{Y: 80}},
+		{FormationLine, 1, 3, geom.Vector{Y: -80}},
+		{FormationLine, 2, 3, geom.Vector{Y: 160}},
+		{FormationWedge, 0, 4, geom.Vector{X: -80, Y: 80}},
+		{FormationWedge, 3, 4, geom.Vector{X: -160, Y: -160}},
+		{FormationRing, 0, 4, geom.Vector{X: 120}},
+		{FormationRing, 2, 4, geom.Vector{X: -120}},
+	}
+	for _, test := range tests {
+		var got = test.formation.Slot(test.index, test.count, 80)
+		if !near(got.X, test.want.X) || !near(got.Y, test.want.Y) {
+			t.Errorf("%s slot %d of %d at %v, want %v", test.formation, test.index, test.count, got, test.want)
+		}
+	}
+	if FormationRing.Next() != FormationLine {
+		t.Errorf("after RING comes %s", FormationRing.Next())
+	}
+}
+
+func TestSquadOrders(t *testing.T) {
+	var instance, leader, wingmen = newFleet(t, true)
+	var enemy, err = instance.Spawn("scout", Position{Vector: geom.Vector{X: 2600, Y: 2000}}, &AI{}, &Brain{Tree: "sentry", Team: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// orders are broadcast at the end of the tick, and flown the next
+	var command = func(command SquadCommand) (*Fleet, *AI) {
+		t.Helper()
+		if err := instance.Step([]ecstypes.ComponentMessage{{Entity: leader, Payload: command}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := instance.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		var fleet, _ = instance.Fleet.GetComponent(leader)
+		var wingman, _ = instance.Wingman.GetComponent(wingmen[0])
+		return fleet, wingman.AI
+	}
+
+	var fleet, ai = command(SquadCommand{Order: OrderHold, Formation: FormationWedge})
+	if fleet.Order != OrderHold || fleet.Formation != FormationWedge || fleet.Point != (geom.Vector{X: 2000, Y: 2000}) {
+		t.Errorf("holding with orders %+v", fleet.SquadOrders)
+	}
+	if ai.Steering != SteerArrive || ai.Point != (geom.Vector{X: 1920, Y: 2080}) {
+		t.Errorf("holding with %+v, want to arrive at the first wedge slot", *ai)
+	}
+
+	fleet, ai = command(SquadCommand{Order: OrderReturn})
+	if fleet.Order != OrderReturn || fleet.Point != fleet.Base || ai.Point != (geom.Vector{X: 2500, Y: 2080}) {
+		t.Errorf("returning with orders %+v and %+v", fleet.SquadOrders, *ai)
+	}
+
+	// the wingmen are nearer, but on the fleet's side
+	fleet, ai = command(SquadCommand{Order: OrderAttack})
+	if fleet.Order != OrderAttack || fleet.Target != enemy {
+		t.Errorf("attacking with orders %+v, want the enemy targeted", fleet.SquadOrders)
+	}
+	if !ai.HasTarget || ai.Target != enemy || !ai.WeaponsFree {
+		t.Errorf("attacking with %+v", *ai)
+	}
+
+	instance.RemoveEntity(enemy)
+	fleet, ai = command(SquadCommand{Order: OrderAttack})
+	if fleet.Order != OrderFollow || ai.HasTarget || ai.Steering != SteerArrive {
+		t.Errorf("with nothing left to attack, orders %+v and %+v", fleet.SquadOrders, *ai)
+	}
+}
+
+func TestNPCFleetIgnoresCommands(t *testing.T) {
+	var instance, leader, _ = newFleet(t, false)
+	var command = SquadCommand{Order: OrderHold, Formation: FormationRing}
+	for tick := 0; tick < 2; tick++ {
+		if err := instance.Step([]ecstypes.ComponentMessage{{Entity: leader, Payload: command}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fleet, _ := instance.Fleet.GetComponent(leader); fleet.SquadOrders != (SquadOrders{}) {
+		t.Errorf("an NPC fleet took orders %+v", fleet.SquadOrders)
+	}
+}
+
+func TestWingmenFollow(t *testing.T) {
+	var instance, leader, wingmen = newFleet(t, true)
+	var motion, _ = instance.Motion.GetComponent(leader)
+	motion.Velocity = geom.Vector{X: 100}
+	for tick := 0; tick < 10*DefaultTickRate; tick++ {
+		if err := instance.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var at, _ = instance.Position.GetComponent(leader)
+	var pose = geom.Pose(at.Vector, at.Angle)
+	for n, e := range wingmen {
+		var position, _ = instance.Position.GetComponent(e)
+		var slot = pose.Apply(FormationLine.Slot(n, len(wingmen), 80))
+		if distance := instance.GetWorld().Distance(position.Vector, slot); distance > 40 {
+			t.Errorf("wingman %d %v from its slot", n, distance)
+		}
+		var motion, _ = instance.Motion.GetComponent(e)
+		if speed := motion.Velocity.Sub(geom.Vector{X: 100}).Length(); speed > 40 {
+			t.Errorf("wingman %d moving %v relative to the leader", n, speed)
+		}
+	}
+
+	instance.RemoveEntity(leader)
+	if err := instance.Step(nil); err != nil {
+		t.Fatal(err)
+	}
+	for n, e := range wingmen {
+		if _, ok := instance.Wingman.GetComponent(e); ok {
+			t.Errorf("wingman %d still in formation without a leader", n)
+		}
+		if ai, _ := instance.AI.GetComponent(e); ai.Steering != SteerIdle {
+			t.Errorf("wingman %d still steering %v without a leader", n, ai.Steering)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"slices"
+	"time"
+)
+
+var ErrSector = errors.New("no such sector")
+
+// SectorCoord is a sector's column and row in a Galaxy.
+type SectorCoord struct {
+	X int32
+	Y int32
+}
+
+// SectorChanged tells a player's client that its ship has moved to another
+// sector.
+type SectorChanged struct {
+	Sector SectorCoord
+}
+
+// Galaxy is a Columns by Rows grid of sectors, each simulated by its own
+// Instance with the galaxy's Parameters, so ScreenWidth and ScreenHeight are
+// a sector's size. Sectors are created when first used, and generated from
+// the GalaxySeed if there is one.
+//
+// Anything moving freely across a sector's edge, under impulse or at warp,
+// moves to the neighbouring sector, coming in at the opposite edge. At the
+// edges of the galaxy sectors wrap round if Wrap is set, and are bounded
+// otherwise: ships stop at the edge.
+//
+// Only active sectors are simulated; the rest stay as they were until a
+// player or an NPC comes. Clients are only sent what happens in sectors
+// with players.
+type Galaxy struct {
+	Columns    int
+	Rows       int
+	Wrap       bool
+	Parameters Parameters
+
+	// ShipClasses and Behaviors are given to every sector.
+	ShipClasses map[string]ShipClass
+	Behaviors   map[string]BehaviorTree
+	Sectors     map[SectorCoord]*Instance
+	Allocator   *EntityAllocator
+	Clock       *Clock
+	Receiver    ecstypes.Receiver
+	Sender      ecstypes.Sender
+}
+
+func NewGalaxy(columns, rows int, wrap bool, parameters Parameters) *Galaxy {
+	if parameters.TickRate == 0 {
+		parameters.TickRate = DefaultTickRate
+	}
+	return &Galaxy{
+		Columns:    columns,
+		Rows:       rows,
+		Wrap:       wrap,
+		Parameters: parameters,
+		Sectors:    make(map[SectorCoord]*Instance),
+		Allocator:  new(EntityAllocator),
+		Clock:      NewClock(parameters.TickRate),
+	}
+}
+
+func (g *Galaxy) SetReceiver(receiver ecstypes.Receiver) {
+	g.Receiver = receiver
+}
+func (g *Galaxy) SetSender(sender ecstypes.Sender) {
+	g.Sender = sender
+}
+
+// sectorSender sends a sector's messages on to the clients of the players
+// whose ships are in the sector.
+type sectorSender struct {
+	galaxy *Galaxy
+	sector *Instance
+}
+
+func (s sectorSender) Send(msg ecstypes.ComponentMessage) {
+	var players []ecstypes.EntityID
+	s.sector.SyncSender.EachSorted(func(e ecstypes.EntityID, _ *SyncSender) {
+		players = append(players, e)
+	})
+	s.galaxy.sendTo(players, msg)
+}
+
+// sendTo sends msg to the clients of players. A Sender that isn't an
+// ecstypes.Router is taken to reach the one local client, and sent anything
+// for any player.
+func (g *Galaxy) sendTo(players []ecstypes.EntityID, msg ecstypes.ComponentMessage) {
+	if g.Sender == nil || len(players) == 0 {
+		return
+	}
+	if router, ok := g.Sender.(ecstypes.Router); ok {
+		router.SendTo(players, msg)
+		return
+	}
+	g.Sender.Send(msg)
+}
+
+func (g *Galaxy) contains(coord SectorCoord) bool {
+	return coord.X >= 0 && coord.X < int32(g.Columns) && coord.Y >= 0 && coord.Y < int32(g.Rows)
+}
+
+// Sector returns the Instance simulating a sector, creating it if need be.
+func (g *Galaxy) Sector(coord SectorCoord) (*Instance, error) {
+	if !g.contains(coord) {
+		return nil, fmt.Errorf("sector %d,%d: %w", coord.X, coord.Y, ErrSector)
+	}
+	if sector, ok := g.Sectors[coord]; ok {
+		return sector, nil
+	}
+	var sector = NewInstance(g.Parameters)
+	sector.Name = fmt.Sprintf("Sector %d,%d", coord.X, coord.Y)
+	sector.Sector = coord
+	sector.Allocator = g.Allocator
+	sector.ShipClasses = g.ShipClasses
+	sector.Behaviors = g.Behaviors
+	sector.SetSender(sectorSender{galaxy: g, sector: sector})
+	g.Sectors[coord] = sector
+	if g.Parameters.GalaxySeed != 0 {
+		if err := sector.GenerateSector(true); err != nil {
+			return nil, err
+		}
+	}
+	return sector, nil
+}
+
+// Neighbor returns the sector across the edge of coord in direction dx, dy,
+// each -1, 0 or 1, or false at a bounded edge of the galaxy.
+func (g *Galaxy) Neighbor(coord SectorCoord, dx, dy int32) (SectorCoord, bool) {
+	var next = SectorCoord{X: coord.X + dx, Y: coord.Y + dy}
+	if g.Wrap {
+		next.X = (next.X%int32(g.Columns) + int32(g.Columns)) % int32(g.Columns)
+		next.Y = (next.Y%int32(g.Rows) + int32(g.Rows)) % int32(g.Rows)
+	}
+	return next, g.contains(next)
+}
+
+// Locate returns the sector entity is in.
+func (g *Galaxy) Locate(entity ecstypes.EntityID) (SectorCoord, bool) {
+	for coord, sector := range g.Sectors {
+		if _, ok := sector.Entities[entity]; ok {
+			return coord, true
+		}
+	}
+	return SectorCoord{}, false
+}
+
+// Spawn adds a ship of the named class to a sector as a new entity.
+func (g *Galaxy) Spawn(coord SectorCoord, class string, position Position, extra ...ecstypes.Component) (ecstypes.EntityID, error) {
+	sector, err := g.Sector(coord)
+	if err != nil {
+		return 0, err
+	}
+	return sector.Spawn(class, position, extra...)
+}
+
+// SpawnEntity adds a ship of the named class to a sector as entity.
+func (g *Galaxy) SpawnEntity(coord SectorCoord, entity ecstypes.EntityID, class string, position Position, extra ...ecstypes.Component) error {
+	sector, err := g.Sector(coord)
+	if err != nil {
+		return err
+	}
+	return sector.SpawnEntity(entity, class, position, extra...)
+}
+
+// active reports whether a sector needs simulating: whether a player's ship,
+// which the server replicates with a SyncSender, a ship with an AI, or
+// anything moving is in it.
+func (g *Galaxy) active(sector *Instance) bool {
+	if sector.SyncSender.Map.Len() > 0 || sector.AI.Map.Len() > 0 {
+		return true
+	}
+	var moving bool
+	sector.Motion.EachSorted(func(_ ecstypes.EntityID, motion *Motion) {
+		moving = moving || motion.Velocity != (geom.Vector{})
+	})
+	return moving
+}
+
+func (g *Galaxy) RunServer(done chan bool) {
+	ticker := time.NewTicker(g.Clock.TickDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.Update()
+		case <-done:
+			return
+		}
+	}
+}
+
+// Update runs as many ticks as the Clock says are due, applying the
+// messages received on the first of them, as Instance.Update does.
+func (g *Galaxy) Update() error {
+	var ticks = g.Clock.Advance(time.Now())
+	if ticks == 0 {
+		return nil
+	}
+	var msgs []ecstypes.ComponentMessage
+	for {
+		msg, hasMessage := g.Receiver.Receive()
+		if !hasMessage {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+	var errs []error
+	for ; ticks > 0; ticks-- {
+		errs = append(errs, g.Step(msgs))
+		msgs = nil
+	}
+	return errors.Join(errs...)
+}
+
+// Step advances every active sector one tick, giving each the messages for
+// its entities, then moves whatever crossed a sector's edge. Entities moved
+// are only simulated again next tick, whichever sector they went to.
+func (g *Galaxy) Step(msgs []ecstypes.ComponentMessage) error {
+	var routed = make(map[SectorCoord][]ecstypes.ComponentMessage)
+	for _, msg := range msgs {
+		if coord, ok := g.Locate(msg.Entity); ok {
+			routed[coord] = append(routed[coord], msg)
+		}
+	}
+	var active []*Instance
+	for _, coord := range g.sortedSectors() {
+		if sector := g.Sectors[coord]; g.active(sector) {
+			active = append(active, sector)
+		}
+	}
+	var errs []error
+	for _, sector := range active {
+		if err := sector.Step(routed[sector.Sector]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sector.Name, err))
+		}
+	}
+	for _, sector := range active {
+		errs = append(errs, g.crossEdges(sector))
+	}
+	return errors.Join(errs...)
+}
+
+func (g *Galaxy) sortedSectors() []SectorCoord {
+	var coords = make([]SectorCoord, 0, len(g.Sectors))
+	for y := int32(0); y < int32(g.Rows); y++ {
+		for x := int32(0); x < int32(g.Columns); x++ {
+			if _, ok := g.Sectors[SectorCoord{X: x, Y: y}]; ok {
+				coords = append(coords, SectorCoord{X: x, Y: y})
+			}
+		}
+	}
+	return coords
+}
+
+// crossEdges finds what moved across the sector's edges this tick, which
+// Motion has already wrapped round to the far side, and either moves it to
+// the neighbouring sector or, at a bounded edge, stops it there. Entities
+// attached to a Parent go with it, and those on an Orbit never leave. A
+// wingman doesn't leave its leader's sector, stopping at the edge, but goes
+// with its leader.
+func (g *Galaxy) crossEdges(sector *Instance) error {
+	var world = sector.World
+	var crossings = make(map[ecstypes.EntityID][2]int32)
+	sector.Motion.EachSorted(func(e ecstypes.EntityID, motion *Motion) {
+		if _, ok := sector.Parent.GetComponent(e); ok {
+			return
+		}
+		if _, ok := sector.Orbit.GetComponent(e); ok {
+			return
+		}
+		var position = motion.Position
+		var unwrapped = position.Previous.Add(world.Delta(position.Previous, position.Vector))
+		var dx, dy = edgeCrossed(unwrapped.X, world.Width), edgeCrossed(unwrapped.Y, world.Height)
+		if dx != 0 || dy != 0 {
+			crossings[e] = [2]int32{dx, dy}
+		}
+	})
+	var errs []error
+	for _, e := range sortedKeys(crossings) {
+		if _, ok := sector.Entities[e]; !ok {
+			// gone with its leader
+			continue
+		}
+		var crossing = crossings[e]
+		next, ok := g.Neighbor(sector.Sector, crossing[0], crossing[1])
+		if wingman, isWingman := sector.Wingman.GetComponent(e); isWingman {
+			if _, ok := sector.Entities[wingman.Leader]; ok {
+				sector.stopAtEdge(e, crossing[0], crossing[1])
+				continue
+			}
+		}
+		if !ok {
+			sector.stopAtEdge(e, crossing[0], crossing[1])
+			continue
+		}
+		if next != sector.Sector {
+			errs = append(errs, g.Transfer(e, sector.Sector, next))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func edgeCrossed(x, size float64) int32 {
+	switch {
+	case x < 0:
+		return -1
+	case x >= size:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func sortedKeys[V any](m map[ecstypes.EntityID]V) []ecstypes.EntityID {
+	var keys = make([]ecstypes.EntityID, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// stopAtEdge puts an entity that crossed a bounded edge back just inside it,
+// with its speed across the edge taken away and out of warp.
+func (i *Instance) stopAtEdge(e ecstypes.EntityID, dx, dy int32) {
+	motion, ok := i.Motion.GetComponent(e)
+	if !ok {
+		return
+	}
+	var position = motion.Position
+	// a hair inside, since Wrap would take the far edge itself to zero
+	var inside = func(size float64) float64 {
+		return size * (1 - 1e-9)
+	}
+	switch dx {
+	case -1:
+		position.X = 0
+		motion.Velocity.X = max(0, motion.Velocity.X)
+	case 1:
+		position.X = inside(i.World.Width)
+		motion.Velocity.X = min(0, motion.Velocity.X)
+	}
+	switch dy {
+	case -1:
+		position.Y = 0
+		motion.Velocity.Y = max(0, motion.Velocity.Y)
+	case 1:
+		position.Y = inside(i.World.Height)
+		motion.Velocity.Y = min(0, motion.Velocity.Y)
+	}
+	position.Previous = position.Vector
+	i.indexEntity(e, position)
+	if warp, ok := i.Warp.GetComponent(e); ok {
+		warp.Drop(i)
+		// flushed with the sector's next tick
+	}
+}
+
+// Transfer moves an entity and everything attached to it from one sector to
+// another, keeping its position within the sector, and tells a player's
+// client. Warp drives and jump gates use it to move ships directly.
+//
+// A fleet's wingmen go with their leader, keeping where they are relative to
+// it but inside the sector's edges.
+func (g *Galaxy) Transfer(entity ecstypes.EntityID, from, to SectorCoord) error {
+	source, err := g.Sector(from)
+	if err != nil {
+		return err
+	}
+	destination, err := g.Sector(to)
+	if err != nil {
+		return err
+	}
+	var family = source.family(entity)
+	var states = make([]EntityState, len(family))
+	var offsets = make(map[ecstypes.EntityID]geom.Vector)
+	for index, e := range family {
+		states[index] = source.SaveEntity(e)
+		if state := states[index]; state.Wingman != nil && state.Parent == nil && state.Position != nil {
+			if leader, ok := source.Position.GetComponent(state.Wingman.Leader); ok {
+				offsets[e] = source.World.Delta(leader.Vector, state.Position.Vector)
+			}
+		}
+	}
+	for index := len(family) - 1; index >= 0; index-- {
+		source.RemoveEntity(family[index])
+	}
+	for _, state := range states {
+		if err = destination.AddEntity(state.Entity, state.components()...); err != nil {
+			return fmt.Errorf("moving %d to %s: %w", state.Entity, destination.Name, err)
+		}
+		if offset, ok := offsets[state.Entity]; ok {
+			destination.formUp(state.Entity, state.Wingman.Leader, offset)
+		}
+		if state.Replicated != nil && source.Sender != nil {
+			// clients showing the old sector stop showing it
+			source.Sender.Send(ecstypes.ComponentMessage{Entity: state.Entity, Payload: Despawned{}})
+		}
+		if state.SyncSender != nil {
+			g.sendTo([]ecstypes.EntityID{state.Entity}, ecstypes.ComponentMessage{
+				Entity:  state.Entity,
+				Payload: SectorChanged{Sector: to},
+			})
+		}
+	}
+	return nil
+}
+
+// family returns entity and everything attached to it, directly or not,
+// each parent before its children, and the wingmen of its fleet after their
+// leader.
+func (i *Instance) family(entity ecstypes.EntityID) []ecstypes.EntityID {
+	var result = []ecstypes.EntityID{entity}
+	for index := 0; index < len(result); index++ {
+		i.Parent.EachSorted(func(child ecstypes.EntityID, comp *Parent) {
+			if comp.Parent == result[index] {
+				result = append(result, child)
+			}
+		})
+		i.Wingman.EachSorted(func(wingman ecstypes.EntityID, comp *Wingman) {
+			if comp.Leader == result[index] {
+				result = append(result, wingman)
+			}
+		})
+	}
+	return result
+}
+
+// formUp puts a wingman that came with its leader at offset from it, moved
+// inside the sector's edges if need be.
+func (i *Instance) formUp(e, leader ecstypes.EntityID, offset geom.Vector) {
+	position, ok := i.Position.GetComponent(e)
+	if !ok {
+		return
+	}
+	lead, ok := i.Position.GetComponent(leader)
+	if !ok {
+		return
+	}
+	// a hair inside, since Wrap would take the far edge itself to zero
+	var p = lead.Vector.Add(offset)
+	p.X = max(0, min(p.X, i.World.Width*(1-1e-9)))
+	p.Y = max(0, min(p.Y, i.World.Height*(1-1e-9)))
+	position.Vector, position.Previous = p, p
+	i.indexEntity(e, position)
+}
+
+// changeSector shows the client's ship in another sector: what it was
+// showing of the old one is cleared away and the new one's content
+// generated.
+func (i *Instance) changeSector(coord SectorCoord) error {
+	i.Sector = coord
+	i.Beams = i.Beams[:0]
+	for _, e := range i.sortedEntities() {
+		if _, ok := i.Player.GetComponent(e); !ok {
+			i.RemoveEntity(e)
+		}
+	}
+	if i.Parameters.GalaxySeed == 0 {
+		return nil
+	}
+	return i.GenerateSector(false)
+}
@@ -0,0 +1,343 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"testing"
+)
+
+// newGalaxy returns a three by two galaxy of 1000 pixel sectors, and the
+// Pipe it sends to.
+func newGalaxy(wrap bool) (*Galaxy, *Pipe) {
+	var galaxy = NewGalaxy(3, 2, wrap, Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	var sent = NewPipe()
+	galaxy.SetSender(sent)
+	return galaxy, sent
+}
+
+// addShip adds a moving entity to a sector, replicated to a player's client
+// if player is set.
+func addShip(t *testing.T, galaxy *Galaxy, coord SectorCoord, e ecstypes.EntityID, at, velocity geom.Vector, player bool) {
+	t.Helper()
+	sector, err := galaxy.Sector(coord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var components = []ecstypes.Component{&Position{Vector: at}, &Motion{Velocity: velocity}}
+	if player {
+		components = append(components, new(SyncSender))
+	}
+	if err := sector.AddEntity(e, components...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNeighbor(t *testing.T) {
+	var tests = []struct {
+		name   string
+		wrap   bool
+		from   SectorCoord
+		dx, dy int32
+		want   SectorCoord
+		ok     bool
+	}{
+		{"inside", false, SectorCoord{X: 1, Y: 0}, 1, 1, SectorCoord{X: 2, Y: 1}, true},
+		{"bounded left", false, SectorCoord{X: 0, Y: 1}, -1, 0, SectorCoord{}, false},
+		{"bounded corner", false, SectorCoord{X: 2, Y: 1}, 1, 1, SectorCoord{}, false},
+		{"wraps left", true, SectorCoord{X: 0, Y: 1}, -1, 0, SectorCoord{X: 2, Y: 1}, true},
+		{"wraps corner", true, SectorCoord{X: 2, Y: 1}, 1, 1, SectorCoord{X: 0, Y: 0}, true},
+	}
+	for _, test := range tests {
+		var galaxy, _ = newGalaxy(test.wrap)
+		got, ok := galaxy.Neighbor(test.from, test.dx, test.dy)
+		if ok != test.ok || (ok && got != test.want) {
+			t.Errorf("%s: got %v, %v, want %v, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+	var galaxy, _ = newGalaxy(true)
+	if _, err := galaxy.Sector(SectorCoord{X: 3}); !errors.Is(err, ErrSector) {
+		t.Errorf("sector outside the galaxy: got %v, want ErrSector", err)
+	}
+}
+
+func TestCrossEdges(t *testing.T) {
+	var tests = []struct {
+		name     string
+		wrap     bool
+		from     SectorCoord
+		at       geom.Vector
+		velocity geom.Vector
+		want     SectorCoord
+		// wantAt is roughly where it ends up, within a tick's movement
+		wantAt geom.Vector
+	}{
+		{"right", false, SectorCoord{X: 0, Y: 0}, geom.Vector{X: 999, Y: 500}, geom.Vector{X: 600}, SectorCoord{X: 1, Y: 0}, geom.Vector{X: 9, Y: 500}},
+		{"diagonally", false, SectorCoord{X: 0, Y: 0}, geom.Vector{X: 999, Y: 999}, geom.Vector{X: 600, Y: 600}, SectorCoord{X: 1, Y: 1}, geom.Vector{X: 9, Y: 9}},
+		{"round the galaxy", true, SectorCoord{X: 1, Y: 0}, geom.Vector{X: 500, Y: 1}, geom.Vector{Y: -600}, SectorCoord{X: 1, Y: 1}, geom.Vector{X: 500, Y: 991}},
+		{"stopped at the galaxy's edge", false, SectorCoord{X: 1, Y: 0}, geom.Vector{X: 500, Y: 1}, geom.Vector{X: 60, Y: -600}, SectorCoord{X: 1, Y: 0}, geom.Vector{X: 501, Y: 0}},
+		{"inside", false, SectorCoord{X: 1, Y: 0}, geom.Vector{X: 500, Y: 500}, geom.Vector{X: 600}, SectorCoord{X: 1, Y: 0}, geom.Vector{X: 510, Y: 500}},
+	}
+	for _, test := range tests {
+		var galaxy, _ = newGalaxy(test.wrap)
+		addShip(t, galaxy, test.from, 1, test.at, test.velocity, true)
+		if err := galaxy.Step(nil); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		coord, ok := galaxy.Locate(1)
+		if !ok || coord != test.want {
+			t.Errorf("%s: in sector %v, want %v", test.name, coord, test.want)
+			continue
+		}
+		var motion, _ = galaxy.Sectors[coord].Motion.GetComponent(1)
+		if motion.Position.Sub(test.wantAt).Length() > 1e-6 {
+			t.Errorf("%s: at %v, want %v", test.name, motion.Position.Vector, test.wantAt)
+		}
+		if test.from != test.want && motion.Velocity != test.velocity {
+			t.Errorf("%s: moving at %v after crossing, want %v", test.name, motion.Velocity, test.velocity)
+		}
+	}
+}
+
+func TestStopAtEdge(t *testing.T) {
+	var tests = []struct {
+		name     string
+		sector   SectorCoord
+		at       geom.Vector
+		velocity geom.Vector
+		want     geom.Vector
+	}{
+		{"left", SectorCoord{X: 0, Y: 1}, geom.Vector{X: 1, Y: 500}, geom.Vector{X: -600, Y: 60}, geom.Vector{Y: 60}},
+		{"right", SectorCoord{X: 2, Y: 0}, geom.Vector{X: 999, Y: 500}, geom.Vector{X: 600, Y: -60}, geom.Vector{Y: -60}},
+		{"bottom", SectorCoord{X: 1, Y: 1}, geom.Vector{X: 500, Y: 999}, geom.Vector{X: 60, Y: 600}, geom.Vector{X: 60}},
+		{"corner", SectorCoord{X: 0, Y: 0}, geom.Vector{X: 1, Y: 1}, geom.Vector{X: -600, Y: -600}, geom.Vector{}},
+	}
+	for _, test := range tests {
+		var galaxy, _ = newGalaxy(false)
+		addShip(t, galaxy, test.sector, 1, test.at, test.velocity, true)
+		if err := galaxy.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		if coord, _ := galaxy.Locate(1); coord != test.sector {
+			t.Errorf("%s: left for sector %v", test.name, coord)
+			continue
+		}
+		var motion, _ = galaxy.Sectors[test.sector].Motion.GetComponent(1)
+		if motion.Velocity != test.want {
+			t.Errorf("%s: moving at %v, want %v", test.name, motion.Velocity, test.want)
+		}
+		var p = motion.Position.Vector
+		if p.X < 0 || p.X >= 1000 || p.Y < 0 || p.Y >= 1000 {
+			t.Errorf("%s: stopped outside the sector at %v", test.name, p)
+		}
+		if galaxy.Sectors[test.sector].World.Distance(p, test.at) > 20 {
+			t.Errorf("%s: stopped at %v, far from %v", test.name, p, test.at)
+		}
+	}
+}
+
+func TestTransferMovesAttached(t *testing.T) {
+	var galaxy, sent = newGalaxy(false)
+	var from, to = SectorCoord{X: 0, Y: 0}, SectorCoord{X: 1, Y: 0}
+	addShip(t, galaxy, from, 1, geom.Vector{X: 100, Y: 100}, geom.Vector{}, true)
+	addShip(t, galaxy, from, 2, geom.Vector{X: 110, Y: 100}, geom.Vector{}, false)
+	addShip(t, galaxy, from, 3, geom.Vector{X: 120, Y: 100}, geom.Vector{}, false)
+	addShip(t, galaxy, from, 4, geom.Vector{X: 900, Y: 900}, geom.Vector{}, false)
+	var source = galaxy.Sectors[from]
+	if err := source.Attach(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Attach(3, 2); err != nil {
+		t.Fatal(err)
+	}
+	drain(sent)
+
+	if err := galaxy.Transfer(1, from, to); err != nil {
+		t.Fatal(err)
+	}
+	for e, want := range map[ecstypes.EntityID]SectorCoord{1: to, 2: to, 3: to, 4: from} {
+		if coord, _ := galaxy.Locate(e); coord != want {
+			t.Errorf("entity %d in %v, want %v", e, coord, want)
+		}
+	}
+	if parent, ok := galaxy.Sectors[to].Parent.GetComponent(3); !ok || parent.Parent != 2 {
+		t.Error("grandchild came detached")
+	}
+	var msgs = drain(sent)
+	if len(msgs) != 1 || msgs[0].Entity != 1 || msgs[0].Payload != (SectorChanged{Sector: to}) {
+		t.Errorf("sent %+v, want the player told of the new sector", msgs)
+	}
+	if err := galaxy.Transfer(1, to, SectorCoord{X: 5}); !errors.Is(err, ErrSector) {
+		t.Errorf("transfer out of the galaxy: got %v, want ErrSector", err)
+	}
+}
+
+// clients is an ecstypes.Router that records what each player's client is
+// sent.
+type clients map[ecstypes.EntityID][]ecstypes.ComponentMessage
+
+func (c clients) Send(msg ecstypes.ComponentMessage) {
+	for player := range c {
+		c[player] = append(c[player], msg)
+	}
+}
+func (c clients) SendTo(players []ecstypes.EntityID, msg ecstypes.ComponentMessage) {
+	for _, player := range players {
+		c[player] = append(c[player], msg)
+	}
+}
+
+func TestSectorsSendToTheirPlayers(t *testing.T) {
+	var galaxy, _ = newGalaxy(false)
+	var sent = clients{1: nil, 2: nil}
+	galaxy.SetSender(sent)
+	var a, b = SectorCoord{X: 0}, SectorCoord{X: 1}
+	addShip(t, galaxy, a, 1, geom.Vector{X: 100, Y: 100}, geom.Vector{}, true)
+	addShip(t, galaxy, b, 2, geom.Vector{X: 500, Y: 500}, geom.Vector{}, true)
+	var step = func() {
+		t.Helper()
+		sent[1], sent[2] = nil, nil
+		for tick := 0; tick < 3; tick++ {
+			if err := galaxy.Step(nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var about = func(player, e ecstypes.EntityID) bool {
+		for _, msg := range sent[player] {
+			if msg.Entity == e {
+				return true
+			}
+		}
+		return false
+	}
+
+	step()
+	for player, other := range map[ecstypes.EntityID]ecstypes.EntityID{1: 2, 2: 1} {
+		if !about(player, player) || about(player, other) {
+			t.Errorf("player %d in another sector from %d was sent %+v", player, other, sent[player])
+		}
+	}
+
+	sent[1], sent[2] = nil, nil
+	if err := galaxy.Transfer(1, a, b); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent[1]) != 1 || sent[1][0].Payload != (SectorChanged{Sector: b}) || len(sent[2]) != 0 {
+		t.Errorf("after the transfer, sent %+v, want only player 1 told", sent)
+	}
+	step()
+	if !about(1, 2) || !about(2, 1) {
+		t.Errorf("players in one sector were sent %+v", sent)
+	}
+}
+
+func TestSectorChangedOnlyForOwnShip(t *testing.T) {
+	var client = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	client.Client = true
+	if err := client.AddEntity(1, &Position{}, new(Motion), new(Player)); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddEntity(2, &Position{}, new(Motion)); err != nil {
+		t.Fatal(err)
+	}
+	var to = SectorCoord{X: 1}
+	if err := client.HandleMessage(ecstypes.ComponentMessage{Entity: 2, Payload: SectorChanged{Sector: to}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.Entities[2]; !ok || client.Sector != (SectorCoord{}) {
+		t.Errorf("moved to %v when another ship changed sector", client.Sector)
+	}
+	if err := client.HandleMessage(ecstypes.ComponentMessage{Entity: 1, Payload: SectorChanged{Sector: to}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.Entities[2]; ok || client.Sector != to {
+		t.Errorf("in %v when its own ship changed sector", client.Sector)
+	}
+}
+
+func TestOnlyActiveSectorsStep(t *testing.T) {
+	var tests = []struct {
+		name     string
+		velocity geom.Vector
+		player   bool
+		extra    []ecstypes.Component
+		want     uint64
+	}{
+		{"player", geom.Vector{}, true, nil, 1},
+		{"moving", geom.Vector{X: 60}, false, nil, 1},
+		{"npc", geom.Vector{}, false, []ecstypes.Component{new(AI)}, 1},
+		{"still", geom.Vector{}, false, nil, 0},
+	}
+	for _, test := range tests {
+		var galaxy, _ = newGalaxy(false)
+		var coord = SectorCoord{X: 1}
+		sector, err := galaxy.Sector(coord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var components = append([]ecstypes.Component{&Position{Vector: geom.Vector{X: 100, Y: 100}}, &Motion{Velocity: test.velocity}}, test.extra...)
+		if test.player {
+			components = append(components, new(SyncSender))
+		}
+		if err := sector.AddEntity(1, components...); err != nil {
+			t.Fatal(err)
+		}
+		if err := galaxy.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := sector.Counter; got != test.want {
+			t.Errorf("%s: sector stepped %d times, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWingmenStayWithLeader(t *testing.T) {
+	var galaxy, _ = newGalaxy(false)
+	var from, to = SectorCoord{X: 0, Y: 0}, SectorCoord{X: 1, Y: 0}
+	source, err := galaxy.Sector(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.AddEntity(1, &Position{Vector: geom.Vector{X: 20, Y: 500}}, new(Motion), new(SyncSender), new(Fleet)); err != nil {
+		t.Fatal(err)
+	}
+	var wingmen = map[ecstypes.EntityID]geom.Vector{
+		2: {X: 60, Y: 540},
+		// across the sector's left edge from its leader
+		3: {X: 980, Y: 500},
+	}
+	for _, e := range sortedKeys(wingmen) {
+		err := source.AddEntity(e, &Position{Vector: wingmen[e]}, new(Motion), new(Helm), new(AI), &Wingman{Leader: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// crossing an edge without its leader, a wingman stops there
+	var motion, _ = source.Motion.GetComponent(2)
+	motion.Velocity = geom.Vector{X: 600}
+	var position, _ = source.Position.GetComponent(2)
+	position.Vector.X = 995
+	if err := galaxy.Step(nil); err != nil {
+		t.Fatal(err)
+	}
+	if coord, _ := galaxy.Locate(2); coord != from {
+		t.Fatalf("wingman left for %v without its leader", coord)
+	}
+
+	if err := galaxy.Transfer(1, from, to); err != nil {
+		t.Fatal(err)
+	}
+	var destination = galaxy.Sectors[to]
+	for _, e := range []ecstypes.EntityID{2, 3} {
+		if coord, _ := galaxy.Locate(e); coord != to {
+			t.Errorf("wingman %d in %v, want %v with its leader", e, coord, to)
+		}
+	}
+	var leader, _ = destination.Position.GetComponent(1)
+	var wingman, _ = destination.Position.GetComponent(3)
+	if wingman.X != 0 || wingman.Y != leader.Y {
+		t.Errorf("wingman at %v with its leader at %v, want it at the sector's left edge", wingman.Vector, leader.Vector)
+	}
+}
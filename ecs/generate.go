@@ -0,0 +1,251 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"image/color"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// sectorClearance is the radius around a sector's center left empty, so
+// ships spawned there don't start inside anything.
+const sectorClearance = 300
+
+var (
+	starColor     = color.RGBA{R: 0xff, G: 0xf0, B: 0xa0, A: 0xff}
+	asteroidColor = color.RGBA{R: 0x90, G: 0x90, B: 0x90, A: 0xff}
+	nebulaColor   = color.RGBA{R: 0x60, G: 0x30, B: 0xa0, A: 0x40}
+	stationColor  = color.RGBA{R: 0xa0, G: 0xc0, B: 0xff, A: 0xff}
+	planetColors  = []color.RGBA{
+		{R: 0x40, G: 0x80, B: 0xff, A: 0xff},
+		{R: 0xc0, G: 0x60, B: 0x30, A: 0xff},
+		{R: 0x60, G: 0xc0, B: 0x60, A: 0xff},
+		{R: 0xd0, G: 0xc0, B: 0x90, A: 0xff},
+	}
+)
+
+const (
+	// GeneratedEntities is the first entity ID of generated content, kept
+	// apart from the IDs NewEntity hands out. Each sector numbers its content
+	// from its own block of sectorEntities IDs, so a client numbers it as its
+	// server does whatever else either has added.
+	GeneratedEntities ecstypes.EntityID = 1 << 62
+	sectorEntities                      = 1 << 20
+)
+
+// sectorGenerator places a sector's content, drawing everything from one
+// random source seeded by the galaxy's seed and the sector's coordinates.
+type sectorGenerator struct {
+	*Instance
+	rand *rand.Rand
+	next ecstypes.EntityID
+}
+
+// GenerateSector fills the Instance's Sector with content derived from
+// Parameters.GalaxySeed: a nebula, a star and its planets or a few rogue
+// planets, asteroid fields and a station, none of which move or can be
+// destroyed. The same seed and sector always give the same content, so a
+// client generates what its server has rather than being sent it.
+//
+// Patrols of ships from ShipClasses are added after the static content if
+// patrols is set, as a server does and a client doesn't: the server
+// replicates them.
+func (i *Instance) GenerateSector(patrols bool) error {
+	var block = ecstypes.EntityID(uint16(i.Sector.Y))<<16 | ecstypes.EntityID(uint16(i.Sector.X))
+	var gen = &sectorGenerator{
+		Instance: i,
+		rand:     rand.New(rand.NewPCG(i.Parameters.GalaxySeed, uint64(uint32(i.Sector.X))<<32|uint64(uint32(i.Sector.Y)))),
+		next:     GeneratedEntities + block*sectorEntities,
+	}
+	var errs []error
+	if gen.rand.Float64() < 0.4 {
+		errs = append(errs, gen.nebula())
+	}
+	var planets []geom.Vector
+	if gen.rand.Float64() < 0.3 {
+		var star = gen.place(200)
+		errs = append(errs, gen.body(star, 60, 7.2e6, starColor))
+		for count := gen.rand.IntN(4); count > 0; count-- {
+			// planets keep clear of the star's pull and the sector's center
+			var planet = gen.placeNear(star, 350, 900)
+			errs = append(errs, gen.body(planet, 15+gen.rand.Float64()*20, 1.25e6, gen.pick(planetColors)))
+			planets = append(planets, planet)
+		}
+	} else {
+		for count := gen.rand.IntN(3); count > 0; count-- {
+			var planet = gen.place(150)
+			errs = append(errs, gen.body(planet, 15+gen.rand.Float64()*20, 1.25e6, gen.pick(planetColors)))
+			planets = append(planets, planet)
+		}
+	}
+	for count := gen.rand.IntN(3); count > 0; count-- {
+		errs = append(errs, gen.asteroidField())
+	}
+	if gen.rand.Float64() < 0.25 {
+		var at geom.Vector
+		if len(planets) > 0 {
+			at = gen.placeNear(planets[gen.rand.IntN(len(planets))], 100, 160)
+		} else {
+			at = gen.place(100)
+		}
+		errs = append(errs, gen.station(at))
+	}
+	if patrols {
+		errs = append(errs, gen.patrols())
+	}
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("generating %s: %w", i.Name, err)
+		}
+	}
+	return nil
+}
+
+// entity returns the next ID of the sector's block.
+func (gen *sectorGenerator) entity() ecstypes.EntityID {
+	var e = gen.next
+	gen.next++
+	return e
+}
+
+// place picks a point at least margin inside the sector's edges and clear of
+// its center.
+func (gen *sectorGenerator) place(margin float64) geom.Vector {
+	var p geom.Vector
+	for tries := 0; tries < 10; tries++ {
+		p = geom.Vector{
+			X: margin + gen.rand.Float64()*max(0, gen.World.Width-2*margin),
+			Y: margin + gen.rand.Float64()*max(0, gen.World.Height-2*margin),
+		}
+		if gen.clear(p) {
+			break
+		}
+	}
+	return p
+}
+
+// placeNear picks a point between near and far from center, clear of the
+// sector's center.
+func (gen *sectorGenerator) placeNear(center geom.Vector, near, far float64) geom.Vector {
+	var p geom.Vector
+	for tries := 0; tries < 10; tries++ {
+		var offset = geom.Angle(gen.rand.Float64() * 2 * math.Pi).ToVector().Multiply(near + gen.rand.Float64()*(far-near))
+		p = gen.World.Wrap(center.Add(offset))
+		if gen.clear(p) {
+			break
+		}
+	}
+	return p
+}
+
+func (gen *sectorGenerator) clear(p geom.Vector) bool {
+	var center = geom.Vector{X: gen.World.Width / 2, Y: gen.World.Height / 2}
+	return gen.World.Distance(p, center) > sectorClearance
+}
+
+func (gen *sectorGenerator) pick(colors []color.RGBA) color.RGBA {
+	return colors[gen.rand.IntN(len(colors))]
+}
+
+// blob returns a rough circle of the given radius, each point's distance
+// from the center varied by up to roughness.
+func (gen *sectorGenerator) blob(radius float64, points int, roughness float64) geom.Outline {
+	var outline = make(geom.Outline, points)
+	for index := range outline {
+		var angle = geom.Angle(2 * math.Pi * float64(index) / float64(points))
+		outline[index] = angle.ToVector().Multiply(radius * (1 - roughness*gen.rand.Float64()))
+	}
+	return outline
+}
+
+func (gen *sectorGenerator) nebula() error {
+	var radius = 300 + gen.rand.Float64()*300
+	return gen.AddEntity(gen.entity(),
+		&Position{Vector: gen.place(0)},
+		new(Motion),
+		&Sprite{Outline: gen.blob(radius, 12, 0.4), Color: nebulaColor},
+	)
+}
+
+// body adds a star or planet: a GravityWell as heavy as mass.
+func (gen *sectorGenerator) body(at geom.Vector, radius, mass float64, tint color.RGBA) error {
+	return gen.AddEntity(gen.entity(),
+		&Position{Vector: at},
+		new(Motion),
+		&Sprite{Outline: gen.blob(radius, 16, 0), Color: tint},
+		&GravityWell{Mass: mass, Radius: radius, Softening: radius},
+		// no mass: other wells don't pull it about
+		new(Body),
+	)
+}
+
+func (gen *sectorGenerator) asteroidField() error {
+	var center = gen.place(250)
+	for count := 6 + gen.rand.IntN(10); count > 0; count-- {
+		var outline = gen.blob(6+gen.rand.Float64()*14, 7, 0.5)
+		var err = gen.AddEntity(gen.entity(),
+			&Position{Vector: gen.placeNear(center, 0, 250), Angle: geom.Angle(gen.rand.Float64() * 2 * math.Pi)},
+			new(Motion),
+			&Sprite{Outline: outline, Color: asteroidColor},
+			&Collider{Outline: outline, Layer: LayerObstacle},
+			// no mass: ships bounce off, the asteroid doesn't move
+			&Body{Restitution: 0.5},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gen *sectorGenerator) station(at geom.Vector) error {
+	var outline = gen.blob(25, 6, 0)
+	return gen.AddEntity(gen.entity(),
+		&Position{Vector: at},
+		new(Motion),
+		&Sprite{Outline: outline, Color: stationColor},
+		&Collider{Outline: outline, Layer: LayerObstacle},
+		&Body{Restitution: 0.2},
+	)
+}
+
+// patrols adds up to two fleets of two or three ships of one class each, in
+// wedge formation behind a leader that circles a point in the sector, or
+// thinks with the patrol behavior tree if there is one.
+func (gen *sectorGenerator) patrols() error {
+	if len(gen.ShipClasses) == 0 {
+		return nil
+	}
+	var classes = make([]string, 0, len(gen.ShipClasses))
+	for name := range gen.ShipClasses {
+		classes = append(classes, name)
+	}
+	sort.Strings(classes)
+	for groups := gen.rand.IntN(3); groups > 0; groups-- {
+		var class = classes[gen.rand.IntN(len(classes))]
+		var center = gen.place(200)
+		var heading = geom.Angle(gen.rand.Float64() * 2 * math.Pi)
+		var extra = []ecstypes.Component{
+			&AI{Steering: SteerOrbit, Point: center, Radius: 200},
+			&Fleet{SquadOrders: SquadOrders{Formation: FormationWedge}},
+			&Replicated{Class: class},
+		}
+		if _, ok := gen.Behaviors["patrol"]; ok {
+			extra = append(extra, &Brain{Tree: "patrol", Team: 1, Blackboard: Blackboard{Home: center}})
+		}
+		leader, err := gen.Spawn(class, Position{Vector: gen.placeNear(center, 40, 120), Angle: heading}, extra...)
+		if err != nil {
+			return err
+		}
+		for count := 1 + gen.rand.IntN(2); count > 0; count-- {
+			var position = Position{Vector: gen.placeNear(center, 40, 120), Angle: heading}
+			if _, err := gen.Spawn(class, position, &AI{}, &Wingman{Leader: leader}, &Replicated{Class: class}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
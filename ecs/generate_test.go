@@ -0,0 +1,94 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/assets"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"slices"
+	"testing"
+)
+
+func generated(t *testing.T, seed uint64, coord SectorCoord) *Instance {
+	t.Helper()
+	classes, err := LoadShipClasses(assets.Ships())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sector = NewInstance(Parameters{ScreenWidth: 2000, ScreenHeight: 2000, GalaxySeed: seed})
+	sector.Sector = coord
+	sector.ShipClasses = classes
+	if err := sector.GenerateSector(true); err != nil {
+		t.Fatal(err)
+	}
+	return sector
+}
+
+func TestGenerateSectorDeterministic(t *testing.T) {
+	var count int
+	for _, coord := range []SectorCoord{{X: 0, Y: 0}, {X: 3, Y: 1}, {X: 7, Y: 7}} {
+		var a, b = generated(t, 42, coord), generated(t, 42, coord)
+		count += len(a.Entities)
+		if !slices.Equal(a.sortedEntities(), b.sortedEntities()) {
+			t.Errorf("sector %v: entities %v and %v", coord, a.sortedEntities(), b.sortedEntities())
+		}
+		if a.Checksum() != b.Checksum() {
+			t.Errorf("sector %v generated differently from the same seed", coord)
+		}
+	}
+
+	if count == 0 {
+		t.Fatal("nothing generated")
+	}
+
+	// across many sectors and seeds, something must differ
+	var checksums = make(map[uint64]bool)
+	for seed := uint64(1); seed <= 3; seed++ {
+		for x := int32(0); x < 4; x++ {
+			checksums[generated(t, seed, SectorCoord{X: x}).Checksum()] = true
+		}
+	}
+	if len(checksums) < 6 {
+		t.Errorf("only %d different sectors from 3 seeds by 4 coordinates", len(checksums))
+	}
+}
+
+func TestGeneratedIDBlocks(t *testing.T) {
+	var owner = make(map[ecstypes.EntityID]SectorCoord)
+	for _, coord := range []SectorCoord{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: -1, Y: 0}, {X: 0, Y: -1}, {X: -1, Y: -1}} {
+		var sector = generated(t, 42, coord)
+		for e := range sector.Entities {
+			if _, ship := sector.Helm.GetComponent(e); ship {
+				// patrols are spawned, and replicated
+				continue
+			}
+			if e < GeneratedEntities {
+				t.Errorf("sector %v: generated entity %d below GeneratedEntities", coord, e)
+			}
+			if other, ok := owner[e]; ok {
+				t.Errorf("entity %d generated in both %v and %v", e, other, coord)
+			}
+			owner[e] = coord
+		}
+		if sector.NextEntity >= GeneratedEntities {
+			t.Errorf("sector %v: NextEntity %d after generating", coord, sector.NextEntity)
+		}
+	}
+	if len(owner) == 0 {
+		t.Fatal("nothing generated")
+	}
+
+	// a client with entities of its own numbers the content as the server
+	var server = generated(t, 42, SectorCoord{X: 2, Y: 1})
+	var client = NewInstance(Parameters{ScreenWidth: 2000, ScreenHeight: 2000, GalaxySeed: 42})
+	client.Sector = SectorCoord{X: 2, Y: 1}
+	if err := client.AddEntity(client.NewEntity(), &Position{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.GenerateSector(false); err != nil {
+		t.Fatal(err)
+	}
+	for e := range client.Entities {
+		if _, ok := server.Entities[e]; !ok && e >= GeneratedEntities {
+			t.Errorf("client generated entity %d the server didn't", e)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+	"math"
+)
+
+// GravitationalConstant scales GravityWell.Mass into the gravitational
+// parameter, in pixels³/s² per unit of mass.
+const GravitationalConstant = 1.0
+
+// keplerIterations is enough Newton steps to solve Kepler's equation to
+// float64 precision for eccentricities below about 0.95.
+const keplerIterations = 12
+
+// GravityWell is a planet or star that pulls every entity with Motion towards
+// it. Softening is the Plummer length that smooths the pull close in, so that
+// a ship passing through the center isn't flung off at infinite speed.
+type GravityWell struct {
+	Entity    ecstypes.EntityID
+	Position  *Position `json:"-"`
+	Mass      float64
+	Radius    float64
+	Softening float64
+}
+
+func (comp GravityWell) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding gravity well: %w", err)
+	}
+	return nil
+}
+func (comp GravityWell) Update(_ ecstypes.SystemManager) (GravityWell, error) {
+	return comp, nil
+}
+func (comp GravityWell) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemGravityWell
+}
+
+// Mu is the well's standard gravitational parameter.
+func (comp *GravityWell) Mu() float64 {
+	return GravitationalConstant * comp.Mass
+}
+
+// Acceleration returns the pull of the well on a point, towards the nearest
+// copy of the well across the world's edges.
+func (comp *GravityWell) Acceleration(world geom.Torus, at geom.Vector) geom.Vector {
+	var delta = world.Delta(at, comp.Position.Vector)
+	var distanceSquared = delta.LengthSquared() + comp.Softening*comp.Softening
+	if distanceSquared == 0 {
+		return geom.Vector{}
+	}
+	return delta.Multiply(comp.Mu() / (distanceSquared * math.Sqrt(distanceSquared)))
+}
+
+// OrbitalVelocity returns the velocity, relative to the well, of a circular
+// orbit through at: counterclockwise on screen, or clockwise if retrograde.
+func (comp *GravityWell) OrbitalVelocity(world geom.Torus, at geom.Vector, retrograde bool) geom.Vector {
+	var offset = world.Delta(comp.Position.Vector, at)
+	var distance = offset.Length()
+	if distance == 0 {
+		return geom.Vector{}
+	}
+	var speed = math.Sqrt(comp.Mu() / distance)
+	// y points down the screen, so the perpendicular of the offset turns
+	// clockwise
+	var direction = offset.Perp().Multiply(-1 / distance)
+	if retrograde {
+		direction = direction.Negate()
+	}
+	return direction.Multiply(speed)
+}
+
+// applyGravity accelerates every entity with Motion towards every
+// GravityWell. Entities on an Orbit are moved on rails instead, and Bodies
+// with no Mass don't move.
+func (i *Instance) applyGravity() {
+	if i.GravityWell.Map.Len() == 0 {
+		return
+	}
+	var wells []*GravityWell
+	i.GravityWell.EachSorted(func(_ ecstypes.EntityID, comp *GravityWell) {
+		wells = append(wells, comp)
+	})
+	var dt = i.GetTimeStep()
+	i.Motion.EachSorted(func(e ecstypes.EntityID, motion *Motion) {
+		if _, ok := i.Orbit.GetComponent(e); ok {
+			return
+		}
+		var acceleration geom.Vector
+		for _, well := range wells {
+			if well.Entity != e {
+				acceleration = acceleration.Add(well.Acceleration(i.World, motion.Position.Vector))
+			}
+		}
+		if body, ok := i.Body.GetComponent(e); ok {
+			body.ApplyForce(acceleration.Multiply(body.Mass))
+			return
+		}
+		motion.Velocity = motion.Velocity.Add(acceleration.Multiply(dt))
+	})
+}
+
+// Orbit moves an entity on rails along a Keplerian ellipse around the
+// GravityWell of Primary, so moons and stations stay in stable orbits
+// however long the game runs. Its Position is set from the elements every
+// tick, and its Motion velocity too if it has one.
+//
+// The ellipse has its periapsis in the direction Periapsis from the primary.
+// MeanAnomaly is where along the orbit the entity is at tick Epoch, as an
+// angle from periapsis. Orbits run counterclockwise on screen unless
+// Retrograde.
+type Orbit struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+
+	Primary       ecstypes.EntityID
+	SemiMajorAxis float64
+	Eccentricity  float64
+	Periapsis     geom.Angle
+	MeanAnomaly   geom.Angle
+	Epoch         uint64
+	Retrograde    bool
+}
+
+func (comp Orbit) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Eccentricity < 0 || comp.Eccentricity >= 1 {
+		return fmt.Errorf("orbit eccentricity %v is not an ellipse: %w", comp.Eccentricity, ErrType)
+	}
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding orbit: %w", err)
+	}
+	return nil
+}
+
+// Update places the entity where the elements say it is this tick. A primary
+// that is itself on an Orbit is followed as of its last update, which lags by
+// a tick if it updates after this one.
+func (comp Orbit) Update(sm ecstypes.SystemManager) (Orbit, error) {
+	well, err := GetComponent[GravityWell](sm, comp.Primary)
+	if well == nil {
+		return comp, fmt.Errorf("orbit primary %d has no GravityWell: %w", comp.Primary, vterr.ErrMissing)
+	}
+	var elapsed = float64(int64(sm.GetCounter()-comp.Epoch)) * sm.GetTimeStep()
+	offset, velocity := comp.State(well.Mu(), elapsed)
+	comp.Position.Vector = sm.GetWorld().Wrap(well.Position.Vector.Add(offset))
+	comp.Position.Angle = velocity.Angle()
+
+	motion, err := GetComponent[Motion](sm, comp.Entity)
+	if err != nil {
+		return comp, err
+	}
+	if motion != nil {
+		if primary, _ := GetComponent[Motion](sm, comp.Primary); primary != nil {
+			velocity = velocity.Add(primary.Velocity)
+		}
+		motion.Velocity = velocity
+	}
+	return comp, nil
+}
+func (comp Orbit) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemOrbit
+}
+
+// Period returns the time in seconds for one orbit around a primary with
+// gravitational parameter mu.
+func (comp *Orbit) Period(mu float64) float64 {
+	return 2 * math.Pi * math.Sqrt(comp.SemiMajorAxis*comp.SemiMajorAxis*comp.SemiMajorAxis/mu)
+}
+
+// State returns the offset from the primary and the velocity relative to it,
+// elapsed seconds after Epoch.
+func (comp *Orbit) State(mu float64, elapsed float64) (geom.Vector, geom.Vector) {
+	var a, e = comp.SemiMajorAxis, comp.Eccentricity
+	var motion = math.Sqrt(mu / (a * a * a))
+	var mean = math.Mod(float64(comp.MeanAnomaly)+motion*elapsed, 2*math.Pi)
+
+	// Kepler's equation, M = E - e sin E, by Newton's method
+	var eccentric = mean
+	if e > 0.8 {
+		eccentric = math.Pi
+	}
+	for range keplerIterations {
+		eccentric -= (eccentric - e*math.Sin(eccentric) - mean) / (1 - e*math.Cos(eccentric))
+	}
+
+	var sin, cos = math.Sincos(eccentric)
+	var minor = math.Sqrt(1 - e*e)
+	var offset = geom.Vector{X: a * (cos - e), Y: a * minor * sin}
+	var rate = motion / (1 - e*cos)
+	var velocity = geom.Vector{X: -a * sin * rate, Y: a * minor * cos * rate}
+	// The ellipse above runs clockwise on screen, y pointing down
+	if !comp.Retrograde {
+		offset.Y, velocity.Y = -offset.Y, -velocity.Y
+	}
+	var rotation = geom.Rotation(comp.Periapsis)
+	return rotation.ApplyVector(offset), rotation.ApplyVector(velocity)
+}
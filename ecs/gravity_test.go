@@ -0,0 +1,126 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"testing"
+)
+
+// longRun is how many ticks the energy of an orbit is watched for: over five
+// minutes at the default tick rate.
+const longRun = 20000
+
+// newWell returns an Instance with a GravityWell, entity 0, at the center of
+// its world.
+func newWell(t *testing.T, softening float64) (*Instance, *GravityWell) {
+	t.Helper()
+	var instance = NewInstance(Parameters{ScreenWidth: 2000, ScreenHeight: 2000})
+	var err = instance.AddEntity(0,
+		&Position{Vector: geom.Vector{X: 1000, Y: 1000}},
+		&GravityWell{Mass: 1e6, Radius: 20, Softening: softening},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var well, _ = instance.GravityWell.GetComponent(0)
+	return instance, well
+}
+
+// energy is the specific orbital energy of e about well, in the well's
+// softened potential.
+func energy(instance *Instance, well *GravityWell, e ecstypes.EntityID) float64 {
+	var motion, _ = instance.Motion.GetComponent(e)
+	var r = instance.World.Distance(well.Position.Vector, motion.Position.Vector)
+	var speed = motion.Velocity.Length()
+	return speed*speed/2 - well.Mu()/math.Sqrt(r*r+well.Softening*well.Softening)
+}
+
+// energyDrift steps the Instance for longRun ticks and returns the largest
+// difference from e's starting energy, relative to it.
+func energyDrift(t *testing.T, instance *Instance, well *GravityWell, e ecstypes.EntityID) float64 {
+	t.Helper()
+	var start = energy(instance, well, e)
+	var drift float64
+	for tick := 0; tick < longRun; tick++ {
+		if err := instance.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		drift = max(drift, math.Abs(energy(instance, well, e)-start))
+	}
+	return drift / math.Abs(start)
+}
+
+func TestGravityEnergyDrift(t *testing.T) {
+	var tests = []struct {
+		name      string
+		distance  float64
+		speed     float64
+		softening float64
+		body      bool
+		tolerance float64
+	}{
+		{"circular", 300, 1, 0, false, 1e-3},
+		{"eccentric", 300, 0.8, 0, false, 1e-2},
+		{"softened", 300, 1, 20, false, 1e-3},
+		{"body", 300, 1, 0, true, 1e-3},
+		{"eccentric body", 400, 1.1, 10, true, 1e-2},
+	}
+	for _, test := range tests {
+		var instance, well = newWell(t, test.softening)
+		var at = geom.Vector{X: 1000 + test.distance, Y: 1000}
+		var velocity = well.OrbitalVelocity(instance.World, at, false).Multiply(test.speed)
+		var components = []ecstypes.Component{&Position{Vector: at}, &Motion{Velocity: velocity}}
+		if test.body {
+			components = append(components, &Body{Mass: 5})
+		}
+		var e ecstypes.EntityID = 1
+		if err := instance.AddEntity(e, components...); err != nil {
+			t.Fatal(err)
+		}
+		if drift := energyDrift(t, instance, well, e); drift > test.tolerance {
+			t.Errorf("%s: energy drifted by %.2g", test.name, drift)
+		}
+	}
+}
+
+func TestOrbitConservesEnergy(t *testing.T) {
+	for _, eccentricity := range []float64{0, 0.3, 0.7} {
+		var instance, well = newWell(t, 0)
+		var e ecstypes.EntityID = 1
+		var orbit = &Orbit{Primary: well.Entity, SemiMajorAxis: 400, Eccentricity: eccentricity, Periapsis: 1}
+		if err := instance.AddEntity(e, new(Position), new(Motion), orbit); err != nil {
+			t.Fatal(err)
+		}
+		// placed on the ellipse by the first tick
+		if err := instance.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		// vis-viva: the energy of an orbit depends only on its size
+		var want = -well.Mu() / (2 * orbit.SemiMajorAxis)
+		if got := energy(instance, well, e); math.Abs(got-want) > 1e-9*math.Abs(want) {
+			t.Errorf("e=%v: energy %v, want %v", eccentricity, got, want)
+		}
+		if drift := energyDrift(t, instance, well, e); drift > 1e-9 {
+			t.Errorf("e=%v: energy drifted by %.2g", eccentricity, drift)
+		}
+		// a period later it's back where it started
+		var period = orbit.Period(well.Mu())
+		var first, _ = orbit.State(well.Mu(), 0)
+		if later, _ := orbit.State(well.Mu(), period); later.Sub(first).Length() > 1e-6 {
+			t.Errorf("e=%v: at %v after a period, started at %v", eccentricity, later, first)
+		}
+	}
+}
+
+func TestOrbitalVelocityMatchesOrbit(t *testing.T) {
+	var instance, well = newWell(t, 0)
+	for _, retrograde := range []bool{false, true} {
+		var orbit = Orbit{SemiMajorAxis: 250, Periapsis: 2, Retrograde: retrograde}
+		var offset, velocity = orbit.State(well.Mu(), 0)
+		var at = well.Position.Vector.Add(offset)
+		if got := well.OrbitalVelocity(instance.World, at, retrograde); got.Sub(velocity).Length() > 1e-9 {
+			t.Errorf("retrograde %v: OrbitalVelocity %v, the orbit moves at %v", retrograde, got, velocity)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+)
+
+// Parent attaches an entity to another so that it follows it: turrets,
+// shields, engine flares, docked shuttles. The child's world Position is set
+// every tick from the parent's pose and the child's Offset and Angle in the
+// parent's frame. Parents may themselves have parents.
+//
+// When the parent is removed the child is detached: its Parent component is
+// removed and it stays where it was.
+type Parent struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+
+	Parent ecstypes.EntityID
+	Offset geom.Vector
+	Angle  geom.Angle
+}
+
+func (comp Parent) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Parent == entity {
+		return fmt.Errorf("entity %d can't be its own parent: %w", entity, ErrType)
+	}
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding parent: %w", err)
+	}
+	return nil
+}
+func (comp Parent) Update(_ ecstypes.SystemManager) (Parent, error) {
+	return comp, nil
+}
+func (comp Parent) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemParent
+}
+
+// Transform returns the child's pose in its parent's frame.
+func (comp *Parent) Transform() geom.Transform {
+	return geom.Pose(comp.Offset, comp.Angle)
+}
+
+// resolveTransforms sets the world Position and Motion of every child from
+// its parent's, parents first. A child whose parent has gone is detached, and a cycle of
+// parents is an error.
+func (i *Instance) resolveTransforms() error {
+	var resolved = make(map[ecstypes.EntityID]bool, i.Parent.Map.Len())
+	var orphans []ecstypes.EntityID
+	var resolve func(e ecstypes.EntityID, child *Parent) error
+	resolve = func(e ecstypes.EntityID, child *Parent) error {
+		if done, visiting := resolved[e]; visiting {
+			if !done {
+				return fmt.Errorf("entity %d is its own ancestor: %w", e, ErrType)
+			}
+			return nil
+		}
+		resolved[e] = false
+		parentPosition, ok := i.Position.GetComponent(child.Parent)
+		if !ok {
+			orphans = append(orphans, e)
+			resolved[e] = true
+			return nil
+		}
+		if grandparent, ok := i.Parent.GetComponent(child.Parent); ok {
+			if err := resolve(child.Parent, grandparent); err != nil {
+				return err
+			}
+		}
+		var pose = geom.Pose(parentPosition.Vector, parentPosition.Angle)
+		child.Position.Vector = i.World.Wrap(pose.Apply(child.Offset))
+		child.Position.Angle = (parentPosition.Angle + child.Angle).Normalize()
+		if motion, ok := i.Motion.GetComponent(e); ok {
+			if parentMotion, ok := i.Motion.GetComponent(child.Parent); ok {
+				motion.Velocity = parentMotion.Velocity
+				// a spinning parent swings the child round with it
+				if body, ok := i.Body.GetComponent(child.Parent); ok {
+					var arm = child.Offset.Rotate(parentPosition.Angle)
+					motion.Velocity = motion.Velocity.Add(arm.Perp().Multiply(body.AngularVelocity))
+				}
+			}
+		}
+		resolved[e] = true
+		return nil
+	}
+	var err error
+	i.Parent.EachSorted(func(e ecstypes.EntityID, child *Parent) {
+		if err == nil {
+			err = resolve(e, child)
+		}
+	})
+	for _, e := range orphans {
+		i.Detach(e)
+	}
+	return err
+}
+
+// Attach makes child follow parent from where it is now, working out its
+// Offset and Angle in the parent's frame the short way round the world.
+func (i *Instance) Attach(child, parent ecstypes.EntityID) error {
+	childPosition, ok := i.Position.GetComponent(child)
+	if !ok {
+		return fmt.Errorf("child %d has no Position: %w", child, vterr.ErrMissing)
+	}
+	parentPosition, ok := i.Position.GetComponent(parent)
+	if !ok {
+		return fmt.Errorf("parent %d has no Position: %w", parent, vterr.ErrMissing)
+	}
+	var offset = i.World.Delta(parentPosition.Vector, childPosition.Vector).Rotate(-parentPosition.Angle)
+	return Parent{
+		Parent: parent,
+		Offset: offset,
+		Angle:  parentPosition.Angle.Diff(childPosition.Angle),
+	}.Init(i, child)
+}
+
+// Detach frees child from its parent, leaving it where it is.
+func (i *Instance) Detach(child ecstypes.EntityID) {
+	i.Parent.RemoveComponent(child)
+}
@@ -0,0 +1,116 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"testing"
+)
+
+func addAt(t *testing.T, instance *Instance, e ecstypes.EntityID, at geom.Vector, angle geom.Angle, extra ...ecstypes.Component) {
+	t.Helper()
+	var components = append([]ecstypes.Component{&Position{Vector: at, Angle: angle}, new(Motion)}, extra...)
+	if err := instance.AddEntity(e, components...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveTransforms(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	addAt(t, instance, 0, geom.Vector{X: 995, Y: 500}, math.Pi/2)
+	// listed before its parent, so it must wait for it
+	addAt(t, instance, 1, geom.Vector{}, 0, &Parent{Parent: 2, Offset: geom.Vector{X: 5}, Angle: math.Pi / 2})
+	addAt(t, instance, 2, geom.Vector{}, 0, &Parent{Parent: 0, Offset: geom.Vector{Y: -10}})
+	if err := instance.resolveTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	// the parent faces down the screen, so its -y is +x, across the edge
+	var tests = []struct {
+		e     ecstypes.EntityID
+		at    geom.Vector
+		angle geom.Angle
+	}{
+		{2, geom.Vector{X: 5, Y: 500}, math.Pi / 2},
+		{1, geom.Vector{X: 5, Y: 505}, -math.Pi},
+	}
+	for _, test := range tests {
+		var position, _ = instance.Position.GetComponent(test.e)
+		if position.Vector.Sub(test.at).Length() > 1e-9 || !near(float64(position.Angle.Diff(test.angle)), 0) {
+			t.Errorf("entity %d at %v facing %v, want %v facing %v", test.e, position.Vector, position.Angle, test.at, test.angle)
+		}
+	}
+}
+
+func TestChildVelocity(t *testing.T) {
+	var tests = []struct {
+		name   string
+		angle  geom.Angle
+		spin   float64
+		offset geom.Vector
+		want   geom.Vector
+	}{
+		{"still", 0, 0, geom.Vector{X: 50}, geom.Vector{X: 10}},
+		{"spinning", 0, 2, geom.Vector{X: 50}, geom.Vector{X: 10, Y: 100}},
+		{"spinning backwards", 0, -2, geom.Vector{Y: 50}, geom.Vector{X: 110}},
+		{"turned", math.Pi / 2, 2, geom.Vector{X: 50}, geom.Vector{X: -90}},
+	}
+	for _, test := range tests {
+		var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+		addAt(t, instance, 0, geom.Vector{X: 500, Y: 500}, test.angle, &Body{Mass: 1, Inertia: 1, AngularVelocity: test.spin})
+		addAt(t, instance, 1, geom.Vector{}, 0, &Parent{Parent: 0, Offset: test.offset})
+		var parent, _ = instance.Motion.GetComponent(0)
+		parent.Velocity = geom.Vector{X: 10}
+		if err := instance.resolveTransforms(); err != nil {
+			t.Fatal(err)
+		}
+		if motion, _ := instance.Motion.GetComponent(1); motion.Velocity.Sub(test.want).Length() > 1e-9 {
+			t.Errorf("%s: child moving %v, want %v", test.name, motion.Velocity, test.want)
+		}
+	}
+}
+
+func TestParentCycles(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	if err := instance.AddEntity(0, new(Position), &Parent{Parent: 0}); !errors.Is(err, ErrType) {
+		t.Errorf("own parent: got %v, want ErrType", err)
+	}
+	addAt(t, instance, 1, geom.Vector{}, 0, &Parent{Parent: 2})
+	addAt(t, instance, 2, geom.Vector{}, 0, &Parent{Parent: 3})
+	addAt(t, instance, 3, geom.Vector{}, 0, &Parent{Parent: 1})
+	if err := instance.resolveTransforms(); !errors.Is(err, ErrType) {
+		t.Errorf("cycle of three: got %v, want ErrType", err)
+	}
+}
+
+func TestAttachAndRemove(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	addAt(t, instance, 0, geom.Vector{X: 10, Y: 10}, 1)
+	addAt(t, instance, 1, geom.Vector{X: 990, Y: 20}, 2)
+	if err := instance.Attach(1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.resolveTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	var child, _ = instance.Position.GetComponent(1)
+	if instance.World.Distance(child.Vector, geom.Vector{X: 990, Y: 20}) > 1e-9 || !near(float64(child.Angle), 2) {
+		t.Fatalf("attaching moved the child to %v facing %v", child.Vector, child.Angle)
+	}
+
+	var parent, _ = instance.Position.GetComponent(0)
+	parent.Vector.X += 50
+	instance.RemoveEntity(0)
+	if _, ok := instance.Parent.GetComponent(1); ok {
+		t.Fatal("child still attached to a removed parent")
+	}
+	if err := instance.resolveTransforms(); err != nil {
+		t.Fatal(err)
+	}
+	if child.X != 990 {
+		t.Errorf("detached child moved to %v", child.Vector)
+	}
+	if _, ok := instance.Position.GetComponent(0); ok {
+		t.Error("removed entity still has a Position")
+	}
+}
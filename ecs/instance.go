@@ -4,8 +4,10 @@ import (
 	"errors"
 	"github.com/StCredZero/vectrek/constants"
 	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
 	"github.com/StCredZero/vectrek/slices"
-	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/StCredZero/vectrek/spatial"
+	"math/rand/v2"
 	"sort"
 	"time"
 )
@@ -13,7 +15,23 @@ import (
 type Parameters struct {
 	ScreenWidth  float64
 	ScreenHeight float64
+
+	// Deterministic makes the Instance reproducible: the RNG is seeded from
+	// Seed and a checksum of the world is taken after every tick.
+	Deterministic bool
+	Seed          uint64
+	// FixedPoint rounds positions and velocities to geom.Fixed after every
+	// tick, so that last-bit float differences between platforms can't
+	// accumulate.
+	FixedPoint bool
+	// TickRate is the number of simulation ticks per second. Zero means
+	// DefaultTickRate.
+	TickRate int
+	// GalaxySeed, if not zero, is what a Galaxy's sectors are generated
+	// from. See GenerateSector.
+	GalaxySeed uint64
 }
+
 type Instance struct {
 	Entities map[ecstypes.EntityID]struct{}
 
@@ -26,18 +44,74 @@ type Instance struct {
 	Player       *SMSystem[Player]
 	SyncReceiver *SMSystem[SyncReceiver]
 	SyncSender   *SMSystem[SyncSender]
+	Collider     *SMSystem[Collider]
+	Body         *SMSystem[Body]
+	GravityWell  *SMSystem[GravityWell]
+	Orbit        *SMSystem[Orbit]
+	Parent       *SMSystem[Parent]
+	Weapons      *SMSystem[Weapons]
+	Torpedo      *SMSystem[Torpedo]
+	Shields      *SMSystem[Shields]
+	Hull         *SMSystem[Hull]
+	Reactor      *SMSystem[Reactor]
+	Warp         *SMSystem[Warp]
+	AI           *SMSystem[AI]
+	Brain        *SMSystem[Brain]
+	Fleet        *SMSystem[Fleet]
+	Wingman      *SMSystem[Wingman]
+	Replicated   *SMSystem[Replicated]
 
 	Counter    uint64
 	Parameters Parameters
+	// NextEntity is the lowest entity ID never used, below
+	// GeneratedEntities.
+	NextEntity ecstypes.EntityID
+	// Allocator, if set, hands out entity IDs shared with other Instances,
+	// so that entities can move between them.
+	Allocator *EntityAllocator
+	// Sector is the sector of a Galaxy the Instance simulates or, on a
+	// client, shows.
+	Sector SectorCoord
+	// Client marks an Instance that shows a game simulated elsewhere. A
+	// client takes only what its server sends, and an authoritative Instance
+	// only its players' input.
+	Client bool
+
+	World        geom.Torus
+	Clock        *Clock
+	RandSource   *rand.PCG
+	Rand         *rand.Rand
+	LastChecksum uint64
+
+	// ShipClasses are the classes Spawn builds ships of, by name.
+	ShipClasses map[string]ShipClass
+	// Behaviors are the trees Brains think with, by name.
+	Behaviors map[string]BehaviorTree
+
+	// Damage lists the damage dealt this tick.
+	Damage []ecstypes.Damage
+	// Events lists the broadcasts applied this tick, such as Destroyed.
+	Events []ecstypes.ComponentMessage
+	// Beams are the phaser beams being drawn.
+	Beams     []Beam
+	broadcast []ecstypes.ComponentMessage
+
+	// Collisions lists every collision found this tick.
+	Collisions    []ecstypes.Collision
+	collisions    map[ecstypes.EntityID][]ecstypes.Collision
+	collisionGrid *spatial.Grid
+	spatialIndex  *spatial.Index[ecstypes.EntityID]
 
 	Pipe     *Pipe
 	Receiver ecstypes.Receiver
 	Sender   ecstypes.Sender
+	Recorder Recorder
 }
 
 func NewInstance(parameters Parameters) *Instance {
 	var result = new(Instance)
 	result.Entities = make(map[ecstypes.EntityID]struct{})
+	result.collisions = make(map[ecstypes.EntityID][]ecstypes.Collision)
 	result.Position = NewSMSystem[Position](func(each Position) (Position, error) {
 		return each.Update(result)
 	})
@@ -57,7 +131,70 @@ func NewInstance(parameters Parameters) *Instance {
 		return each.Update(result)
 	})
 	result.SyncSender = NewSMSystem[SyncSender](func(each SyncSender) (SyncSender, error) { return each.Update(result) })
+	result.Collider = NewSMSystem[Collider](func(each Collider) (Collider, error) {
+		return each.Update(result)
+	})
+	result.Body = NewSMSystem[Body](func(each Body) (Body, error) {
+		return each.Update(result)
+	})
+	result.GravityWell = NewSMSystem[GravityWell](func(each GravityWell) (GravityWell, error) {
+		return each.Update(result)
+	})
+	result.Orbit = NewSMSystem[Orbit](func(each Orbit) (Orbit, error) {
+		return each.Update(result)
+	})
+	result.Parent = NewSMSystem[Parent](func(each Parent) (Parent, error) {
+		return each.Update(result)
+	})
+	result.Weapons = NewSMSystem[Weapons](func(each Weapons) (Weapons, error) {
+		return each.Update(result)
+	})
+	result.Torpedo = NewSMSystem[Torpedo](func(each Torpedo) (Torpedo, error) {
+		return each.Update(result)
+	})
+	result.Shields = NewSMSystem[Shields](func(each Shields) (Shields, error) {
+		return each.Update(result)
+	})
+	result.Hull = NewSMSystem[Hull](func(each Hull) (Hull, error) {
+		return each.Update(result)
+	})
+	result.Reactor = NewSMSystem[Reactor](func(each Reactor) (Reactor, error) {
+		return each.Update(result)
+	})
+	result.Warp = NewSMSystem[Warp](func(each Warp) (Warp, error) {
+		return each.Update(result)
+	})
+	result.AI = NewSMSystem[AI](func(each AI) (AI, error) {
+		return each.Update(result)
+	})
+	result.Brain = NewSMSystem[Brain](func(each Brain) (Brain, error) {
+		return each.Update(result)
+	})
+	result.Fleet = NewSMSystem[Fleet](func(each Fleet) (Fleet, error) {
+		return each.Update(result)
+	})
+	result.Wingman = NewSMSystem[Wingman](func(each Wingman) (Wingman, error) {
+		return each.Update(result)
+	})
+	result.Replicated = NewSMSystem[Replicated](func(each Replicated) (Replicated, error) {
+		return each.Update(result)
+	})
 	result.Parameters = parameters
+	result.World = geom.Torus{Width: parameters.ScreenWidth, Height: parameters.ScreenHeight}
+	if result.World.Width == 0 || result.World.Height == 0 {
+		result.World = geom.Torus{Width: constants.ScreenWidth, Height: constants.ScreenHeight}
+	}
+	result.spatialIndex = spatial.NewIndex[ecstypes.EntityID](result.World, spatialCellSize)
+	if result.Parameters.TickRate == 0 {
+		result.Parameters.TickRate = DefaultTickRate
+	}
+	result.Clock = NewClock(result.Parameters.TickRate)
+	var seed = parameters.Seed
+	if !parameters.Deterministic {
+		seed = uint64(time.Now().UnixNano())
+	}
+	result.RandSource = rand.NewPCG(seed, seed)
+	result.Rand = rand.New(result.RandSource)
 	return result
 }
 func (i *Instance) GetSender() ecstypes.Sender {
@@ -75,8 +212,17 @@ func (i *Instance) SetReceiver(pipe ecstypes.Receiver) {
 func (i *Instance) GetName() string {
 	return i.Name
 }
+func (i *Instance) GetRand() *rand.Rand {
+	return i.Rand
+}
+func (i *Instance) GetWorld() geom.Torus {
+	return i.World
+}
+func (i *Instance) GetTimeStep() float64 {
+	return 1 / float64(i.Parameters.TickRate)
+}
 func (i *Instance) RunServer(done chan bool) {
-	ticker := time.NewTicker(16667 * time.Microsecond)
+	ticker := time.NewTicker(i.Clock.TickDuration)
 	defer ticker.Stop()
 
 	for {
@@ -91,56 +237,206 @@ func (i *Instance) RunServer(done chan bool) {
 func (i *Instance) GetCounter() uint64 {
 	return i.Counter
 }
-func (i *Instance) Update() error {
-	i.Counter++
 
-	var hasMessage bool
-	var msg ecstypes.ComponentMessage
+// Update runs as many ticks as the Clock says are due, which may be none when
+// called faster than the tick rate. Messages received are applied on the first
+// of them, even when catching up after a stall: they arrived without ticks
+// to tell them apart, so a press and release queued together cancel out
+// before the rest of the burst runs on the last input.
+func (i *Instance) Update() error {
+	var ticks = i.Clock.Advance(time.Now())
+	if ticks == 0 {
+		return nil
+	}
+	var msgs []ecstypes.ComponentMessage
 	for {
-		if msg, hasMessage = i.Receiver.Receive(); !hasMessage {
+		msg, hasMessage := i.Receiver.Receive()
+		if !hasMessage {
 			break
 		}
-		switch obj := msg.Payload.(type) {
-		case HelmInput:
-			if helm, ok := i.Helm.GetComponent(msg.Entity); ok {
-				helm.Input = obj
-			}
-		case SyncInput:
-			if sync, ok := i.SyncReceiver.GetComponent(msg.Entity); ok {
-				sync.Input <- obj
-			}
-		default:
-		}
+		msgs = append(msgs, msg)
 	}
+	var errs []error
+	for ; ticks > 0; ticks-- {
+		errs = append(errs, i.Step(msgs))
+		msgs = nil
+	}
+	return errors.Join(errs...)
+}
+
+// Step advances the simulation one tick, applying msgs in order first.
+// Unlike Update it doesn't poll the Receiver, so a caller that supplies the
+// same messages per tick gets the same world.
+func (i *Instance) Step(msgs []ecstypes.ComponentMessage) error {
+	i.Counter++
+	i.Damage = i.Damage[:0]
+	i.Events = i.Events[:0]
+	i.fadeBeams()
 
 	// systems must be executed in reverse dependency order
 	var errs []error
+	for _, msg := range msgs {
+		errs = append(errs, i.HandleMessage(msg))
+	}
+	if i.Recorder != nil {
+		errs = append(errs, i.Recorder.RecordTick(i.Counter, msgs))
+	}
+	errs = append(errs, i.Position.Iterate()...)
+	errs = append(errs, i.Reactor.Iterate()...)
+	errs = append(errs, i.runBehaviors())
+	i.flyFormations()
+	errs = append(errs, i.AI.Iterate()...)
+	if i.Client {
+		i.countDownWarp()
+	} else {
+		errs = append(errs, i.Warp.Iterate()...)
+		i.interdictWarp()
+	}
 	errs = append(errs, i.Helm.Iterate()...)
+	i.applyGravity()
+	errs = append(errs, i.Body.Iterate()...)
 	errs = append(errs, i.Motion.Iterate()...)
+	errs = append(errs, i.Orbit.Iterate()...)
+	errs = append(errs, i.resolveTransforms())
+	i.detectCollisions()
+	i.resolveCollisions()
+	i.updateSpatialIndex()
+	errs = append(errs, i.Weapons.Iterate()...)
+	errs = append(errs, i.Torpedo.Iterate()...)
+	i.applyDamage()
+	errs = append(errs, i.Shields.Iterate()...)
 	//errs = append(errs, i.Sprite.Iterate()...)
 	errs = append(errs, i.Player.Iterate()...)
 	errs = append(errs, i.SyncSender.Iterate()...)
+	errs = append(errs, i.Replicated.Iterate()...)
 	errs = append(errs, i.SyncReceiver.Iterate()...)
+	errs = append(errs, i.flushBroadcast())
+
+	if i.Parameters.FixedPoint {
+		i.quantize()
+	}
+	if i.Parameters.Deterministic {
+		i.LastChecksum = i.Checksum()
+	}
 
 	errs = slices.Select(errs, func(err error) bool {
 		return err != nil
 	})
 	return errors.Join(errs...)
 }
-func (i *Instance) Draw(screen *ebiten.Image) {
-	i.Sprite.doIterate(func(sprite Sprite) (Sprite, error) {
-		sprite.Draw(screen, false, false)
-		return sprite, nil
-	})
+
+// HandleMessage applies a message received from outside. An authoritative
+// Instance takes only players' input; anything else is dropped, so a client
+// can't despawn or spawn things on the server.
+func (i *Instance) HandleMessage(msg ecstypes.ComponentMessage) error {
+	if i.Client {
+		return i.handleServerMessage(msg)
+	}
+	switch obj := msg.Payload.(type) {
+	case HelmInput:
+		if helm, ok := i.Helm.GetComponent(msg.Entity); ok {
+			helm.Input = obj
+		}
+	case PowerInput:
+		if _, ok := i.Reactor.GetComponent(msg.Entity); ok {
+			i.Broadcast(ecstypes.ComponentMessage{
+				Entity:  msg.Entity,
+				Payload: PowerAllocation{Allocation: NormalizeAllocation(obj.Allocation)},
+			})
+		}
+	case SquadCommand:
+		i.commandSquad(msg.Entity, obj)
+	}
+	return nil
+}
+
+// handleServerMessage applies on a Client what its server sends. Broadcasts
+// only set up what the client needs to draw them.
+func (i *Instance) handleServerMessage(msg ecstypes.ComponentMessage) error {
+	switch obj := msg.Payload.(type) {
+	case SyncInput:
+		if sync, ok := i.SyncReceiver.GetComponent(msg.Entity); ok {
+			sync.Input <- obj
+		}
+	case ShipState:
+		return i.applyShipState(msg.Entity, obj)
+	case SectorChanged:
+		// only for the ship played here
+		if _, ok := i.Player.GetComponent(msg.Entity); ok {
+			return i.changeSector(obj.Sector)
+		}
+	case PhaserBeam, TorpedoLaunch, Despawned, Destroyed, PowerAllocation, WarpStatus, SquadOrders:
+		i.Events = append(i.Events, msg)
+		return i.applyEvent(msg, false)
+	}
+	return nil
+}
+
+// applyEvent applies a broadcast. Where the game is simulated, spawned
+// entities are given everything needed to simulate them too.
+func (i *Instance) applyEvent(msg ecstypes.ComponentMessage, simulated bool) error {
+	switch obj := msg.Payload.(type) {
+	case PhaserBeam:
+		i.Beams = append(i.Beams, Beam{PhaserBeam: obj, Remaining: beamDuration})
+	case TorpedoLaunch:
+		return i.launchTorpedo(msg.Entity, obj, simulated)
+	case Despawned:
+		i.RemoveEntity(msg.Entity)
+	case PowerAllocation:
+		if reactor, ok := i.Reactor.GetComponent(msg.Entity); ok {
+			reactor.Allocation = obj.Allocation
+		}
+	case WarpStatus:
+		if warp, ok := i.Warp.GetComponent(msg.Entity); ok {
+			warp.State, warp.Factor, warp.Remaining = obj.State, obj.Factor, obj.Remaining
+		}
+	case SquadOrders:
+		if fleet, ok := i.Fleet.GetComponent(msg.Entity); ok {
+			fleet.SquadOrders = obj
+		}
+	}
+	return nil
+}
+
+// camera returns the world point at the top left of the screen: the origin,
+// unless the world is larger than the screen and there is a player's ship to
+// center on.
+func (i *Instance) camera(alpha float64) (geom.Vector, bool) {
+	if i.World.Width <= constants.ScreenWidth && i.World.Height <= constants.ScreenHeight {
+		return geom.Vector{}, false
+	}
+	for _, e := range i.Player.Map.Keys() {
+		if position, ok := i.Position.GetComponent(ecstypes.EntityID(e)); ok {
+			center, _ := position.Interpolate(i.World, alpha)
+			return center.Sub(geom.Vector{X: constants.ScreenWidth / 2, Y: constants.ScreenHeight / 2}), true
+		}
+	}
+	return geom.Vector{}, false
 }
 func (i *Instance) Layout(outsideWidth, outsideHeight int) (int, int) {
 	return constants.ScreenWidth, constants.ScreenHeight
 }
+func (i *Instance) sortedEntities() []ecstypes.EntityID {
+	var entities = make([]ecstypes.EntityID, 0, len(i.Entities))
+	for entity := range i.Entities {
+		entities = append(entities, entity)
+	}
+	sort.Slice(entities, func(a, b int) bool {
+		return entities[a] < entities[b]
+	})
+	return entities
+}
 func (i *Instance) AddEntity(
 	entity ecstypes.EntityID,
 	components ...ecstypes.Component,
 ) error {
 	i.Entities[entity] = struct{}{}
+	if entity < GeneratedEntities {
+		i.NextEntity = max(i.NextEntity, entity+1)
+		if i.Allocator != nil {
+			i.Allocator.Next = max(i.Allocator.Next, entity+1)
+		}
+	}
 	sort.Slice(components, func(i, j int) bool {
 		return components[i].SystemID() < components[j].SystemID()
 	})
@@ -149,5 +445,82 @@ func (i *Instance) AddEntity(
 			return err
 		}
 	}
+	if position, ok := i.Position.GetComponent(entity); ok {
+		i.indexEntity(entity, position)
+	}
 	return nil
 }
+
+// RemoveEntity deletes an entity and all its components. Its children are
+// detached, staying where they are.
+func (i *Instance) RemoveEntity(entity ecstypes.EntityID) {
+	var children []ecstypes.EntityID
+	i.Parent.EachSorted(func(child ecstypes.EntityID, comp *Parent) {
+		if comp.Parent == entity {
+			children = append(children, child)
+		}
+	})
+	for _, child := range children {
+		i.Detach(child)
+	}
+	i.removeComponents(entity)
+	i.spatialIndex.Remove(entity)
+	delete(i.collisions, entity)
+	delete(i.Entities, entity)
+}
+
+func (i *Instance) NewEntity() ecstypes.EntityID {
+	if i.Allocator != nil {
+		i.NextEntity = max(i.NextEntity, i.Allocator.Next)
+	}
+	var e = i.NextEntity
+	i.NextEntity++
+	if i.Allocator != nil {
+		i.Allocator.Next = i.NextEntity
+	}
+	return e
+}
+
+// EntityAllocator hands out entity IDs unique across the Instances sharing
+// it, such as the sectors of a Galaxy.
+type EntityAllocator struct {
+	Next ecstypes.EntityID
+}
+
+func (i *Instance) Broadcast(msg ecstypes.ComponentMessage) {
+	i.broadcast = append(i.broadcast, msg)
+}
+
+func (i *Instance) Despawn(e ecstypes.EntityID) {
+	i.Broadcast(ecstypes.ComponentMessage{Entity: e, Payload: Despawned{}})
+}
+
+func (i *Instance) ApplyDamage(damage ecstypes.Damage) {
+	i.Damage = append(i.Damage, damage)
+}
+
+// flushBroadcast applies this tick's broadcasts, simulating what they spawn,
+// and sends them on to clients. A client never sends them: its Sender goes
+// to the server.
+func (i *Instance) flushBroadcast() error {
+	var errs []error
+	for _, msg := range i.broadcast {
+		i.Events = append(i.Events, msg)
+		errs = append(errs, i.applyEvent(msg, true))
+		if i.Sender != nil && !i.Client {
+			i.Sender.Send(msg)
+		}
+	}
+	i.broadcast = i.broadcast[:0]
+	return errors.Join(errs...)
+}
+
+func (i *Instance) fadeBeams() {
+	var dt = i.GetTimeStep()
+	i.Beams = slices.Select(i.Beams, func(beam Beam) bool {
+		return beam.Remaining > 0
+	})
+	for index := range i.Beams {
+		i.Beams[index].Remaining -= dt
+	}
+}
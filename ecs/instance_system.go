@@ -21,6 +21,38 @@ func (i *Instance) GetComponent(systemID ecstypes.SystemID, e ecstypes.EntityID)
 		return i.SyncReceiver.GetComponent(e)
 	case ecstypes.SystemSyncSender:
 		return i.SyncSender.GetComponent(e)
+	case ecstypes.SystemCollider:
+		return i.Collider.GetComponent(e)
+	case ecstypes.SystemBody:
+		return i.Body.GetComponent(e)
+	case ecstypes.SystemGravityWell:
+		return i.GravityWell.GetComponent(e)
+	case ecstypes.SystemOrbit:
+		return i.Orbit.GetComponent(e)
+	case ecstypes.SystemParent:
+		return i.Parent.GetComponent(e)
+	case ecstypes.SystemWeapons:
+		return i.Weapons.GetComponent(e)
+	case ecstypes.SystemTorpedo:
+		return i.Torpedo.GetComponent(e)
+	case ecstypes.SystemShields:
+		return i.Shields.GetComponent(e)
+	case ecstypes.SystemHull:
+		return i.Hull.GetComponent(e)
+	case ecstypes.SystemReactor:
+		return i.Reactor.GetComponent(e)
+	case ecstypes.SystemWarp:
+		return i.Warp.GetComponent(e)
+	case ecstypes.SystemAI:
+		return i.AI.GetComponent(e)
+	case ecstypes.SystemBrain:
+		return i.Brain.GetComponent(e)
+	case ecstypes.SystemFleet:
+		return i.Fleet.GetComponent(e)
+	case ecstypes.SystemWingman:
+		return i.Wingman.GetComponent(e)
+	case ecstypes.SystemReplicated:
+		return i.Replicated.GetComponent(e)
 	default:
 		return nil, false
 	}
@@ -39,6 +71,38 @@ func (i *Instance) GetSystem(id ecstypes.SystemID) (ecstypes.System, error) {
 		return i.SyncReceiver, nil
 	case ecstypes.SystemSyncSender:
 		return i.SyncSender, nil
+	case ecstypes.SystemCollider:
+		return i.Collider, nil
+	case ecstypes.SystemBody:
+		return i.Body, nil
+	case ecstypes.SystemGravityWell:
+		return i.GravityWell, nil
+	case ecstypes.SystemOrbit:
+		return i.Orbit, nil
+	case ecstypes.SystemParent:
+		return i.Parent, nil
+	case ecstypes.SystemWeapons:
+		return i.Weapons, nil
+	case ecstypes.SystemTorpedo:
+		return i.Torpedo, nil
+	case ecstypes.SystemShields:
+		return i.Shields, nil
+	case ecstypes.SystemHull:
+		return i.Hull, nil
+	case ecstypes.SystemReactor:
+		return i.Reactor, nil
+	case ecstypes.SystemWarp:
+		return i.Warp, nil
+	case ecstypes.SystemAI:
+		return i.AI, nil
+	case ecstypes.SystemBrain:
+		return i.Brain, nil
+	case ecstypes.SystemFleet:
+		return i.Fleet, nil
+	case ecstypes.SystemWingman:
+		return i.Wingman, nil
+	case ecstypes.SystemReplicated:
+		return i.Replicated, nil
 	default:
 		return nil, fmt.Errorf("invalid system id: %w", ErrType)
 	}
@@ -73,8 +137,99 @@ func (i *Instance) AddComponent(e ecstypes.EntityID, component ecstypes.Componen
 		if err := i.SyncSender.AddComponent(e, c); err != nil {
 			return err
 		}
+	case Collider:
+		if err := i.Collider.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Body:
+		if err := i.Body.AddComponent(e, c); err != nil {
+			return err
+		}
+	case GravityWell:
+		if err := i.GravityWell.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Orbit:
+		if err := i.Orbit.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Parent:
+		if err := i.Parent.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Weapons:
+		if err := i.Weapons.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Torpedo:
+		if err := i.Torpedo.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Shields:
+		if err := i.Shields.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Hull:
+		if err := i.Hull.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Reactor:
+		if err := i.Reactor.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Warp:
+		if err := i.Warp.AddComponent(e, c); err != nil {
+			return err
+		}
+	case AI:
+		if err := i.AI.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Brain:
+		if err := i.Brain.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Fleet:
+		if err := i.Fleet.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Wingman:
+		if err := i.Wingman.AddComponent(e, c); err != nil {
+			return err
+		}
+	case Replicated:
+		if err := i.Replicated.AddComponent(e, c); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("invalid system type %v: %w", component, ErrType)
 	}
 	return nil
 }
+
+// removeComponents deletes every component of e.
+func (i *Instance) removeComponents(e ecstypes.EntityID) {
+	i.Position.RemoveComponent(e)
+	i.Motion.RemoveComponent(e)
+	i.Helm.RemoveComponent(e)
+	i.Sprite.RemoveComponent(e)
+	i.Player.RemoveComponent(e)
+	i.SyncReceiver.RemoveComponent(e)
+	i.SyncSender.RemoveComponent(e)
+	i.Collider.RemoveComponent(e)
+	i.Body.RemoveComponent(e)
+	i.GravityWell.RemoveComponent(e)
+	i.Orbit.RemoveComponent(e)
+	i.Parent.RemoveComponent(e)
+	i.Weapons.RemoveComponent(e)
+	i.Torpedo.RemoveComponent(e)
+	i.Shields.RemoveComponent(e)
+	i.Hull.RemoveComponent(e)
+	i.Reactor.RemoveComponent(e)
+	i.Warp.RemoveComponent(e)
+	i.AI.RemoveComponent(e)
+	i.Brain.RemoveComponent(e)
+	i.Fleet.RemoveComponent(e)
+	i.Wingman.RemoveComponent(e)
+	i.Replicated.RemoveComponent(e)
+}
@@ -24,3 +24,8 @@ func (p *Pipe) Receive() (ecstypes.ComponentMessage, bool) {
 		return ecstypes.ComponentMessage{}, false
 	}
 }
+
+// Discard is a Sender that drops every message.
+type Discard struct{}
+
+func (Discard) Send(_ ecstypes.ComponentMessage) {}
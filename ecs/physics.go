@@ -0,0 +1,173 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+)
+
+const (
+	// positionCorrection is the fraction of overlap removed each tick, and
+	// positionSlop the overlap left alone, so resting contacts don't jitter.
+	positionCorrection = 0.8
+	positionSlop       = 0.01
+)
+
+// Body makes an entity a rigid body. Forces and torques applied during a tick
+// are accumulated and integrated into Motion.Velocity and AngularVelocity
+// before Motion moves the entity. Rates are per second.
+//
+// A Mass of zero makes the body immovable, for stations and asteroids that
+// others bounce off. An Inertia of zero is filled in from the entity's
+// Collider, as a uniform plate of its Outline or else a disc.
+type Body struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+	Motion   *Motion   `json:"-"`
+
+	Mass    float64
+	Inertia float64
+	// AngularVelocity is in radians per second.
+	AngularVelocity float64
+	// LinearDamping and AngularDamping are the fractions of velocity lost
+	// per second.
+	LinearDamping  float64
+	AngularDamping float64
+	// Restitution is the bounciness of collisions, from 0 to 1.
+	Restitution float64
+	// MaxSpeed clamps the speed; zero means MaxVelocity.
+	MaxSpeed float64
+
+	Force  geom.Vector
+	Torque float64
+}
+
+func (comp Body) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Motion, err = GetComponent[Motion](sm, entity); comp.Motion == nil {
+		return fmt.Errorf("no Motion found: %w", vterr.ErrMissing)
+	}
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if comp.Inertia == 0 && comp.Mass > 0 {
+		if collider, _ := GetComponent[Collider](sm, entity); collider != nil && len(collider.Outline) >= 3 {
+			comp.Inertia = collider.Outline.Inertia(comp.Mass)
+		} else if collider != nil {
+			var radius = collider.BoundingRadius()
+			comp.Inertia = comp.Mass * radius * radius / 2
+		}
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding body: %w", err)
+	}
+	return nil
+}
+func (comp Body) Update(sm ecstypes.SystemManager) (Body, error) {
+	// at warp the Helm moves the ship directly
+	warp, err := GetComponent[Warp](sm, comp.Entity)
+	if err != nil {
+		return comp, err
+	}
+	if warp != nil && warp.Engaged() {
+		comp.AngularVelocity = 0
+		comp.Force = geom.Vector{}
+		comp.Torque = 0
+		return comp, nil
+	}
+	var dt = sm.GetTimeStep()
+	var velocity = comp.Motion.Velocity.Add(comp.Force.Multiply(comp.InverseMass() * dt))
+	velocity = velocity.Multiply(max(0, 1-comp.LinearDamping*dt))
+	var maxSpeed = comp.MaxSpeed
+	if maxSpeed == 0 {
+		maxSpeed = MaxVelocity
+	}
+	comp.Motion.Velocity = velocity.ClampLength(maxSpeed)
+
+	comp.AngularVelocity += comp.Torque * comp.InverseInertia() * dt
+	comp.AngularVelocity *= max(0, 1-comp.AngularDamping*dt)
+	comp.Position.Angle = (comp.Position.Angle + geom.Angle(comp.AngularVelocity*dt)).Normalize()
+
+	comp.Force = geom.Vector{}
+	comp.Torque = 0
+	return comp, nil
+}
+func (comp Body) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemBody
+}
+
+func (comp *Body) InverseMass() float64 {
+	if comp.Mass == 0 {
+		return 0
+	}
+	return 1 / comp.Mass
+}
+
+func (comp *Body) InverseInertia() float64 {
+	if comp.Inertia == 0 {
+		return 0
+	}
+	return 1 / comp.Inertia
+}
+
+func (comp *Body) ApplyForce(force geom.Vector) {
+	comp.Force = comp.Force.Add(force)
+}
+
+func (comp *Body) ApplyTorque(torque float64) {
+	comp.Torque += torque
+}
+
+// ApplyForceAt applies force at offset from the body's center, adding the
+// torque it causes.
+func (comp *Body) ApplyForceAt(force geom.Vector, offset geom.Vector) {
+	comp.ApplyForce(force)
+	comp.ApplyTorque(offset.Cross(force))
+}
+
+// ApplyImpulse changes velocity immediately, as a collision does. offset is
+// where the impulse acts, relative to the body's center.
+func (comp *Body) ApplyImpulse(impulse geom.Vector, offset geom.Vector) {
+	comp.Motion.Velocity = comp.Motion.Velocity.Add(impulse.Multiply(comp.InverseMass()))
+	comp.AngularVelocity += offset.Cross(impulse) * comp.InverseInertia()
+}
+
+// velocityAt is the velocity of the point at offset from the center.
+func (comp *Body) velocityAt(offset geom.Vector) geom.Vector {
+	return comp.Motion.Velocity.Add(offset.Perp().Multiply(comp.AngularVelocity))
+}
+
+// resolveCollisions bounces apart every colliding pair that both have a Body,
+// with an impulse along the contact normal, and pushes them out of overlap.
+func (i *Instance) resolveCollisions() {
+	for _, collision := range i.Collisions {
+		a, okA := i.Body.GetComponent(collision.Entity)
+		b, okB := i.Body.GetComponent(collision.Other)
+		if !okA || !okB {
+			continue
+		}
+		var inverseMass = a.InverseMass() + b.InverseMass()
+		if inverseMass == 0 {
+			continue
+		}
+		var normal = collision.Normal
+		var ra = i.World.Delta(a.Position.Vector, collision.Point)
+		var rb = i.World.Delta(b.Position.Vector, collision.Point)
+
+		var approach = b.velocityAt(rb).Sub(a.velocityAt(ra)).Dot(normal)
+		if approach < 0 {
+			var raN, rbN = ra.Cross(normal), rb.Cross(normal)
+			var denominator = inverseMass + raN*raN*a.InverseInertia() + rbN*rbN*b.InverseInertia()
+			var restitution = min(a.Restitution, b.Restitution)
+			var impulse = normal.Multiply(-(1 + restitution) * approach / denominator)
+			a.ApplyImpulse(impulse.Negate(), ra)
+			b.ApplyImpulse(impulse, rb)
+		}
+
+		var correction = normal.Multiply(max(collision.Depth-positionSlop, 0) * positionCorrection / inverseMass)
+		a.Position.Vector = i.World.Wrap(a.Position.Vector.Sub(correction.Multiply(a.InverseMass())))
+		b.Position.Vector = i.World.Wrap(b.Position.Vector.Add(correction.Multiply(b.InverseMass())))
+	}
+}
@@ -0,0 +1,142 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"math"
+)
+
+// PowerSystem is one of the ship systems a Reactor powers.
+type PowerSystem int
+
+const (
+	PowerEngines PowerSystem = iota
+	PowerShields
+	PowerWeapons
+	PowerSensors
+)
+
+// ThrustPower is the energy per second the engines draw at full thrust.
+const ThrustPower = 25.0
+
+// Reactor produces Output energy per second and shares it between the ship's
+// systems by Allocation, whose fractions add up to one. Each system's share
+// collects in its own pool, up to Capacity, and is drawn from there: the Helm
+// for thrust, Shields to regenerate and Weapons to recharge. A system drawing
+// more than its share runs at reduced effect. The sensors' share isn't drawn
+// but sets how far the ship sees, by its Level. Ships without a Reactor have
+// unlimited power.
+//
+// Allocation is changed by the player with a PowerInput; the server
+// broadcasts the result as a PowerAllocation.
+type Reactor struct {
+	Entity ecstypes.EntityID
+
+	Output     float64
+	Capacity   float64
+	Allocation [4]float64
+	Pools      [4]float64
+}
+
+// PowerInput asks to change a ship's allocation. It needn't be normalized.
+type PowerInput struct {
+	Allocation [4]float64
+}
+
+// PowerAllocation is the allocation a ship's reactor now has.
+type PowerAllocation struct {
+	Allocation [4]float64
+}
+
+// NewReactor returns a reactor whose even split covers full thrust, the
+// default weapons' recharge and four facings of shields regenerating at 5.
+func NewReactor() *Reactor {
+	return &Reactor{Output: 100}
+}
+
+func (comp Reactor) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	comp.Entity = entity
+	comp.Allocation = NormalizeAllocation(comp.Allocation)
+	if comp.Capacity == 0 {
+		comp.Capacity = comp.Output
+	}
+	if err := sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding reactor: %w", err)
+	}
+	return nil
+}
+func (comp Reactor) Update(sm ecstypes.SystemManager) (Reactor, error) {
+	var dt = sm.GetTimeStep()
+	for system, share := range comp.Allocation {
+		comp.Pools[system] = min(comp.Capacity, comp.Pools[system]+comp.Output*share*dt)
+	}
+	return comp, nil
+}
+func (comp Reactor) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemReactor
+}
+
+// Draw takes up to amount of energy from a system's pool and returns how
+// much it got.
+func (comp *Reactor) Draw(system PowerSystem, amount float64) float64 {
+	var granted = max(0, min(amount, comp.Pools[system]))
+	comp.Pools[system] -= granted
+	return granted
+}
+
+// Level returns a system's allocation relative to an even share: 1 when
+// power is split evenly, up to 4 with everything on one system.
+func (comp *Reactor) Level(system PowerSystem) float64 {
+	return comp.Allocation[system] * float64(len(comp.Allocation))
+}
+
+// sensorRange returns how far entity sees things reach away with evenly
+// split power: half as far with nothing on sensors, and up to two and a half
+// times as far with everything.
+func (i *Instance) sensorRange(entity ecstypes.EntityID, reach float64) float64 {
+	reactor, ok := i.Reactor.GetComponent(entity)
+	if !ok {
+		return reach
+	}
+	return reach * (1 + reactor.Level(PowerSensors)) / 2
+}
+
+// NormalizeAllocation scales an allocation so it adds up to one, treating
+// negative, infinite and NaN shares, as a client might send, as zero. All
+// zeros means an even split.
+func NormalizeAllocation(allocation [4]float64) [4]float64 {
+	var largest float64
+	for system, share := range allocation {
+		if !(share > 0) || math.IsInf(share, 1) {
+			share = 0
+		}
+		allocation[system] = share
+		largest = max(largest, share)
+	}
+	if largest == 0 {
+		return [4]float64{0.25, 0.25, 0.25, 0.25}
+	}
+	// scaled by the largest first, so huge shares can't add up to infinity
+	var total float64
+	for system := range allocation {
+		allocation[system] /= largest
+		total += allocation[system]
+	}
+	for system := range allocation {
+		allocation[system] /= total
+	}
+	return allocation
+}
+
+// drawPower draws amount from entity's reactor, or grants all of it if the
+// entity has none.
+func drawPower(sm ecstypes.SystemManager, entity ecstypes.EntityID, system PowerSystem, amount float64) (float64, error) {
+	reactor, err := GetComponent[Reactor](sm, entity)
+	if err != nil {
+		return 0, err
+	}
+	if reactor == nil {
+		return amount, nil
+	}
+	return reactor.Draw(system, amount), nil
+}
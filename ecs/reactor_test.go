@@ -0,0 +1,128 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"testing"
+)
+
+func TestNormalizeAllocation(t *testing.T) {
+	var tests = []struct {
+		name       string
+		allocation [4]float64
+		want       [4]float64
+	}{
+		{"already normal", [4]float64{0.4, 0.2, 0.2, 0.2}, [4]float64{0.4, 0.2, 0.2, 0.2}},
+		{"scaled", [4]float64{2, 1, 1, 0}, [4]float64{0.5, 0.25, 0.25, 0}},
+		{"negative is zero", [4]float64{-1, 1, 0, 1}, [4]float64{0, 0.5, 0, 0.5}},
+		{"all zero is even", [4]float64{}, [4]float64{0.25, 0.25, 0.25, 0.25}},
+		{"all negative is even", [4]float64{-1, -1, -1, -1}, [4]float64{0.25, 0.25, 0.25, 0.25}},
+		{"NaN is zero", [4]float64{math.NaN(), 1, 1, 0}, [4]float64{0, 0.5, 0.5, 0}},
+		{"infinity is zero", [4]float64{math.Inf(1), 1, math.Inf(-1), 1}, [4]float64{0, 0.5, 0, 0.5}},
+		{"all NaN is even", [4]float64{math.NaN(), math.NaN(), math.NaN(), math.NaN()}, [4]float64{0.25, 0.25, 0.25, 0.25}},
+		{"huge", [4]float64{math.MaxFloat64, math.MaxFloat64, 0, 0}, [4]float64{0.5, 0.5, 0, 0}},
+	}
+	for _, test := range tests {
+		if got := NormalizeAllocation(test.allocation); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSensorRange(t *testing.T) {
+	var tests = []struct {
+		name       string
+		allocation [4]float64
+		want       float64
+	}{
+		{"even", [4]float64{0.25, 0.25, 0.25, 0.25}, 400},
+		{"none", [4]float64{0.5, 0.5, 0, 0}, 200},
+		{"all", [4]float64{0, 0, 0, 1}, 1000},
+	}
+	for _, test := range tests {
+		var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+		err := instance.AddEntity(0, &Position{}, new(Motion), new(Helm), &Reactor{Output: 60, Capacity: 10, Allocation: test.allocation})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := instance.sensorRange(0, 400); !near(got, test.want) {
+			t.Errorf("%s: sees %v, want %v", test.name, got, test.want)
+		}
+	}
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	if got := instance.sensorRange(0, 400); got != 400 {
+		t.Errorf("without a reactor sees %v, want 400", got)
+	}
+}
+
+func TestReactorPools(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	var reactor = Reactor{Output: 60, Capacity: 10, Allocation: [4]float64{0.5, 0.5, 0, 0}}
+	for tick := 0; tick < DefaultTickRate; tick++ {
+		reactor, _ = reactor.Update(instance)
+	}
+	// 30 a second each for engines and shields, capped at 10
+	if reactor.Pools != [4]float64{10, 10, 0, 0} {
+		t.Fatalf("pools %v after a second", reactor.Pools)
+	}
+	if got := reactor.Draw(PowerEngines, 4); got != 4 || reactor.Pools[PowerEngines] != 6 {
+		t.Errorf("drew %v, leaving %v", got, reactor.Pools[PowerEngines])
+	}
+	if got := reactor.Draw(PowerWeapons, 4); got != 0 {
+		t.Errorf("drew %v from an empty pool", got)
+	}
+	if got := reactor.Draw(PowerShields, 40); got != 10 {
+		t.Errorf("drew %v from a pool of 10", got)
+	}
+	if got := reactor.Level(PowerEngines); got != 2 {
+		t.Errorf("engines at level %v with half the power", got)
+	}
+}
+
+func TestPowerStarvesThrust(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	for e, allocation := range [][4]float64{{1, 0, 0, 0}, {0, 1, 0, 0}} {
+		var at = geom.Vector{X: 100, Y: 100 + 200*float64(e)}
+		err := instance.AddEntity(ecstypes.EntityID(e), &Position{Vector: at}, new(Motion), new(Helm),
+			&Reactor{Output: 100, Allocation: allocation})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var thrust = []ecstypes.ComponentMessage{
+		{Entity: 0, Payload: HelmInput{Thrust: true}},
+		{Entity: 1, Payload: HelmInput{Thrust: true}},
+	}
+	for tick := 0; tick < 30; tick++ {
+		if err := instance.Step(thrust); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var powered, _ = instance.Motion.GetComponent(0)
+	var starved, _ = instance.Motion.GetComponent(1)
+	if powered.Velocity.Length() == 0 || starved.Velocity.Length() != 0 {
+		t.Fatalf("powered ship at %v, unpowered at %v", powered.Velocity, starved.Velocity)
+	}
+}
+
+func TestPowerInputBroadcast(t *testing.T) {
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	var sent = NewPipe()
+	instance.SetSender(sent)
+	if err := instance.AddEntity(0, NewReactor()); err != nil {
+		t.Fatal(err)
+	}
+	var input = ecstypes.ComponentMessage{Entity: 0, Payload: PowerInput{Allocation: [4]float64{3, 1, 0, 0}}}
+	if err := instance.Step([]ecstypes.ComponentMessage{input}); err != nil {
+		t.Fatal(err)
+	}
+	var want = [4]float64{0.75, 0.25, 0, 0}
+	if reactor, _ := instance.Reactor.GetComponent(0); reactor.Allocation != want {
+		t.Errorf("allocation %v, want %v", reactor.Allocation, want)
+	}
+	var msgs = drain(sent)
+	if len(msgs) != 1 || msgs[0].Payload != (PowerAllocation{Allocation: want}) {
+		t.Errorf("sent %+v, want the new allocation", msgs)
+	}
+}
@@ -0,0 +1,195 @@
+package ecs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// encodeValue writes v field by field, little-endian. Integers are widened to
+// 64 bits, strings and slices are length-prefixed, pointers carry a presence
+// byte, and struct fields that are unexported or tagged `json:"-"` are
+// skipped, so the binary world format saves exactly what the JSON one does.
+//
+// Decoding grows strings and slices as their contents are read, rather than
+// trusting a length prefix that may be corrupt.
+func encodeValue(w io.Writer, v any) error {
+	return encodeReflect(w, reflect.ValueOf(v))
+}
+
+func decodeValue(r io.Reader, ptr any) error {
+	return decodeReflect(r, reflect.ValueOf(ptr).Elem())
+}
+
+// decodeFields decodes the saved fields of the struct ptr points to, starting
+// at field index first.
+func decodeFields(r io.Reader, ptr any, first int) error {
+	var v = reflect.ValueOf(ptr).Elem()
+	for index := first; index < v.NumField(); index++ {
+		if !savedField(v.Type().Field(index)) {
+			continue
+		}
+		if err := decodeReflect(r, v.Field(index)); err != nil {
+			return fmt.Errorf("%s.%s: %w", v.Type().Name(), v.Type().Field(index).Name, err)
+		}
+	}
+	return nil
+}
+
+func savedField(field reflect.StructField) bool {
+	return field.IsExported() && field.Tag.Get("json") != "-"
+}
+
+func encodeReflect(w io.Writer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		var b uint8
+		if v.Bool() {
+			b = 1
+		}
+		return binary.Write(w, binary.LittleEndian, b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.Write(w, binary.LittleEndian, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.Write(w, binary.LittleEndian, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return binary.Write(w, binary.LittleEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		if err := binary.Write(w, binary.LittleEndian, uint32(v.Len())); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, v.String())
+		return err
+	case reflect.Slice:
+		if err := binary.Write(w, binary.LittleEndian, uint32(v.Len())); err != nil {
+			return err
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			_, err := w.Write(v.Bytes())
+			return err
+		}
+		fallthrough
+	case reflect.Array:
+		for index := 0; index < v.Len(); index++ {
+			if err := encodeReflect(w, v.Index(index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return binary.Write(w, binary.LittleEndian, uint8(0))
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint8(1)); err != nil {
+			return err
+		}
+		return encodeReflect(w, v.Elem())
+	case reflect.Struct:
+		for index := 0; index < v.NumField(); index++ {
+			if !savedField(v.Type().Field(index)) {
+				continue
+			}
+			if err := encodeReflect(w, v.Field(index)); err != nil {
+				return fmt.Errorf("%s.%s: %w", v.Type().Name(), v.Type().Field(index).Name, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("can't encode %s: %w", v.Type(), ErrCodec)
+	}
+}
+
+func decodeReflect(r io.Reader, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		var b uint8
+		if err := binary.Read(r, binary.LittleEndian, &b); err != nil {
+			return err
+		}
+		v.SetBool(b != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var bits uint64
+		if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+			return err
+		}
+		v.SetFloat(math.Float64frombits(bits))
+	case reflect.String:
+		var data, err = readPrefixed(r)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(data))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			var data, err = readPrefixed(r)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(data)
+			return nil
+		}
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 0, int(min(length, 1024))))
+		for index := uint32(0); index < length; index++ {
+			var elem = reflect.New(v.Type().Elem()).Elem()
+			if err := decodeReflect(r, elem); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+	case reflect.Array:
+		for index := 0; index < v.Len(); index++ {
+			if err := decodeReflect(r, v.Index(index)); err != nil {
+				return err
+			}
+		}
+	case reflect.Pointer:
+		var present uint8
+		if err := binary.Read(r, binary.LittleEndian, &present); err != nil {
+			return err
+		}
+		if present == 0 {
+			v.SetZero()
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return decodeReflect(r, v.Elem())
+	case reflect.Struct:
+		return decodeFields(r, v.Addr().Interface(), 0)
+	default:
+		return fmt.Errorf("can't decode %s: %w", v.Type(), ErrCodec)
+	}
+	return nil
+}
+
+func readPrefixed(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, err
+	}
+	var data, err = io.ReadAll(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < int(length) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
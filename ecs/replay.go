@@ -0,0 +1,273 @@
+package ecs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"io"
+	"sync"
+)
+
+var ErrReplay = errors.New("replay error")
+
+const (
+	replayMagic   = "VTREPLAY"
+	ReplayVersion = 4
+	// maxReplayMessage is far more than any encoded message takes, so a
+	// corrupt length can't have ReadReplay allocate gigabytes.
+	maxReplayMessage = 1 << 12
+)
+
+// Recorder is given every message an Instance applies, once per tick.
+type Recorder interface {
+	RecordTick(counter uint64, msgs []ecstypes.ComponentMessage) error
+}
+
+type ReplayTick struct {
+	Counter  uint64
+	Messages []ecstypes.ComponentMessage
+}
+
+// Replay is a recorded run of an Instance: the world when recording started
+// and the messages applied on each tick after that.
+type Replay struct {
+	World *WorldState
+	Ticks []ReplayTick
+}
+
+// ReplayWriter streams a Replay to w. It is an ecs.Recorder: set it as an
+// Instance's Recorder to record every tick. Methods are safe to call from
+// the goroutine running the Instance while another one flushes.
+type ReplayWriter struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// NewReplayWriter writes the replay header and the current world of instance.
+func NewReplayWriter(w io.Writer, instance *Instance) (*ReplayWriter, error) {
+	world, err := instance.SaveWorld()
+	if err != nil {
+		return nil, err
+	}
+	var rw = &ReplayWriter{w: bufio.NewWriter(w)}
+	if _, err = rw.w.WriteString(replayMagic); err != nil {
+		return nil, fmt.Errorf("writing replay header: %w", err)
+	}
+	if err = binary.Write(rw.w, binary.LittleEndian, uint16(ReplayVersion)); err != nil {
+		return nil, fmt.Errorf("writing replay header: %w", err)
+	}
+	if err = writeWorldBinary(rw.w, world); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+func (rw *ReplayWriter) RecordTick(counter uint64, msgs []ecstypes.ComponentMessage) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if err := binary.Write(rw.w, binary.LittleEndian, counter); err != nil {
+		return fmt.Errorf("writing tick: %w", err)
+	}
+	if err := binary.Write(rw.w, binary.LittleEndian, uint32(len(msgs))); err != nil {
+		return fmt.Errorf("writing tick: %w", err)
+	}
+	for _, msg := range msgs {
+		data, err := EncodeMessage(msg)
+		if err != nil {
+			return fmt.Errorf("recording tick %d: %w", counter, err)
+		}
+		if err = binary.Write(rw.w, binary.LittleEndian, uint32(len(data))); err != nil {
+			return fmt.Errorf("writing message: %w", err)
+		}
+		if _, err = rw.w.Write(data); err != nil {
+			return fmt.Errorf("writing message: %w", err)
+		}
+	}
+	return nil
+}
+
+func (rw *ReplayWriter) Flush() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.w.Flush()
+}
+
+// ReadReplay reads a replay written by ReplayWriter. A tick cut short at the
+// end of the file, as left by a crashed recorder, is dropped.
+func ReadReplay(r io.Reader) (*Replay, error) {
+	var br = bufio.NewReader(r)
+	var magic [len(replayMagic)]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil || string(magic[:]) != replayMagic {
+		return nil, fmt.Errorf("not a replay file: %w", ErrReplay)
+	}
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, fmt.Errorf("reading version: %w", err)
+	}
+	if version != ReplayVersion {
+		return nil, fmt.Errorf("replay version %d, want %d: %w", version, ReplayVersion, ErrReplay)
+	}
+
+	var replay = new(Replay)
+	var err error
+	if replay.World, err = readWorldBinary(br); err != nil {
+		return nil, err
+	}
+
+	for {
+		tick, err := readReplayTick(br)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return replay, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tick %d: %w", len(replay.Ticks), err)
+		}
+		replay.Ticks = append(replay.Ticks, tick)
+	}
+}
+
+func readReplayTick(r io.Reader) (ReplayTick, error) {
+	var tick ReplayTick
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &tick.Counter); err != nil {
+		return tick, err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return tick, err
+	}
+	for ; count > 0; count-- {
+		var length uint32
+		if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+			return tick, err
+		}
+		if length > maxReplayMessage {
+			return tick, fmt.Errorf("message of %d bytes: %w", length, ErrReplay)
+		}
+		var data = make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return tick, err
+		}
+		msg, err := DecodeMessage(data)
+		if err != nil {
+			return tick, err
+		}
+		tick.Messages = append(tick.Messages, msg)
+	}
+	return tick, nil
+}
+
+// Playback re-runs a Replay on a fresh deterministic Instance. Seeking
+// backwards rebuilds the Instance and re-simulates from the start.
+type Playback struct {
+	Replay   *Replay
+	Instance *Instance
+
+	Paused bool
+	// Speed is the number of ticks simulated per call to Advance.
+	Speed float64
+
+	// Setup, if set, is called on each new Instance before the recorded
+	// entities are restored, e.g. to attach a Sender.
+	Setup func(instance *Instance) error
+	// Restored, if set, is called after the recorded entities are restored,
+	// e.g. to add client-side components for rendering.
+	Restored func(instance *Instance) error
+
+	next  int
+	carry float64
+}
+
+func NewPlayback(replay *Replay) *Playback {
+	return &Playback{
+		Replay: replay,
+		Speed:  1,
+	}
+}
+
+// Reset rebuilds the Instance as it was when recording started.
+func (p *Playback) Reset() error {
+	var parameters = p.Replay.World.Parameters
+	parameters.Deterministic = true
+	var instance = NewInstance(parameters)
+	instance.Name = "Replay"
+	instance.Sender = Discard{}
+	if p.Setup != nil {
+		if err := p.Setup(instance); err != nil {
+			return err
+		}
+	}
+	if err := instance.LoadWorld(p.Replay.World); err != nil {
+		return err
+	}
+	if p.Restored != nil {
+		if err := p.Restored(instance); err != nil {
+			return err
+		}
+	}
+	p.Instance = instance
+	p.next = 0
+	p.carry = 0
+	return nil
+}
+
+// Tick returns the index of the next recorded tick to simulate.
+func (p *Playback) Tick() int {
+	return p.next
+}
+
+func (p *Playback) Len() int {
+	return len(p.Replay.Ticks)
+}
+
+func (p *Playback) Done() bool {
+	return p.next >= len(p.Replay.Ticks)
+}
+
+// Step simulates the next recorded tick, even while paused.
+func (p *Playback) Step() error {
+	if p.Instance == nil {
+		if err := p.Reset(); err != nil {
+			return err
+		}
+	}
+	if p.Done() {
+		return nil
+	}
+	var tick = p.Replay.Ticks[p.next]
+	p.next++
+	return p.Instance.Step(tick.Messages)
+}
+
+// Seek moves playback so that tick is the next one to simulate.
+func (p *Playback) Seek(tick int) error {
+	tick = max(0, min(tick, p.Len()))
+	if p.Instance == nil || tick < p.next {
+		if err := p.Reset(); err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for p.next < tick {
+		if err := p.Step(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Advance simulates Speed ticks, carrying fractions over between calls. It
+// does nothing while paused.
+func (p *Playback) Advance() error {
+	if p.Paused || p.Done() {
+		return nil
+	}
+	var errs []error
+	for p.carry += p.Speed; p.carry >= 1 && !p.Done(); p.carry-- {
+		if err := p.Step(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
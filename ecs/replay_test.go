@@ -0,0 +1,130 @@
+package ecs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+const recordedTicks = 200
+
+// record runs ships on scripted input for recordedTicks, recording them, and
+// returns the replay file and the Instance as it ended.
+func record(t *testing.T) ([]byte, *Instance) {
+	t.Helper()
+	var instance = newShips(t, Parameters{Deterministic: true, Seed: 3}, 3)
+	// recording starts part way through a run
+	for tick := 0; tick < 10; tick++ {
+		instance.Step(scriptedMessages(3, tick))
+	}
+	var buf bytes.Buffer
+	writer, err := NewReplayWriter(&buf, instance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance.Recorder = writer
+	for tick := 10; tick < 10+recordedTicks; tick++ {
+		if err = instance.Step(scriptedMessages(3, tick)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), instance
+}
+
+func TestReplayPlaysBack(t *testing.T) {
+	data, recorded := record(t)
+	replay, err := ReadReplay(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.Ticks) != recordedTicks || replay.World.Counter != 10 {
+		t.Fatalf("read %d ticks from %d, want %d from 10", len(replay.Ticks), replay.World.Counter, recordedTicks)
+	}
+	var playback = NewPlayback(replay)
+	for !playback.Done() {
+		if err = playback.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if playback.Instance.Checksum() != recorded.Checksum() {
+		t.Fatal("playback ended in a different world from the recording")
+	}
+}
+
+func TestPlaybackSeek(t *testing.T) {
+	data, _ := record(t)
+	replay, err := ReadReplay(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var playback = NewPlayback(replay)
+	var checksums = make(map[int]uint64)
+	for tick := 0; tick <= 150; tick++ {
+		if err = playback.Seek(tick); err != nil {
+			t.Fatal(err)
+		}
+		checksums[tick] = playback.Instance.Checksum()
+	}
+	for _, tick := range []int{150, 20, 0, 100, 99, 150} {
+		if err = playback.Seek(tick); err != nil {
+			t.Fatal(err)
+		}
+		if playback.Tick() != tick || playback.Instance.Checksum() != checksums[tick] {
+			t.Fatalf("seeking to %d gave a different world from stepping there", tick)
+		}
+	}
+
+	playback.Speed = 2.5
+	playback.Seek(0)
+	playback.Advance()
+	playback.Advance()
+	if playback.Tick() != 5 {
+		t.Fatalf("two advances at speed 2.5 reached tick %d, want 5", playback.Tick())
+	}
+	playback.Paused = true
+	playback.Advance()
+	if playback.Tick() != 5 {
+		t.Fatal("advanced while paused")
+	}
+}
+
+func TestReplayDropsTruncatedTick(t *testing.T) {
+	data, _ := record(t)
+	replay, err := ReadReplay(bytes.NewReader(data[:len(data)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay.Ticks) != recordedTicks-1 {
+		t.Fatalf("read %d ticks, want %d", len(replay.Ticks), recordedTicks-1)
+	}
+}
+
+func TestReplayRejectsOtherFiles(t *testing.T) {
+	data, _ := record(t)
+	var badMagic = bytes.Clone(data)
+	badMagic[0] = 'X'
+	var badVersion = bytes.Clone(data)
+	badVersion[len(replayMagic)]++
+	// a tick with one message claiming to be 4GB long
+	var huge = binary.LittleEndian.AppendUint64(bytes.Clone(data), recordedTicks)
+	huge = binary.LittleEndian.AppendUint32(huge, 1)
+	huge = binary.LittleEndian.AppendUint32(huge, math.MaxUint32)
+	for name, file := range map[string][]byte{
+		"empty":         nil,
+		"wrong magic":   badMagic,
+		"wrong version": badVersion,
+		"huge message":  huge,
+	} {
+		if _, err := ReadReplay(bytes.NewReader(file)); !errors.Is(err, ErrReplay) {
+			t.Errorf("%s: got %v, want ErrReplay", name, err)
+		}
+	}
+	if _, err := ReadReplay(bytes.NewReader(data[:len(replayMagic)+5])); err == nil {
+		t.Error("read a replay cut off in its header")
+	}
+}
@@ -0,0 +1,107 @@
+package ecs
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+)
+
+// ClassName is a ship class's name, fixed-size so that it can be sent in a
+// message.
+type ClassName [24]byte
+
+func NewClassName(name string) ClassName {
+	var result ClassName
+	copy(result[:], name)
+	return result
+}
+
+func (name ClassName) String() string {
+	return string(bytes.TrimRight(name[:], "\x00"))
+}
+
+// ShipState is sent to clients every few ticks for each Replicated ship. A
+// client that hasn't seen the ship yet adds it as a ship of Class, drawn and
+// moving but not simulated; otherwise it corrects the ship towards the state
+// as it does a player's.
+type ShipState struct {
+	Class    ClassName
+	Position geom.Vector
+	Velocity geom.Vector
+	Angle    geom.Angle
+}
+
+// Replicated has the server show clients a ship other than a player's, such
+// as an NPC or a player's escort, as the ShipClass named Class. A player's
+// own ship is replicated by its SyncSender.
+type Replicated struct {
+	Entity   ecstypes.EntityID
+	Motion   *Motion   `json:"-"`
+	Position *Position `json:"-"`
+
+	Class string
+}
+
+func (comp Replicated) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Class == "" {
+		return fmt.Errorf("replicated entity %d has no class: %w", entity, ErrType)
+	}
+	if comp.Motion, err = GetComponent[Motion](sm, entity); comp.Motion == nil {
+		return fmt.Errorf("no Motion found: %w", vterr.ErrMissing)
+	}
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding replicated: %w", err)
+	}
+	return nil
+}
+func (comp Replicated) Update(sm ecstypes.SystemManager) (Replicated, error) {
+	var sender = sm.GetSender()
+	if sm.GetCounter()%3 == 0 && sender != nil {
+		sender.Send(ecstypes.ComponentMessage{
+			Entity: comp.Entity,
+			Payload: ShipState{
+				Class:    NewClassName(comp.Class),
+				Position: comp.Position.Vector,
+				Velocity: comp.Motion.Velocity,
+				Angle:    comp.Position.Angle,
+			},
+		})
+	}
+	return comp, nil
+}
+func (comp Replicated) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemReplicated
+}
+
+// applyShipState shows on a client the ship a ShipState is about.
+func (i *Instance) applyShipState(e ecstypes.EntityID, state ShipState) error {
+	var input = SyncInput{Position: state.Position, Velocity: state.Velocity, Angle: state.Angle}
+	if sync, ok := i.SyncReceiver.GetComponent(e); ok {
+		sync.Input <- input
+		return nil
+	}
+	if _, ok := i.Entities[e]; ok {
+		return nil
+	}
+	class, ok := i.ShipClasses[state.Class.String()]
+	if !ok {
+		return fmt.Errorf("ship %d of class %q: %w", e, state.Class, ErrShipClass)
+	}
+	var err = i.AddEntity(e,
+		&Position{Vector: state.Position, Angle: state.Angle},
+		&Motion{Velocity: state.Velocity},
+		class.Sprite(),
+		new(SyncReceiver),
+	)
+	if err != nil {
+		return fmt.Errorf("adding ship %d: %w", e, err)
+	}
+	return nil
+}
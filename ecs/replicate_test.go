@@ -0,0 +1,89 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/StCredZero/vectrek/assets"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"testing"
+)
+
+func TestReplicatedShips(t *testing.T) {
+	classes, err := LoadShipClasses(assets.Ships())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server = NewInstance(Parameters{ScreenWidth: 2000, ScreenHeight: 2000})
+	server.ShipClasses = classes
+	var sent = NewPipe()
+	server.SetSender(sent)
+	npc, err := server.Spawn("scout", Position{Vector: geom.Vector{X: 500, Y: 500}}, &Replicated{Class: "scout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var motion, _ = server.Motion.GetComponent(npc)
+	motion.Velocity = geom.Vector{X: 60}
+
+	var client = NewInstance(Parameters{ScreenWidth: 2000, ScreenHeight: 2000})
+	client.Client = true
+	client.ShipClasses = classes
+	var states int
+	for tick := 0; tick < 30; tick++ {
+		if err := server.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+		var msgs = drain(sent)
+		for _, msg := range msgs {
+			if _, ok := msg.Payload.(ShipState); ok {
+				states++
+			}
+		}
+		if err := client.Step(msgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if states != 10 {
+		t.Errorf("sent %d ShipStates in 30 ticks, want one every 3", states)
+	}
+	if _, ok := client.Sprite.GetComponent(npc); !ok {
+		t.Fatal("the client doesn't draw the ship")
+	}
+	if _, ok := client.Helm.GetComponent(npc); ok {
+		t.Error("the client simulates the ship")
+	}
+	var want, _ = server.Position.GetComponent(npc)
+	var got, _ = client.Position.GetComponent(npc)
+	if distance := client.GetWorld().Distance(got.Vector, want.Vector); distance > 5 {
+		t.Errorf("client shows the ship %v from where the server has it", distance)
+	}
+
+	var unknown = ecstypes.ComponentMessage{Entity: 99, Payload: ShipState{Class: NewClassName("barge")}}
+	if err := client.Step([]ecstypes.ComponentMessage{unknown}); !errors.Is(err, ErrShipClass) {
+		t.Errorf("showing a ship of an unknown class: got %v, want ErrShipClass", err)
+	}
+}
+
+func TestTransferDespawnsReplicated(t *testing.T) {
+	var galaxy, sent = newGalaxy(false)
+	var from, to = SectorCoord{X: 0, Y: 0}, SectorCoord{X: 1, Y: 0}
+	source, err := galaxy.Sector(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.AddEntity(1, &Position{Vector: geom.Vector{X: 100, Y: 100}}, new(Motion), &Replicated{Class: "scout"}); err != nil {
+		t.Fatal(err)
+	}
+	// a player to see it go
+	addShip(t, galaxy, from, 2, geom.Vector{X: 500, Y: 500}, geom.Vector{}, true)
+	drain(sent)
+	if err := galaxy.Transfer(1, from, to); err != nil {
+		t.Fatal(err)
+	}
+	var msgs = drain(sent)
+	if len(msgs) != 1 || msgs[0].Entity != 1 || msgs[0].Payload != (Despawned{}) {
+		t.Errorf("sent %+v, want the ship despawned from the old sector", msgs)
+	}
+	if _, ok := galaxy.Sectors[to].Replicated.GetComponent(1); !ok {
+		t.Error("the ship isn't replicated from its new sector")
+	}
+}
@@ -0,0 +1,201 @@
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+)
+
+var ErrDesync = errors.New("desync")
+
+const (
+	// rollbackFrames is how many past frames a Rollback keeps snapshots of.
+	rollbackFrames = 16
+	// MaxPrediction is how many frames a Rollback will simulate past the
+	// last confirmed remote input before it stalls to wait for the peer.
+	MaxPrediction = 8
+)
+
+type rollbackFrame struct {
+	// snapshot is the world before this frame was simulated
+	snapshot Snapshot
+	local    HelmInput
+	remote   HelmInput
+	checksum uint64
+}
+
+// Rollback runs a two-peer match GGPO style. Both peers simulate both ships
+// every frame; the remote ship's input is predicted to repeat its last known
+// value. When the real input for a frame arrives and differs from the
+// prediction, the world is restored to that frame's snapshot and every frame
+// since is simulated again.
+type Rollback struct {
+	Instance *Instance
+	Local    ecstypes.EntityID
+	Remote   ecstypes.EntityID
+	// Sender and Receiver connect to the peer. Sender may be nil in a sync
+	// test.
+	Sender   ecstypes.Sender
+	Receiver ecstypes.Receiver
+
+	// SyncTest, when above zero, rolls back that many frames after every
+	// frame and checks that re-simulating them gives the same checksums.
+	// It catches nondeterminism without needing a peer.
+	SyncTest int
+
+	// Rollbacks counts how many times mispredicted input forced a rollback.
+	Rollbacks int
+
+	frame     uint64
+	confirmed uint64
+	frames    [rollbackFrames]rollbackFrame
+	remote    map[uint64]HelmInput
+}
+
+func NewRollback(instance *Instance, local ecstypes.EntityID, remote ecstypes.EntityID) *Rollback {
+	return &Rollback{
+		Instance: instance,
+		Local:    local,
+		Remote:   remote,
+		remote:   make(map[uint64]HelmInput),
+	}
+}
+
+// Frame returns the number of frames simulated so far.
+func (r *Rollback) Frame() uint64 {
+	return r.frame
+}
+
+// Stalled reports whether the next Advance would wait for the peer instead of
+// predicting further ahead.
+func (r *Rollback) Stalled() bool {
+	return r.SyncTest == 0 && r.frame >= r.confirmed+MaxPrediction
+}
+
+// Advance applies any inputs received from the peer, rolling back if they
+// contradict a prediction, then simulates one new frame with local as the
+// local ship's input. It returns false without simulating when too far ahead
+// of the peer.
+func (r *Rollback) Advance(local HelmInput) (bool, error) {
+	if err := r.receive(); err != nil {
+		return false, err
+	}
+	if r.Stalled() {
+		return false, nil
+	}
+
+	var slot = &r.frames[r.frame%rollbackFrames]
+	slot.local = local
+	slot.remote = r.predict(r.frame)
+	if r.Sender != nil {
+		r.Sender.Send(ecstypes.ComponentMessage{
+			Entity:  r.Local,
+			Payload: RollbackInput{Frame: r.frame, Input: local},
+		})
+	}
+	var err = r.simulate(r.frame)
+	r.frame++
+	if err != nil {
+		return true, err
+	}
+	if r.SyncTest > 0 {
+		return true, r.syncTest()
+	}
+	return true, nil
+}
+
+func (r *Rollback) receive() error {
+	if r.Receiver == nil {
+		return nil
+	}
+	for {
+		msg, ok := r.Receiver.Receive()
+		if !ok {
+			break
+		}
+		if input, ok := msg.Payload.(RollbackInput); ok && input.Frame >= r.confirmed {
+			r.remote[input.Frame] = input.Input
+		}
+	}
+
+	var first = r.confirmed
+	for {
+		if _, ok := r.remote[r.confirmed]; !ok {
+			break
+		}
+		r.confirmed++
+	}
+	for frame := first; frame < min(r.confirmed, r.frame); frame++ {
+		if r.frames[frame%rollbackFrames].remote != r.remote[frame] {
+			r.Rollbacks++
+			if err := r.resimulate(frame); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	for frame := range r.remote {
+		if frame+1 < r.confirmed && frame+rollbackFrames < r.frame {
+			delete(r.remote, frame)
+		}
+	}
+	return nil
+}
+
+// predict returns the remote input for frame: the real one if it has
+// arrived, otherwise the last confirmed one.
+func (r *Rollback) predict(frame uint64) HelmInput {
+	if input, ok := r.remote[frame]; ok {
+		return input
+	}
+	if r.confirmed > 0 {
+		return r.remote[r.confirmed-1]
+	}
+	return HelmInput{}
+}
+
+func (r *Rollback) simulate(frame uint64) error {
+	var slot = &r.frames[frame%rollbackFrames]
+	r.Instance.Snapshot(&slot.snapshot)
+	var err = r.Instance.Step([]ecstypes.ComponentMessage{
+		{Entity: r.Local, Payload: slot.local},
+		{Entity: r.Remote, Payload: slot.remote},
+	})
+	slot.checksum = r.Instance.Checksum()
+	if err != nil {
+		return fmt.Errorf("simulating frame %d: %w", frame, err)
+	}
+	return nil
+}
+
+// resimulate restores the snapshot taken before from and simulates every
+// frame up to the present again with the best known remote inputs.
+func (r *Rollback) resimulate(from uint64) error {
+	r.Instance.Restore(&r.frames[from%rollbackFrames].snapshot)
+	for frame := from; frame < r.frame; frame++ {
+		r.frames[frame%rollbackFrames].remote = r.predict(frame)
+		if err := r.simulate(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rollback) syncTest() error {
+	var depth = min(uint64(r.SyncTest), r.frame, rollbackFrames)
+	var from = r.frame - depth
+	var want [rollbackFrames]uint64
+	for frame := from; frame < r.frame; frame++ {
+		want[frame%rollbackFrames] = r.frames[frame%rollbackFrames].checksum
+	}
+	if err := r.resimulate(from); err != nil {
+		return err
+	}
+	for frame := from; frame < r.frame; frame++ {
+		if got := r.frames[frame%rollbackFrames].checksum; got != want[frame%rollbackFrames] {
+			return fmt.Errorf("frame %d checksum %016x, re-simulated %016x: %w", frame, want[frame%rollbackFrames], got, ErrDesync)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"testing"
+)
+
+// newDuel returns a Rollback between ships 0, played here, and 1, whose
+// input the test sends through the returned Pipe.
+func newDuel(t *testing.T) (*Rollback, *Pipe) {
+	t.Helper()
+	var rollback = NewRollback(newShips(t, Parameters{Deterministic: true, Seed: 7}, 2), 0, 1)
+	var peer = NewPipe()
+	rollback.Receiver = peer
+	rollback.Sender = Discard{}
+	return rollback, peer
+}
+
+func remoteInput(frame uint64) ecstypes.ComponentMessage {
+	return ecstypes.ComponentMessage{Entity: 1, Payload: RollbackInput{Frame: frame, Input: scriptedInput(1, int(frame))}}
+}
+
+func TestRollbackCorrectsLateInput(t *testing.T) {
+	const frames, late = 120, 5
+	var rollback, peer = newDuel(t)
+	for frame := uint64(0); frame < frames; frame++ {
+		if frame >= late {
+			peer.Send(remoteInput(frame - late))
+		}
+		if ok, err := rollback.Advance(scriptedInput(0, int(frame))); !ok || err != nil {
+			t.Fatalf("frame %d: advanced %v, %v", frame, ok, err)
+		}
+	}
+	for frame := uint64(frames - late); frame < frames; frame++ {
+		peer.Send(remoteInput(frame))
+	}
+	if err := rollback.receive(); err != nil {
+		t.Fatal(err)
+	}
+	if rollback.Rollbacks == 0 {
+		t.Fatal("late input never contradicted a prediction")
+	}
+
+	// the same frames with every input known in time
+	var reference = newShips(t, Parameters{Deterministic: true, Seed: 7}, 2)
+	for frame := 0; frame < frames; frame++ {
+		reference.Step(scriptedMessages(2, frame))
+	}
+	if rollback.Instance.Checksum() != reference.Checksum() {
+		t.Fatal("rolled back world differs from one run with the real inputs")
+	}
+}
+
+func TestRollbackStallsTooFarAhead(t *testing.T) {
+	var rollback, peer = newDuel(t)
+	for frame := 0; frame < MaxPrediction; frame++ {
+		if ok, err := rollback.Advance(HelmInput{}); !ok || err != nil {
+			t.Fatalf("frame %d: advanced %v, %v", frame, ok, err)
+		}
+	}
+	if !rollback.Stalled() {
+		t.Fatal("not stalled after predicting MaxPrediction frames")
+	}
+	if ok, _ := rollback.Advance(HelmInput{}); ok || rollback.Frame() != MaxPrediction {
+		t.Fatalf("advanced to frame %d while stalled", rollback.Frame())
+	}
+	peer.Send(remoteInput(0))
+	if ok, err := rollback.Advance(HelmInput{}); !ok || err != nil {
+		t.Fatalf("still stalled once the peer caught up: %v", err)
+	}
+}
+
+func TestSyncTest(t *testing.T) {
+	var rollback = NewRollback(newShips(t, Parameters{Deterministic: true, Seed: 7}, 2), 0, 1)
+	rollback.SyncTest = 6
+	for frame := 0; frame < 200; frame++ {
+		if ok, err := rollback.Advance(scriptedInput(0, frame)); !ok || err != nil {
+			t.Fatalf("frame %d: advanced %v, %v", frame, ok, err)
+		}
+	}
+
+	// changing the world outside the simulation is as good as
+	// nondeterminism: re-simulating undoes it
+	position, _ := rollback.Instance.Position.GetComponent(0)
+	position.X += 10
+	if _, err := rollback.Advance(HelmInput{}); !errors.Is(err, ErrDesync) {
+		t.Fatalf("got %v, want ErrDesync", err)
+	}
+}
+
+func TestSnapshotRestoresWorld(t *testing.T) {
+	var instance = newShips(t, Parameters{Deterministic: true, Seed: 7}, 3)
+	var snapshot Snapshot
+	instance.Snapshot(&snapshot)
+	var before, random = instance.Checksum(), *instance.RandSource
+	for tick := 0; tick < 30; tick++ {
+		instance.Step(scriptedMessages(3, tick))
+	}
+	instance.Restore(&snapshot)
+	if instance.Checksum() != before || *instance.RandSource != random {
+		t.Fatal("restored world differs from the snapshot")
+	}
+}
@@ -0,0 +1,196 @@
+package ecs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"image/color"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+var ErrShipClass = errors.New("invalid ship class")
+
+// ShipClass describes a kind of ship, as loaded from a JSON file by
+// LoadShipClasses. TurnRate is in degrees per second, Energy and Recharge
+// are the Weapons', which a class with Hardpoints must have, and Shields,
+// Hull and Reactor are the shields' strength per facing, the hull's
+// integrity and the reactor's output, and WarpFactor is the highest its
+// Warp reaches. A ship with no Mass has no Body, and zero
+// Shields, Reactor or WarpFactor leave those out.
+//
+// A Hardpoint only needs its Kind and where it's mounted: anything else
+// left zero is taken from PhaserBank or TorpedoTube.
+type ShipClass struct {
+	Name        string
+	Outline     geom.Outline
+	Color       string
+	Mass        float64
+	Thrust      float64
+	TurnRate    float64
+	MaxVelocity float64
+	Hardpoints  []Hardpoint
+	Energy      float64
+	Recharge    float64
+	Shields     float64
+	ShieldRegen float64
+	ShieldDelay float64
+	Hull        float64
+	Reactor     float64
+	WarpFactor  float64
+}
+
+// LoadShipClasses reads every .json file at the top of fsys as a ShipClass.
+// A class without a Name is named after its file.
+func LoadShipClasses(fsys fs.FS) (map[string]ShipClass, error) {
+	names, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("listing ship classes: %w", err)
+	}
+	var classes = make(map[string]ShipClass, len(names))
+	for _, name := range names {
+		raw, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		var class ShipClass
+		if err = json.Unmarshal(raw, &class); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", name, err)
+		}
+		if class.Name == "" {
+			class.Name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		if err = class.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if _, ok := classes[class.Name]; ok {
+			return nil, fmt.Errorf("%s: class %q defined twice: %w", name, class.Name, ErrShipClass)
+		}
+		classes[class.Name] = class
+	}
+	return classes, nil
+}
+
+func (class ShipClass) validate() error {
+	if len(class.Name) > len(ClassName{}) {
+		return fmt.Errorf("class name %q is longer than %d bytes: %w", class.Name, len(ClassName{}), ErrShipClass)
+	}
+	if len(class.Outline) < 3 || class.Outline.Area() == 0 {
+		return fmt.Errorf("class %q has no outline: %w", class.Name, ErrShipClass)
+	}
+	if _, err := parseColor(class.Color); err != nil {
+		return fmt.Errorf("class %q: %w", class.Name, err)
+	}
+	for _, hardpoint := range class.Hardpoints {
+		if hardpoint.Kind != WeaponPhaser && hardpoint.Kind != WeaponTorpedo {
+			return fmt.Errorf("class %q has a hardpoint of no kind: %w", class.Name, ErrShipClass)
+		}
+	}
+	if len(class.Hardpoints) > 0 && !(class.Energy > 0) {
+		return fmt.Errorf("class %q has hardpoints but no Energy to fire them: %w", class.Name, ErrShipClass)
+	}
+	return nil
+}
+
+// parseColor reads a color written #rrggbb, or white if it's empty.
+func parseColor(text string) (color.RGBA, error) {
+	var result = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	if text == "" {
+		return result, nil
+	}
+	if _, err := fmt.Sscanf(text, "#%02x%02x%02x", &result.R, &result.G, &result.B); err != nil || len(text) != 7 {
+		return result, fmt.Errorf("color %q isn't #rrggbb: %w", text, ErrShipClass)
+	}
+	return result, nil
+}
+
+// Sprite returns how ships of this class are drawn.
+func (class ShipClass) Sprite() *Sprite {
+	tint, _ := parseColor(class.Color)
+	return &Sprite{Outline: class.Outline, Color: tint}
+}
+
+// Components returns the components of a ship of this class at position.
+func (class ShipClass) Components(position Position) []ecstypes.Component {
+	var components = []ecstypes.Component{
+		&position,
+		new(Motion),
+		&Helm{
+			Thrust:      class.Thrust,
+			TurnRate:    float64(geom.Degrees(class.TurnRate)),
+			MaxVelocity: class.MaxVelocity,
+		},
+		class.Sprite(),
+		&Collider{Outline: class.Outline},
+		&Hull{MaxIntegrity: class.Hull},
+	}
+	if class.Mass > 0 {
+		components = append(components, &Body{Mass: class.Mass, MaxSpeed: class.MaxVelocity})
+	}
+	if len(class.Hardpoints) > 0 {
+		var hardpoints = make([]Hardpoint, len(class.Hardpoints))
+		for index, hardpoint := range class.Hardpoints {
+			hardpoints[index] = hardpoint.withDefaults()
+		}
+		components = append(components, &Weapons{
+			Hardpoints: hardpoints,
+			Energy:     class.Energy,
+			MaxEnergy:  class.Energy,
+			Recharge:   class.Recharge,
+		})
+	}
+	if class.Shields > 0 {
+		components = append(components, &Shields{Max: class.Shields, Regen: class.ShieldRegen, Delay: class.ShieldDelay})
+	}
+	if class.Reactor > 0 {
+		components = append(components, &Reactor{Output: class.Reactor})
+	}
+	if class.WarpFactor > 0 {
+		components = append(components, &Warp{MaxFactor: class.WarpFactor})
+	}
+	return components
+}
+
+// withDefaults fills in what the hardpoint leaves zero from the stock
+// hardpoint of its kind.
+func (hardpoint Hardpoint) withDefaults() Hardpoint {
+	var stock = PhaserBank
+	if hardpoint.Kind == WeaponTorpedo {
+		stock = TorpedoTube
+	}
+	var fill = func(value *float64, fallback float64) {
+		if *value == 0 {
+			*value = fallback
+		}
+	}
+	fill(&hardpoint.Cooldown, stock.Cooldown)
+	fill(&hardpoint.Energy, stock.Energy)
+	fill(&hardpoint.Damage, stock.Damage)
+	fill(&hardpoint.Range, stock.Range)
+	fill(&hardpoint.Speed, stock.Speed)
+	fill(&hardpoint.Lifetime, stock.Lifetime)
+	return hardpoint
+}
+
+// Spawn adds a ship of the named class as a new entity, along with any extra
+// components such as a Player's, and returns the entity.
+func (i *Instance) Spawn(class string, position Position, extra ...ecstypes.Component) (ecstypes.EntityID, error) {
+	var entity = i.NewEntity()
+	return entity, i.SpawnEntity(entity, class, position, extra...)
+}
+
+// SpawnEntity adds a ship of the named class as entity, which peers and
+// servers agree on up front.
+func (i *Instance) SpawnEntity(entity ecstypes.EntityID, class string, position Position, extra ...ecstypes.Component) error {
+	shipClass, ok := i.ShipClasses[class]
+	if !ok {
+		return fmt.Errorf("no ship class %q: %w", class, ErrShipClass)
+	}
+	if err := i.AddEntity(entity, append(shipClass.Components(position), extra...)...); err != nil {
+		return fmt.Errorf("spawning %s: %w", class, err)
+	}
+	return nil
+}
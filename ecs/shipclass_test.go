@@ -0,0 +1,88 @@
+package ecs
+
+import (
+	"errors"
+	"github.com/StCredZero/vectrek/assets"
+	"github.com/StCredZero/vectrek/geom"
+	"testing"
+	"testing/fstest"
+)
+
+const testClass = `{
+	"Outline": [{"X": 10}, {"X": -5, "Y": 5}, {"X": -5, "Y": -5}],
+	"Color": "#ff8000",
+	"Mass": 2,
+	"Hardpoints": [{"Kind": "torpedo", "Offset": {"X": 10}, "Damage": 25}],
+	"Energy": 50,
+	"Shields": 30
+}`
+
+func TestLoadStockShipClasses(t *testing.T) {
+	classes, err := LoadShipClasses(assets.Ships())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"scout", "cruiser", "dreadnought"} {
+		if _, ok := classes[name]; !ok {
+			t.Errorf("no stock %s", name)
+		}
+	}
+}
+
+func TestShipClassErrors(t *testing.T) {
+	var tests = []struct {
+		name  string
+		files fstest.MapFS
+		want  error
+	}{
+		{"no outline", fstest.MapFS{"a.json": {Data: []byte(`{"Outline": [{"X": 1}, {"X": 2}]}`)}}, ErrShipClass},
+		{"flat outline", fstest.MapFS{"a.json": {Data: []byte(`{"Outline": [{"X": 1}, {"X": 2}, {"X": 3}]}`)}}, ErrShipClass},
+		{"bad color", fstest.MapFS{"a.json": {Data: []byte(`{"Outline": [{"X": 10}, {"Y": 5}, {"Y": -5}], "Color": "orange"}`)}}, ErrShipClass},
+		{"hardpoint of no kind", fstest.MapFS{"a.json": {Data: []byte(`{"Outline": [{"X": 10}, {"Y": 5}, {"Y": -5}], "Hardpoints": [{}]}`)}}, ErrShipClass},
+		{"hardpoints without energy", fstest.MapFS{"a.json": {Data: []byte(`{"Outline": [{"X": 10}, {"Y": 5}, {"Y": -5}], "Hardpoints": [{"Kind": "phaser"}]}`)}}, ErrShipClass},
+		{"unknown kind", fstest.MapFS{"a.json": {Data: []byte(`{"Outline": [{"X": 10}, {"Y": 5}, {"Y": -5}], "Hardpoints": [{"Kind": "laser"}]}`)}}, ErrType},
+		{"defined twice", fstest.MapFS{
+			"a.json": {Data: []byte(`{"Name": "same", "Outline": [{"X": 10}, {"Y": 5}, {"Y": -5}]}`)},
+			"b.json": {Data: []byte(`{"Name": "same", "Outline": [{"X": 10}, {"Y": 5}, {"Y": -5}]}`)},
+		}, ErrShipClass},
+	}
+	for _, test := range tests {
+		if _, err := LoadShipClasses(test.files); !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	classes, err := LoadShipClasses(fstest.MapFS{"lancer.json": {Data: []byte(testClass)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	instance.ShipClasses = classes
+	e, err := instance.Spawn("lancer", Position{Vector: geom.Vector{X: 100, Y: 200}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := instance.Body.GetComponent(e); !ok {
+		t.Error("massive ship has no Body")
+	}
+	if _, ok := instance.Reactor.GetComponent(e); ok {
+		t.Error("ship without a reactor got one")
+	}
+	if shields, ok := instance.Shields.GetComponent(e); !ok || shields.Strength != [4]float64{30, 30, 30, 30} {
+		t.Errorf("shields %+v, want 30 on every facing", shields)
+	}
+	var weapons, _ = instance.Weapons.GetComponent(e)
+	var tube = weapons.Hardpoints[0]
+	if tube.Damage != 25 || tube.Speed != TorpedoTube.Speed || tube.Cooldown != TorpedoTube.Cooldown {
+		t.Errorf("hardpoint %+v, want its own damage and the stock tube's rest", tube)
+	}
+	if sprite, _ := instance.Sprite.GetComponent(e); sprite.Color.R != 0xff || sprite.Color.G != 0x80 || sprite.Color.B != 0 {
+		t.Errorf("sprite color %v", sprite.Color)
+	}
+
+	if _, err := instance.Spawn("frigate", Position{}); !errors.Is(err, ErrShipClass) {
+		t.Errorf("unknown class: got %v, want ErrShipClass", err)
+	}
+}
@@ -0,0 +1,111 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/sparse"
+	"maps"
+	"math/rand/v2"
+)
+
+// Snapshot is an in-memory copy of an Instance's simulated state, cheap
+// enough to take every tick. Unlike WorldState it keeps the pointers between
+// components as they are, which stay valid because Restore writes back into
+// the same storage.
+type Snapshot struct {
+	Counter    uint64
+	NextEntity ecstypes.EntityID
+	RandSource rand.PCG
+	Entities   map[ecstypes.EntityID]struct{}
+
+	Position     sparse.Snapshot[Position]
+	Motion       sparse.Snapshot[Motion]
+	Helm         sparse.Snapshot[Helm]
+	Sprite       sparse.Snapshot[Sprite]
+	Player       sparse.Snapshot[Player]
+	SyncReceiver sparse.Snapshot[SyncReceiver]
+	SyncSender   sparse.Snapshot[SyncSender]
+	Collider     sparse.Snapshot[Collider]
+	Body         sparse.Snapshot[Body]
+	GravityWell  sparse.Snapshot[GravityWell]
+	Orbit        sparse.Snapshot[Orbit]
+	Parent       sparse.Snapshot[Parent]
+	Weapons      sparse.Snapshot[Weapons]
+	Torpedo      sparse.Snapshot[Torpedo]
+	Shields      sparse.Snapshot[Shields]
+	Hull         sparse.Snapshot[Hull]
+	Reactor      sparse.Snapshot[Reactor]
+	Warp         sparse.Snapshot[Warp]
+	AI           sparse.Snapshot[AI]
+	Brain        sparse.Snapshot[Brain]
+	Fleet        sparse.Snapshot[Fleet]
+	Wingman      sparse.Snapshot[Wingman]
+	Replicated   sparse.Snapshot[Replicated]
+}
+
+// Snapshot saves the Instance into dst, reusing dst's storage.
+func (i *Instance) Snapshot(dst *Snapshot) {
+	dst.Counter = i.Counter
+	dst.NextEntity = i.NextEntity
+	dst.RandSource = *i.RandSource
+	if dst.Entities == nil {
+		dst.Entities = make(map[ecstypes.EntityID]struct{}, len(i.Entities))
+	}
+	clear(dst.Entities)
+	maps.Copy(dst.Entities, i.Entities)
+	i.Position.Snapshot(&dst.Position)
+	i.Motion.Snapshot(&dst.Motion)
+	i.Helm.Snapshot(&dst.Helm)
+	i.Sprite.Snapshot(&dst.Sprite)
+	i.Player.Snapshot(&dst.Player)
+	i.SyncReceiver.Snapshot(&dst.SyncReceiver)
+	i.SyncSender.Snapshot(&dst.SyncSender)
+	i.Collider.Snapshot(&dst.Collider)
+	i.Body.Snapshot(&dst.Body)
+	i.GravityWell.Snapshot(&dst.GravityWell)
+	i.Orbit.Snapshot(&dst.Orbit)
+	i.Parent.Snapshot(&dst.Parent)
+	i.Weapons.Snapshot(&dst.Weapons)
+	i.Torpedo.Snapshot(&dst.Torpedo)
+	i.Shields.Snapshot(&dst.Shields)
+	i.Hull.Snapshot(&dst.Hull)
+	i.Reactor.Snapshot(&dst.Reactor)
+	i.Warp.Snapshot(&dst.Warp)
+	i.AI.Snapshot(&dst.AI)
+	i.Brain.Snapshot(&dst.Brain)
+	i.Fleet.Snapshot(&dst.Fleet)
+	i.Wingman.Snapshot(&dst.Wingman)
+	i.Replicated.Snapshot(&dst.Replicated)
+}
+
+// Restore puts the Instance back to the state saved in src.
+func (i *Instance) Restore(src *Snapshot) {
+	i.Counter = src.Counter
+	i.NextEntity = src.NextEntity
+	*i.RandSource = src.RandSource
+	clear(i.Entities)
+	maps.Copy(i.Entities, src.Entities)
+	i.Position.Restore(&src.Position)
+	i.Motion.Restore(&src.Motion)
+	i.Helm.Restore(&src.Helm)
+	i.Sprite.Restore(&src.Sprite)
+	i.Player.Restore(&src.Player)
+	i.SyncReceiver.Restore(&src.SyncReceiver)
+	i.SyncSender.Restore(&src.SyncSender)
+	i.Collider.Restore(&src.Collider)
+	i.Body.Restore(&src.Body)
+	i.GravityWell.Restore(&src.GravityWell)
+	i.Orbit.Restore(&src.Orbit)
+	i.Parent.Restore(&src.Parent)
+	i.Weapons.Restore(&src.Weapons)
+	i.Torpedo.Restore(&src.Torpedo)
+	i.Shields.Restore(&src.Shields)
+	i.Hull.Restore(&src.Hull)
+	i.Reactor.Restore(&src.Reactor)
+	i.Warp.Restore(&src.Warp)
+	i.AI.Restore(&src.AI)
+	i.Brain.Restore(&src.Brain)
+	i.Fleet.Restore(&src.Fleet)
+	i.Wingman.Restore(&src.Wingman)
+	i.Replicated.Restore(&src.Replicated)
+	i.updateSpatialIndex()
+}
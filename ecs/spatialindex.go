@@ -0,0 +1,32 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/spatial"
+)
+
+// spatialCellSize is the cell size of the Instance's spatial index, a few
+// ship lengths across.
+const spatialCellSize = 64
+
+// GetSpatialIndex returns an index of every entity with a Position, as a
+// circle the size of its Collider, or a point if it has none. It is brought
+// up to date after movement and collision response each tick.
+func (i *Instance) GetSpatialIndex() *spatial.Index[ecstypes.EntityID] {
+	return i.spatialIndex
+}
+
+func (i *Instance) indexEntity(e ecstypes.EntityID, position *Position) {
+	var radius float64
+	if collider, ok := i.Collider.GetComponent(e); ok {
+		radius = collider.BoundingRadius()
+	}
+	i.spatialIndex.Update(e, position.Vector, radius)
+}
+
+// updateSpatialIndex moves every entity to where it is now and drops those
+// that no longer have a Position.
+func (i *Instance) updateSpatialIndex() {
+	i.Position.EachSorted(i.indexEntity)
+	i.spatialIndex.RemoveStale()
+}
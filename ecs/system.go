@@ -39,6 +39,27 @@ func (s *SMSystem[T]) GetComponent(e ecstypes.EntityID) (*T, bool) {
 	return result, true
 }
 
+func (s *SMSystem[T]) RemoveComponent(e ecstypes.EntityID) {
+	s.Map.Delete(sparse.Key(e))
+}
+
+// EachSorted calls fn for every component in ascending entity order.
+func (s *SMSystem[T]) EachSorted(fn func(e ecstypes.EntityID, component *T)) {
+	for _, key := range s.Map.Keys() {
+		fn(ecstypes.EntityID(key), s.Map.MustGet(key))
+	}
+}
+
+// Snapshot copies every component of the system into dst.
+func (s *SMSystem[T]) Snapshot(dst *sparse.Snapshot[T]) {
+	s.Map.Snapshot(dst)
+}
+
+// Restore puts every component back to what was saved in src.
+func (s *SMSystem[T]) Restore(src *sparse.Snapshot[T]) {
+	s.Map.Restore(src)
+}
+
 func (s *SMSystem[T]) Iterate() []error {
 	return s.doIterate(s.Update)
 }
@@ -0,0 +1,226 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+)
+
+// WarpState is which regime a ship's drive is in.
+type WarpState uint8
+
+const (
+	// WarpImpulse is normal flight under the Helm's thrust.
+	WarpImpulse WarpState = iota
+	WarpCharging
+	WarpEngaged
+	WarpCooldown
+)
+
+const (
+	// LightSpeed is the speed at warp factor 1, in pixels per second.
+	LightSpeed = 2 * MaxVelocity
+	// WarpAcceleration is how fast thrust raises the warp factor, per
+	// second.
+	WarpAcceleration = 1.0
+	// WarpInterdiction is the gravitational acceleration, in pixels per
+	// second squared, above which a ship can't hold warp.
+	WarpInterdiction = 20.0
+)
+
+// Warp is a ship's warp drive. Pressing warp at impulse charges it for
+// ChargeTime seconds, after which the ship goes to warp factor 1 and moves at
+// WarpSpeed, turning no faster than TurnRate; thrust raises the factor up to
+// MaxFactor. Pressing warp again, taking damage, or coming where a
+// GravityWell pulls harder than WarpInterdiction drops the ship back to
+// impulse, and the drive needs CooldownTime seconds before it can charge
+// again. Remaining counts down the charge or the cooldown.
+//
+// The drive runs only where the ship is simulated, and each change of State
+// is broadcast as a WarpStatus. A client only applies the WarpStatus it's
+// sent, counting Remaining down in between.
+type Warp struct {
+	Entity ecstypes.EntityID
+
+	State        WarpState
+	Factor       float64
+	MaxFactor    float64
+	ChargeTime   float64
+	CooldownTime float64
+	TurnRate     float64
+	Remaining    float64
+	// Held is whether the warp input was down last tick, so that holding it
+	// counts as one press.
+	Held bool
+}
+
+// WarpStatus is the state a ship's warp drive has changed to.
+type WarpStatus struct {
+	State     WarpState
+	Factor    float64
+	Remaining float64
+}
+
+// WarpSpeed is the speed at a warp factor, in pixels per second.
+func WarpSpeed(factor float64) float64 {
+	return LightSpeed * factor
+}
+
+func (comp Warp) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	comp.Entity = entity
+	if comp.MaxFactor == 0 {
+		comp.MaxFactor = 5
+	}
+	if comp.ChargeTime == 0 {
+		comp.ChargeTime = 3
+	}
+	if comp.CooldownTime == 0 {
+		comp.CooldownTime = 5
+	}
+	if comp.TurnRate == 0 {
+		comp.TurnRate = TurnRate / 8
+	}
+	if err := sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding warp: %w", err)
+	}
+	return nil
+}
+func (comp Warp) Update(sm ecstypes.SystemManager) (Warp, error) {
+	var input HelmInput
+	helm, err := GetComponent[Helm](sm, comp.Entity)
+	if err != nil {
+		return comp, err
+	}
+	if helm != nil {
+		input = helm.Input
+	}
+	var pressed = input.Warp && !comp.Held
+	comp.Held = input.Warp
+
+	var dt = sm.GetTimeStep()
+	switch comp.State {
+	case WarpImpulse:
+		if pressed {
+			comp.set(sm, WarpCharging, comp.ChargeTime)
+		}
+	case WarpCharging:
+		comp.Remaining -= dt
+		if pressed {
+			comp.set(sm, WarpImpulse, 0)
+		} else if comp.Remaining <= 0 {
+			comp.Factor = 1
+			comp.set(sm, WarpEngaged, 0)
+		}
+	case WarpEngaged:
+		if pressed {
+			comp.Drop(sm)
+		} else if input.Thrust {
+			comp.Factor = min(comp.MaxFactor, comp.Factor+WarpAcceleration*dt)
+		}
+	case WarpCooldown:
+		comp.Remaining -= dt
+		if comp.Remaining <= 0 {
+			comp.set(sm, WarpImpulse, 0)
+		}
+	}
+	return comp, nil
+}
+func (comp Warp) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemWarp
+}
+
+func (comp *Warp) set(sm ecstypes.SystemManager, state WarpState, remaining float64) {
+	comp.State = state
+	comp.Remaining = remaining
+	if state != WarpEngaged {
+		comp.Factor = 0
+	}
+	sm.Broadcast(ecstypes.ComponentMessage{
+		Entity:  comp.Entity,
+		Payload: WarpStatus{State: comp.State, Factor: comp.Factor, Remaining: comp.Remaining},
+	})
+}
+
+// Drop takes the ship out of warp, or stops it charging, and starts the
+// cooldown. The Helm brings it back to impulse speed.
+func (comp *Warp) Drop(sm ecstypes.SystemManager) {
+	if comp.State == WarpCharging || comp.State == WarpEngaged {
+		comp.set(sm, WarpCooldown, comp.CooldownTime)
+	}
+}
+
+// String describes the drive's state for the player.
+func (comp *Warp) String() string {
+	switch comp.State {
+	case WarpCharging:
+		return fmt.Sprintf("WARP CHARGING %.1fs", max(0, comp.Remaining))
+	case WarpEngaged:
+		return fmt.Sprintf("WARP %.1f", comp.Factor)
+	case WarpCooldown:
+		return fmt.Sprintf("WARP COOLDOWN %.1fs", max(0, comp.Remaining))
+	default:
+		return "IMPULSE"
+	}
+}
+
+// Engaged reports whether the ship is moving at warp.
+func (comp *Warp) Engaged() bool {
+	return comp.State == WarpEngaged
+}
+
+// countDownWarp counts down a client's charges and cooldowns for the HUD,
+// until the server says the state has changed.
+func (i *Instance) countDownWarp() {
+	var dt = i.GetTimeStep()
+	i.Warp.EachSorted(func(_ ecstypes.EntityID, warp *Warp) {
+		if warp.State == WarpCharging || warp.State == WarpCooldown {
+			warp.Remaining = max(0, warp.Remaining-dt)
+		}
+	})
+}
+
+// interdictWarp drops out of warp every ship that a GravityWell pulls on
+// harder than WarpInterdiction.
+func (i *Instance) interdictWarp() {
+	if i.GravityWell.Map.Len() == 0 {
+		return
+	}
+	var wells []*GravityWell
+	i.GravityWell.EachSorted(func(_ ecstypes.EntityID, comp *GravityWell) {
+		wells = append(wells, comp)
+	})
+	i.Warp.EachSorted(func(e ecstypes.EntityID, warp *Warp) {
+		position, ok := i.Position.GetComponent(e)
+		if !ok || (warp.State != WarpCharging && warp.State != WarpEngaged) {
+			return
+		}
+		for _, well := range wells {
+			if well.Entity == e {
+				continue
+			}
+			if well.Acceleration(i.World, position.Vector).Length() > WarpInterdiction {
+				warp.Drop(i)
+				return
+			}
+		}
+	})
+}
+
+// warpHelm flies a ship at warp: straight ahead at the warp's speed, turning
+// by no more than its TurnRate. The engines draw full thrust's power, and
+// the ship drops out when they run short.
+func (comp *Helm) warpHelm(sm ecstypes.SystemManager, warp *Warp, turn float64) error {
+	var dt = sm.GetTimeStep()
+	power, err := drawPower(sm, comp.Entity, PowerEngines, ThrustPower*dt)
+	if err != nil {
+		return err
+	}
+	if power < ThrustPower*dt {
+		warp.Drop(sm)
+		return nil
+	}
+	turn = max(-warp.TurnRate, min(warp.TurnRate, turn))
+	comp.Position.Angle = (comp.Position.Angle + geom.Angle(turn*dt)).Normalize()
+	comp.Motion.Velocity = comp.Position.Angle.ToVector().Multiply(WarpSpeed(warp.Factor))
+	return nil
+}
@@ -0,0 +1,147 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"slices"
+	"testing"
+)
+
+// newWarpShip returns an Instance with a ship, entity 0, whose drive charges
+// and cools down in half a second.
+func newWarpShip(t *testing.T) (*Instance, *Pipe) {
+	t.Helper()
+	var instance = NewInstance(Parameters{ScreenWidth: 100000, ScreenHeight: 100000})
+	var sent = NewPipe()
+	instance.SetSender(sent)
+	err := instance.AddEntity(0, &Position{Vector: geom.Vector{X: 1000, Y: 1000}}, new(Motion), new(Helm),
+		&Warp{ChargeTime: 0.5, CooldownTime: 0.5}, new(Hull))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instance, sent
+}
+
+// hold steps the Instance for seconds with the helm input held.
+func hold(t *testing.T, instance *Instance, input HelmInput, seconds float64) {
+	t.Helper()
+	var msgs = []ecstypes.ComponentMessage{{Entity: 0, Payload: input}}
+	for tick := 0; tick < int(seconds*DefaultTickRate); tick++ {
+		if err := instance.Step(msgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func warpStates(msgs []ecstypes.ComponentMessage) []WarpState {
+	var states []WarpState
+	for _, msg := range msgs {
+		if status, ok := msg.Payload.(WarpStatus); ok {
+			states = append(states, status.State)
+		}
+	}
+	return states
+}
+
+func TestWarpCycle(t *testing.T) {
+	var instance, sent = newWarpShip(t)
+	var warp, _ = instance.Warp.GetComponent(0)
+	var motion, _ = instance.Motion.GetComponent(0)
+
+	// held down across the charge, it's still one press
+	hold(t, instance, HelmInput{Warp: true}, 0.25)
+	if warp.State != WarpCharging {
+		t.Fatalf("%v after pressing warp", warp)
+	}
+	hold(t, instance, HelmInput{Warp: true}, 0.5)
+	if !warp.Engaged() || warp.Factor != 1 {
+		t.Fatalf("%v after charging", warp)
+	}
+	hold(t, instance, HelmInput{}, 0.1)
+	if speed := motion.Velocity.Length(); !near(speed, WarpSpeed(1)) {
+		t.Fatalf("moving at %v at warp 1, want %v", speed, WarpSpeed(1))
+	}
+	hold(t, instance, HelmInput{Thrust: true}, 1)
+	if warp.Factor < 1.9 || warp.Factor > 2 {
+		t.Fatalf("%v after a second of thrust", warp)
+	}
+	hold(t, instance, HelmInput{Warp: true}, 0.1)
+	if warp.State != WarpCooldown {
+		t.Fatalf("%v after pressing warp at warp", warp)
+	}
+	// pressing during the cooldown does nothing
+	hold(t, instance, HelmInput{}, 0.1)
+	hold(t, instance, HelmInput{Warp: true}, 0.1)
+	hold(t, instance, HelmInput{}, 0.4)
+	if warp.State != WarpImpulse {
+		t.Fatalf("%v after the cooldown", warp)
+	}
+	var want = []WarpState{WarpCharging, WarpEngaged, WarpCooldown, WarpImpulse}
+	if got := warpStates(drain(sent)); !slices.Equal(got, want) {
+		t.Fatalf("broadcast %v, want %v", got, want)
+	}
+}
+
+func TestWarpDrops(t *testing.T) {
+	var tests = []struct {
+		name string
+		drop func(instance *Instance)
+	}{
+		{"damage", func(instance *Instance) {
+			instance.ApplyDamage(ecstypes.Damage{Target: 0, Amount: 1})
+			instance.applyDamage()
+		}},
+		{"interdiction", func(instance *Instance) {
+			err := instance.AddEntity(1, &Position{Vector: geom.Vector{X: 1200, Y: 1000}}, &GravityWell{Mass: 1e7, Radius: 50})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		var instance, _ = newWarpShip(t)
+		var warp, _ = instance.Warp.GetComponent(0)
+		hold(t, instance, HelmInput{Warp: true}, 0.6)
+		if !warp.Engaged() {
+			t.Fatalf("%s: %v after charging", test.name, warp)
+		}
+		test.drop(instance)
+		hold(t, instance, HelmInput{}, 0.1)
+		if warp.State != WarpCooldown {
+			t.Errorf("%s: %v, want dropped out", test.name, warp)
+		}
+	}
+}
+
+func TestWarpStatusReplicates(t *testing.T) {
+	var server, sent = newWarpShip(t)
+	var client, _ = newWarpShip(t)
+	client.Client = true
+	hold(t, server, HelmInput{Warp: true}, 0.1)
+	if err := client.Step(drain(sent)); err != nil {
+		t.Fatal(err)
+	}
+	var warp, _ = client.Warp.GetComponent(0)
+	// counted down by a tick since the server sent it
+	if warp.State != WarpCharging || warp.Remaining < 0.45 || warp.Remaining > 0.5 {
+		t.Fatalf("client sees %v, want charging", warp)
+	}
+
+	// the client's own input doesn't engage the drive: only the server's
+	// WarpStatus does
+	var clientSent = NewPipe()
+	client.SetSender(clientSent)
+	var helm, _ = client.Helm.GetComponent(0)
+	for tick := 0; tick < DefaultTickRate; tick++ {
+		helm.Input = HelmInput{Warp: true}
+		if err := client.Step(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if warp.State != WarpCharging || warp.Remaining != 0 {
+		t.Errorf("client sees %v after a second on its own, want charged and waiting", warp)
+	}
+	if msgs := drain(clientSent); len(msgs) != 0 {
+		t.Errorf("client sent %+v", msgs)
+	}
+}
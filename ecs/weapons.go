@@ -0,0 +1,318 @@
+package ecs
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/vterr"
+	"slices"
+)
+
+type WeaponKind uint8
+
+const (
+	WeaponPhaser WeaponKind = iota + 1
+	WeaponTorpedo
+)
+
+var weaponKindNames = map[WeaponKind]string{
+	WeaponPhaser:  "phaser",
+	WeaponTorpedo: "torpedo",
+}
+
+// MarshalText writes the kind by name, as ship class files do.
+func (kind WeaponKind) MarshalText() ([]byte, error) {
+	name, ok := weaponKindNames[kind]
+	if !ok {
+		return nil, fmt.Errorf("weapon kind %d: %w", kind, ErrType)
+	}
+	return []byte(name), nil
+}
+
+func (kind *WeaponKind) UnmarshalText(text []byte) error {
+	for each, name := range weaponKindNames {
+		if name == string(text) {
+			*kind = each
+			return nil
+		}
+	}
+	return fmt.Errorf("weapon kind %q: %w", text, ErrType)
+}
+
+// beamDuration is how long a phaser beam stays on screen, in seconds.
+const beamDuration = 0.15
+
+// Hardpoint is a weapon mounted on a ship at Offset, pointing along Angle in
+// the ship's frame. Times are in seconds and speeds in pixels per second.
+// Phasers hit instantly anything within Range; torpedoes fly at Speed for
+// Lifetime.
+type Hardpoint struct {
+	Kind     WeaponKind
+	Offset   geom.Vector
+	Angle    geom.Angle
+	Cooldown float64
+	Energy   float64
+	Damage   float64
+	Range    float64
+	Speed    float64
+	Lifetime float64
+
+	// Ready counts down to when the weapon can fire again.
+	Ready float64
+}
+
+// PhaserBank and TorpedoTube are stock hardpoints, mounted at the bow.
+var (
+	PhaserBank = Hardpoint{
+		Kind:     WeaponPhaser,
+		Offset:   geom.Vector{X: 15},
+		Cooldown: 0.5,
+		Energy:   10,
+		Damage:   8,
+		Range:    300,
+	}
+	TorpedoTube = Hardpoint{
+		Kind:     WeaponTorpedo,
+		Offset:   geom.Vector{X: 15},
+		Cooldown: 2,
+		Energy:   30,
+		Damage:   40,
+		Speed:    400,
+		Lifetime: 2,
+	}
+)
+
+// Weapons fires a ship's Hardpoints when its Helm input asks, as long as
+// they are ready and there is Energy for them. Energy recharges at Recharge
+// per second up to MaxEnergy.
+//
+// Weapons run only where the game is simulated authoritatively. Beams and
+// torpedo launches are broadcast, so clients see them without running
+// Weapons themselves.
+type Weapons struct {
+	Entity   ecstypes.EntityID
+	Position *Position `json:"-"`
+
+	Hardpoints []Hardpoint
+	Energy     float64
+	MaxEnergy  float64
+	Recharge   float64
+}
+
+// NewWeapons returns fully charged Weapons with a PhaserBank and a
+// TorpedoTube.
+func NewWeapons() *Weapons {
+	return &Weapons{
+		Hardpoints: []Hardpoint{PhaserBank, TorpedoTube},
+		Energy:     100,
+		MaxEnergy:  100,
+		Recharge:   20,
+	}
+}
+
+func (comp Weapons) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	var err error
+	comp.Entity = entity
+	if comp.Position, err = GetComponent[Position](sm, entity); comp.Position == nil {
+		return fmt.Errorf("no Position found: %w", vterr.ErrMissing)
+	}
+	if err = sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding weapons: %w", err)
+	}
+	return nil
+}
+func (comp Weapons) Update(sm ecstypes.SystemManager) (Weapons, error) {
+	var dt = sm.GetTimeStep()
+	power, err := drawPower(sm, comp.Entity, PowerWeapons, min(comp.Recharge*dt, comp.MaxEnergy-comp.Energy))
+	if err != nil {
+		return comp, err
+	}
+	comp.Energy += power
+
+	var input HelmInput
+	helm, err := GetComponent[Helm](sm, comp.Entity)
+	if err != nil {
+		return comp, err
+	}
+	if helm != nil {
+		input = helm.Input
+	}
+
+	for index := range comp.Hardpoints {
+		var hardpoint = &comp.Hardpoints[index]
+		hardpoint.Ready = max(0, hardpoint.Ready-dt)
+		var firing = (hardpoint.Kind == WeaponPhaser && input.FirePhaser) ||
+			(hardpoint.Kind == WeaponTorpedo && input.FireTorpedo)
+		if !firing || hardpoint.Ready > 0 || comp.Energy < hardpoint.Energy {
+			continue
+		}
+		comp.Energy -= hardpoint.Energy
+		hardpoint.Ready = hardpoint.Cooldown
+		switch hardpoint.Kind {
+		case WeaponPhaser:
+			comp.firePhaser(sm, hardpoint)
+		case WeaponTorpedo:
+			comp.fireTorpedo(sm, hardpoint)
+		}
+	}
+	return comp, nil
+}
+func (comp Weapons) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemWeapons
+}
+
+// Clone copies the weapons with their own Hardpoints, which firing changes
+// in place.
+func (comp Weapons) Clone() Weapons {
+	comp.Hardpoints = slices.Clone(comp.Hardpoints)
+	return comp
+}
+
+// muzzle returns where a hardpoint is and which way it points in the world.
+func (comp *Weapons) muzzle(hardpoint *Hardpoint) (geom.Vector, geom.Angle) {
+	var pose = geom.Pose(comp.Position.Vector, comp.Position.Angle)
+	return pose.Apply(hardpoint.Offset), comp.Position.Angle + hardpoint.Angle
+}
+
+func (comp *Weapons) firePhaser(sm ecstypes.SystemManager, hardpoint *Hardpoint) {
+	var from, angle = comp.muzzle(hardpoint)
+	var direction = angle.ToVector()
+	var to = from.Add(direction.Multiply(hardpoint.Range))
+	var hits = sm.Raycast(ecstypes.Ray{
+		Origin:    from,
+		Direction: direction,
+		Length:    hardpoint.Range,
+		Mask:      LayerShip | LayerObstacle,
+		Ignore:    []ecstypes.EntityID{comp.Entity},
+	})
+	if len(hits) > 0 {
+		var hit = hits[0]
+		to = from.Add(direction.Multiply(hit.Distance))
+		sm.ApplyDamage(ecstypes.Damage{
+			Target:    hit.Entity,
+			Source:    comp.Entity,
+			Amount:    hardpoint.Damage,
+			Point:     hit.Point,
+			Direction: direction,
+		})
+	}
+	sm.Broadcast(ecstypes.ComponentMessage{
+		Entity:  comp.Entity,
+		Payload: PhaserBeam{From: from, To: sm.GetWorld().Wrap(to)},
+	})
+}
+
+func (comp *Weapons) fireTorpedo(sm ecstypes.SystemManager, hardpoint *Hardpoint) {
+	var from, angle = comp.muzzle(hardpoint)
+	var velocity = angle.ToVector().Multiply(hardpoint.Speed)
+	if motion, _ := GetComponent[Motion](sm, comp.Entity); motion != nil {
+		velocity = velocity.Add(motion.Velocity)
+	}
+	sm.Broadcast(ecstypes.ComponentMessage{
+		Entity: sm.NewEntity(),
+		Payload: TorpedoLaunch{
+			Owner:    comp.Entity,
+			Position: sm.GetWorld().Wrap(from),
+			Velocity: velocity,
+			Angle:    angle,
+			Damage:   hardpoint.Damage,
+			Lifetime: hardpoint.Lifetime,
+		},
+	})
+}
+
+// PhaserBeam is broadcast when a phaser fires, for drawing.
+type PhaserBeam struct {
+	From geom.Vector
+	To   geom.Vector
+}
+
+// TorpedoLaunch is broadcast with the new torpedo's entity when one is fired.
+type TorpedoLaunch struct {
+	Owner    ecstypes.EntityID
+	Position geom.Vector
+	Velocity geom.Vector
+	Angle    geom.Angle
+	Damage   float64
+	Lifetime float64
+}
+
+// TorpedoOutline is a small diamond.
+var TorpedoOutline = geom.Outline{{X: 4}, {Y: 2}, {X: -4}, {Y: -2}}
+
+// launchTorpedo adds a torpedo entity. Where the game is simulated it is a
+// projectile; on a client it only flies and is drawn until the server
+// despawns it.
+func (i *Instance) launchTorpedo(e ecstypes.EntityID, launch TorpedoLaunch, simulated bool) error {
+	var components = []ecstypes.Component{
+		Position{Vector: launch.Position, Angle: launch.Angle},
+		Motion{Velocity: launch.Velocity},
+		Sprite{Outline: TorpedoOutline},
+	}
+	if simulated {
+		components = append(components,
+			Collider{Shape: geom.Circle(3), Layer: LayerProjectile, Mask: LayerShip | LayerObstacle},
+			Torpedo{Owner: launch.Owner, Damage: launch.Damage, Lifetime: launch.Lifetime},
+		)
+	}
+	if err := i.AddEntity(e, components...); err != nil {
+		return fmt.Errorf("launching torpedo %d: %w", e, err)
+	}
+	return nil
+}
+
+// Torpedo damages the first thing it hits other than the ship that fired it,
+// then is despawned, as it is when its Lifetime in seconds runs out.
+type Torpedo struct {
+	Entity   ecstypes.EntityID
+	Owner    ecstypes.EntityID
+	Damage   float64
+	Lifetime float64
+}
+
+func (comp Torpedo) Init(sm ecstypes.SystemManager, entity ecstypes.EntityID) error {
+	comp.Entity = entity
+	if err := sm.AddComponent(entity, comp); err != nil {
+		return fmt.Errorf("adding torpedo: %w", err)
+	}
+	return nil
+}
+func (comp Torpedo) Update(sm ecstypes.SystemManager) (Torpedo, error) {
+	if comp.Lifetime <= 0 {
+		// already despawned, waiting for the end of the tick
+		return comp, nil
+	}
+	for _, collision := range sm.GetCollisions(comp.Entity) {
+		if collision.Other == comp.Owner {
+			continue
+		}
+		var direction geom.Vector
+		if motion, _ := GetComponent[Motion](sm, comp.Entity); motion != nil {
+			direction = motion.Velocity.Normalize()
+		}
+		sm.ApplyDamage(ecstypes.Damage{
+			Target:    collision.Other,
+			Source:    comp.Owner,
+			Amount:    comp.Damage,
+			Point:     collision.Point,
+			Direction: direction,
+		})
+		comp.Lifetime = 0
+		sm.Despawn(comp.Entity)
+		return comp, nil
+	}
+	comp.Lifetime -= sm.GetTimeStep()
+	if comp.Lifetime <= 0 {
+		sm.Despawn(comp.Entity)
+	}
+	return comp, nil
+}
+func (comp Torpedo) SystemID() ecstypes.SystemID {
+	return ecstypes.SystemTorpedo
+}
+
+// Beam is a phaser beam being drawn.
+type Beam struct {
+	PhaserBeam
+	Remaining float64
+}
@@ -0,0 +1,171 @@
+package ecs
+
+import (
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"testing"
+)
+
+// newRange returns an Instance with a ship, entity 0, facing along +x at
+// (100, 500) with the given hardpoint, and targets, entities 1 and up, at
+// the given distances ahead of it. What the Instance sends goes to the
+// returned Pipe.
+func newRange(t *testing.T, hardpoint Hardpoint, distances ...float64) (*Instance, *Pipe) {
+	t.Helper()
+	var instance = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	var sent = NewPipe()
+	instance.SetSender(sent)
+	var weapons = &Weapons{Hardpoints: []Hardpoint{hardpoint}, Energy: 100, MaxEnergy: 100}
+	err := instance.AddEntity(0, &Position{Vector: geom.Vector{X: 100, Y: 500}}, new(Motion), new(Helm),
+		&Collider{Shape: geom.Circle(10)}, weapons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, distance := range distances {
+		var at = geom.Vector{X: 100 + distance, Y: 500}
+		if err := instance.AddEntity(ecstypes.EntityID(n+1), &Position{Vector: at}, &Collider{Shape: geom.Circle(10)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return instance, sent
+}
+
+func fire(input HelmInput) []ecstypes.ComponentMessage {
+	return []ecstypes.ComponentMessage{{Entity: 0, Payload: input}}
+}
+
+func drain(pipe *Pipe) []ecstypes.ComponentMessage {
+	var msgs []ecstypes.ComponentMessage
+	for msg, ok := pipe.Receive(); ok; msg, ok = pipe.Receive() {
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func TestPhaserHitsNearest(t *testing.T) {
+	var instance, sent = newRange(t, PhaserBank, 250, 150)
+	if err := instance.Step(fire(HelmInput{FirePhaser: true})); err != nil {
+		t.Fatal(err)
+	}
+	if len(instance.Damage) != 1 || instance.Damage[0].Target != 2 || instance.Damage[0].Amount != PhaserBank.Damage {
+		t.Fatalf("damage %+v, want the nearer target hit", instance.Damage)
+	}
+	var msgs = drain(sent)
+	if len(msgs) != 1 {
+		t.Fatalf("sent %+v, want one beam", msgs)
+	}
+	if beam, ok := msgs[0].Payload.(PhaserBeam); !ok || !near(beam.To.X, 240) {
+		t.Fatalf("sent %+v, want a beam stopping at the target", msgs[0])
+	}
+	var weapons, _ = instance.Weapons.GetComponent(0)
+	if weapons.Energy != 100-PhaserBank.Energy {
+		t.Errorf("energy %v after firing", weapons.Energy)
+	}
+
+	// still held down, but cooling down
+	if err := instance.Step(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(instance.Damage) != 0 {
+		t.Errorf("fired again before the cooldown: %+v", instance.Damage)
+	}
+}
+
+func TestPhaserOutOfRange(t *testing.T) {
+	var instance, _ = newRange(t, PhaserBank, PhaserBank.Range+50)
+	if err := instance.Step(fire(HelmInput{FirePhaser: true})); err != nil {
+		t.Fatal(err)
+	}
+	if len(instance.Damage) != 0 || len(instance.Beams) != 1 {
+		t.Fatalf("damage %+v and beams %+v, want a beam hitting nothing", instance.Damage, instance.Beams)
+	}
+}
+
+func TestTorpedoReplicates(t *testing.T) {
+	var server, sent = newRange(t, TorpedoTube, 200)
+	var client = NewInstance(Parameters{ScreenWidth: 1000, ScreenHeight: 1000})
+	client.Client = true
+	var damage []ecstypes.Damage
+	var torpedo ecstypes.EntityID
+	for tick := 0; tick < 60 && len(damage) == 0; tick++ {
+		var msgs []ecstypes.ComponentMessage
+		if tick == 0 {
+			msgs = fire(HelmInput{FireTorpedo: true})
+		}
+		if err := server.Step(msgs); err != nil {
+			t.Fatal(err)
+		}
+		damage = append(damage, server.Damage...)
+		var broadcast = drain(sent)
+		if tick == 0 {
+			if len(broadcast) != 1 {
+				t.Fatalf("sent %+v, want a launch", broadcast)
+			}
+			torpedo = broadcast[0].Entity
+		}
+		if err := client.Step(broadcast); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := client.Position.GetComponent(torpedo); !ok && len(damage) == 0 {
+			t.Fatalf("tick %d: torpedo %d isn't on the client", tick, torpedo)
+		}
+		if _, ok := client.Torpedo.GetComponent(torpedo); ok {
+			t.Fatal("the client simulates the torpedo")
+		}
+	}
+	if len(damage) != 1 || damage[0].Target != 1 || damage[0].Source != 0 || damage[0].Amount != TorpedoTube.Damage {
+		t.Fatalf("damage %+v, want the target hit by the ship", damage)
+	}
+	for name, instance := range map[string]*Instance{"server": server, "client": client} {
+		if _, ok := instance.Position.GetComponent(torpedo); ok {
+			t.Errorf("torpedo still on the %s after hitting", name)
+		}
+	}
+}
+
+func TestServerIgnoresBroadcasts(t *testing.T) {
+	var server, sent = newRange(t, TorpedoTube, 200)
+	var forged = []ecstypes.ComponentMessage{
+		{Entity: 1, Payload: Despawned{}},
+		{Entity: 5, Payload: TorpedoLaunch{Owner: 1, Position: geom.Vector{X: 250, Y: 500}, Velocity: geom.Vector{X: -400}, Lifetime: 1}},
+	}
+	if err := server.Step(forged); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Position.GetComponent(1); !ok {
+		t.Error("a client despawned the target")
+	}
+	if _, ok := server.Position.GetComponent(5); ok {
+		t.Error("a client launched a torpedo")
+	}
+	if msgs := drain(sent); len(msgs) != 0 {
+		t.Errorf("passed on %+v", msgs)
+	}
+}
+
+func TestTorpedoExpires(t *testing.T) {
+	var instance, sent = newRange(t, TorpedoTube)
+	var ticks = int(TorpedoTube.Lifetime*DefaultTickRate) + 1
+	for tick := 0; tick <= ticks; tick++ {
+		var msgs []ecstypes.ComponentMessage
+		switch tick {
+		case 0:
+			msgs = fire(HelmInput{FireTorpedo: true})
+		case 1:
+			msgs = fire(HelmInput{})
+		}
+		if err := instance.Step(msgs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var msgs = drain(sent)
+	if len(msgs) != 2 {
+		t.Fatalf("sent %+v, want a launch and a despawn", msgs)
+	}
+	if _, ok := msgs[1].Payload.(Despawned); !ok || msgs[1].Entity != msgs[0].Entity {
+		t.Fatalf("sent %+v, want the torpedo despawned", msgs[1])
+	}
+	if instance.Torpedo.Map.Len() != 0 {
+		t.Error("expired torpedo is still simulated")
+	}
+}
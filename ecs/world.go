@@ -0,0 +1,269 @@
+package ecs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"io"
+)
+
+var ErrVersion = errors.New("incompatible version")
+
+const (
+	worldMagic = "VTWORLD\x00"
+	// WorldVersion is bumped whenever a saved component's fields change.
+	WorldVersion = 17
+)
+
+// WorldState is a copy of every entity and component in an Instance that can
+// be saved as JSON or binary. Component fields tagged `json:"-"` (pointers
+// to sibling components, channels, render buffers) are not saved; Init sets
+// them up again when the world is loaded.
+type WorldState struct {
+	Version    int
+	Parameters Parameters
+	Counter    uint64
+	NextEntity ecstypes.EntityID
+	Sector     SectorCoord
+	RandState  []byte
+	Entities   []EntityState
+}
+
+// EntityState holds the components of one entity. Nil means the entity has
+// no component in that system.
+type EntityState struct {
+	Entity       ecstypes.EntityID
+	Position     *Position     `json:",omitempty"`
+	Motion       *Motion       `json:",omitempty"`
+	Helm         *Helm         `json:",omitempty"`
+	Sprite       *Sprite       `json:",omitempty"`
+	Player       *Player       `json:",omitempty"`
+	SyncReceiver *SyncReceiver `json:",omitempty"`
+	SyncSender   *SyncSender   `json:",omitempty"`
+	Collider     *Collider     `json:",omitempty"`
+	Body         *Body         `json:",omitempty"`
+	GravityWell  *GravityWell  `json:",omitempty"`
+	Orbit        *Orbit        `json:",omitempty"`
+	Parent       *Parent       `json:",omitempty"`
+	Weapons      *Weapons      `json:",omitempty"`
+	Torpedo      *Torpedo      `json:",omitempty"`
+	Shields      *Shields      `json:",omitempty"`
+	Hull         *Hull         `json:",omitempty"`
+	Reactor      *Reactor      `json:",omitempty"`
+	Warp         *Warp         `json:",omitempty"`
+	AI           *AI           `json:",omitempty"`
+	Brain        *Brain        `json:",omitempty"`
+	Fleet        *Fleet        `json:",omitempty"`
+	Wingman      *Wingman      `json:",omitempty"`
+	Replicated   *Replicated   `json:",omitempty"`
+}
+
+func (state EntityState) components() []ecstypes.Component {
+	var components []ecstypes.Component
+	components = appendComponent(components, state.Position)
+	components = appendComponent(components, state.Motion)
+	components = appendComponent(components, state.Helm)
+	components = appendComponent(components, state.Sprite)
+	components = appendComponent(components, state.Player)
+	components = appendComponent(components, state.SyncReceiver)
+	components = appendComponent(components, state.SyncSender)
+	components = appendComponent(components, state.Collider)
+	components = appendComponent(components, state.Body)
+	components = appendComponent(components, state.GravityWell)
+	components = appendComponent(components, state.Orbit)
+	components = appendComponent(components, state.Parent)
+	components = appendComponent(components, state.Weapons)
+	components = appendComponent(components, state.Torpedo)
+	components = appendComponent(components, state.Shields)
+	components = appendComponent(components, state.Hull)
+	components = appendComponent(components, state.Reactor)
+	components = appendComponent(components, state.Warp)
+	components = appendComponent(components, state.AI)
+	components = appendComponent(components, state.Brain)
+	components = appendComponent(components, state.Fleet)
+	components = appendComponent(components, state.Wingman)
+	components = appendComponent(components, state.Replicated)
+	return components
+}
+
+func appendComponent[T ecstypes.Component](components []ecstypes.Component, comp *T) []ecstypes.Component {
+	if comp == nil {
+		return components
+	}
+	return append(components, *comp)
+}
+
+// copyComponent returns a copy of the component of e stored in sys, or nil.
+func copyComponent[T ecstypes.Component](sys *SMSystem[T], e ecstypes.EntityID) *T {
+	comp, ok := sys.GetComponent(e)
+	if !ok {
+		return nil
+	}
+	var result = *comp
+	return &result
+}
+
+// SaveWorld copies the state of every entity in the Instance.
+func (i *Instance) SaveWorld() (*WorldState, error) {
+	randState, err := i.RandSource.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("saving rand state: %w", err)
+	}
+	var state = &WorldState{
+		Version:    WorldVersion,
+		Parameters: i.Parameters,
+		Counter:    i.Counter,
+		NextEntity: i.NextEntity,
+		Sector:     i.Sector,
+		RandState:  randState,
+	}
+	for _, e := range i.sortedEntities() {
+		state.Entities = append(state.Entities, i.SaveEntity(e))
+	}
+	return state, nil
+}
+
+// SaveEntity copies the components of one entity.
+func (i *Instance) SaveEntity(e ecstypes.EntityID) EntityState {
+	return EntityState{
+		Entity:       e,
+		Position:     copyComponent(i.Position, e),
+		Motion:       copyComponent(i.Motion, e),
+		Helm:         copyComponent(i.Helm, e),
+		Sprite:       copyComponent(i.Sprite, e),
+		Player:       copyComponent(i.Player, e),
+		SyncReceiver: copyComponent(i.SyncReceiver, e),
+		SyncSender:   copyComponent(i.SyncSender, e),
+		Collider:     copyComponent(i.Collider, e),
+		Body:         copyComponent(i.Body, e),
+		GravityWell:  copyComponent(i.GravityWell, e),
+		Orbit:        copyComponent(i.Orbit, e),
+		Parent:       copyComponent(i.Parent, e),
+		Weapons:      copyComponent(i.Weapons, e),
+		Torpedo:      copyComponent(i.Torpedo, e),
+		Shields:      copyComponent(i.Shields, e),
+		Hull:         copyComponent(i.Hull, e),
+		Reactor:      copyComponent(i.Reactor, e),
+		Warp:         copyComponent(i.Warp, e),
+		AI:           copyComponent(i.AI, e),
+		Brain:        copyComponent(i.Brain, e),
+		Fleet:        copyComponent(i.Fleet, e),
+		Wingman:      copyComponent(i.Wingman, e),
+		Replicated:   copyComponent(i.Replicated, e),
+	}
+}
+
+// LoadWorld restores a saved world into an Instance that has no entities.
+// Components are added through AddEntity, so each one's Init re-resolves the
+// pointers to its sibling components.
+func (i *Instance) LoadWorld(state *WorldState) error {
+	if state.Version != WorldVersion {
+		return fmt.Errorf("world version %d, this build reads version %d: %w", state.Version, WorldVersion, ErrVersion)
+	}
+	if len(i.Entities) != 0 {
+		return fmt.Errorf("loading into an instance with %d entities: %w", len(i.Entities), ErrType)
+	}
+	if err := i.RandSource.UnmarshalBinary(state.RandState); err != nil {
+		return fmt.Errorf("restoring rand state: %w", err)
+	}
+	i.Counter = state.Counter
+	i.NextEntity = state.NextEntity
+	i.Sector = state.Sector
+	for _, entity := range state.Entities {
+		if err := i.AddEntity(entity.Entity, entity.components()...); err != nil {
+			return fmt.Errorf("restoring entity %d: %w", entity.Entity, err)
+		}
+	}
+	return nil
+}
+
+// NewInstanceFromWorld creates an Instance with the saved Parameters and loads
+// the saved world into it.
+func NewInstanceFromWorld(state *WorldState) (*Instance, error) {
+	var instance = NewInstance(state.Parameters)
+	if err := instance.LoadWorld(state); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func WriteWorldJSON(w io.Writer, state *WorldState) error {
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(state); err != nil {
+		return fmt.Errorf("encoding world: %w", err)
+	}
+	return nil
+}
+
+func ReadWorldJSON(r io.Reader) (*WorldState, error) {
+	var header struct {
+		Version *int
+	}
+	var state = new(WorldState)
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decoding world: %w", err)
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, fmt.Errorf("decoding world: %w", err)
+	}
+	if header.Version == nil {
+		return nil, fmt.Errorf("world has no version: %w", ErrVersion)
+	}
+	if *header.Version != WorldVersion {
+		return nil, fmt.Errorf("world version %d, this build reads version %d: %w", *header.Version, WorldVersion, ErrVersion)
+	}
+	if err := json.Unmarshal(raw, state); err != nil {
+		return nil, fmt.Errorf("decoding world: %w", err)
+	}
+	return state, nil
+}
+
+// WriteWorldBinary writes state in the compact binary format: a magic
+// string, the version, then every saved field in declaration order.
+func WriteWorldBinary(w io.Writer, state *WorldState) error {
+	var bw = bufio.NewWriter(w)
+	if err := writeWorldBinary(bw, state); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func ReadWorldBinary(r io.Reader) (*WorldState, error) {
+	return readWorldBinary(bufio.NewReader(r))
+}
+
+func writeWorldBinary(w io.Writer, state *WorldState) error {
+	if _, err := io.WriteString(w, worldMagic); err != nil {
+		return fmt.Errorf("writing world: %w", err)
+	}
+	if err := encodeValue(w, *state); err != nil {
+		return fmt.Errorf("writing world: %w", err)
+	}
+	return nil
+}
+
+func readWorldBinary(r io.Reader) (*WorldState, error) {
+	var magic [len(worldMagic)]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || string(magic[:]) != worldMagic {
+		return nil, fmt.Errorf("not a binary world: %w", ErrVersion)
+	}
+	// Version is the first field, check it before decoding the rest with a
+	// layout that may not match.
+	var version int64
+	if err := decodeValue(r, &version); err != nil {
+		return nil, fmt.Errorf("reading world version: %w", err)
+	}
+	if version != WorldVersion {
+		return nil, fmt.Errorf("world version %d, this build reads version %d: %w", version, WorldVersion, ErrVersion)
+	}
+	var state = new(WorldState)
+	state.Version = int(version)
+	if err := decodeFields(r, state, 1); err != nil {
+		return nil, fmt.Errorf("reading world: %w", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,122 @@
+package ecs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// savedWorld returns a world part way through a run, and its saved state.
+func savedWorld(t *testing.T) (*Instance, *WorldState) {
+	t.Helper()
+	var instance = newShips(t, Parameters{Deterministic: true, Seed: 5}, 3)
+	for tick := 0; tick < 50; tick++ {
+		instance.Step(scriptedMessages(3, tick))
+	}
+	state, err := instance.SaveWorld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instance, state
+}
+
+func TestWorldRoundTrip(t *testing.T) {
+	var formats = []struct {
+		name  string
+		write func(buf *bytes.Buffer, state *WorldState) error
+		read  func(buf *bytes.Buffer) (*WorldState, error)
+	}{
+		{
+			"json",
+			func(buf *bytes.Buffer, state *WorldState) error { return WriteWorldJSON(buf, state) },
+			func(buf *bytes.Buffer) (*WorldState, error) { return ReadWorldJSON(buf) },
+		},
+		{
+			"binary",
+			func(buf *bytes.Buffer, state *WorldState) error { return WriteWorldBinary(buf, state) },
+			func(buf *bytes.Buffer) (*WorldState, error) { return ReadWorldBinary(buf) },
+		},
+	}
+	for _, format := range formats {
+		var original, state = savedWorld(t)
+		var buf bytes.Buffer
+		if err := format.write(&buf, state); err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		loaded, err := format.read(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		restored, err := NewInstanceFromWorld(loaded)
+		if err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		if restored.Checksum() != original.Checksum() {
+			t.Fatalf("%s: loaded world differs from the saved one", format.name)
+		}
+		// the random numbers carry on from where they were, too
+		for tick := 50; tick < 100; tick++ {
+			original.Step(scriptedMessages(3, tick))
+			restored.Step(scriptedMessages(3, tick))
+			if restored.LastChecksum != original.LastChecksum {
+				t.Fatalf("%s: loaded world diverged at tick %d", format.name, tick)
+			}
+		}
+		if restored.Rand.Uint64() != original.Rand.Uint64() {
+			t.Fatalf("%s: random numbers differ", format.name)
+		}
+	}
+}
+
+func TestWorldRejectsOtherFiles(t *testing.T) {
+	var _, state = savedWorld(t)
+	var buf bytes.Buffer
+	if err := WriteWorldBinary(&buf, state); err != nil {
+		t.Fatal(err)
+	}
+	var data = buf.Bytes()
+	var badMagic = bytes.Clone(data)
+	badMagic[0] = 'X'
+	var badVersion = bytes.Clone(data)
+	badVersion[len(worldMagic)]++
+	for name, file := range map[string][]byte{
+		"wrong magic":   badMagic,
+		"wrong version": badVersion,
+	} {
+		if _, err := ReadWorldBinary(bytes.NewReader(file)); !errors.Is(err, ErrVersion) {
+			t.Errorf("%s: got %v, want ErrVersion", name, err)
+		}
+	}
+	for _, length := range []int{0, len(worldMagic) + 4, len(data) / 2, len(data) - 1} {
+		if _, err := ReadWorldBinary(bytes.NewReader(data[:length])); err == nil {
+			t.Errorf("read a world cut off after %d of %d bytes", length, len(data))
+		}
+	}
+	// a corrupt length prefix mustn't allocate what it claims
+	var huge = []byte{0xff, 0xff, 0xff, 0xff}
+	for name, value := range map[string]any{"string": new(string), "bytes": new([]byte), "slice": new([]Hardpoint)} {
+		if err := decodeValue(bytes.NewReader(huge), value); !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			t.Errorf("%s of 4G: got %v, want EOF", name, err)
+		}
+	}
+
+	for name, file := range map[string]string{
+		"no version":    `{"Counter": 1}`,
+		"wrong version": `{"Version": 0}`,
+	} {
+		if _, err := ReadWorldJSON(bytes.NewReader([]byte(file))); !errors.Is(err, ErrVersion) {
+			t.Errorf("json %s: got %v, want ErrVersion", name, err)
+		}
+	}
+	var old = *state
+	old.Version--
+	if _, err := NewInstanceFromWorld(&old); !errors.Is(err, ErrVersion) {
+		t.Errorf("loading an old world: got %v, want ErrVersion", err)
+	}
+
+	var full = newShips(t, Parameters{}, 1)
+	if err := full.LoadWorld(state); !errors.Is(err, ErrType) {
+		t.Errorf("loading into a full instance: got %v, want ErrType", err)
+	}
+}
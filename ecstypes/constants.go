@@ -8,4 +8,20 @@ const (
 	SystemPlayer
 	SystemSyncReceiver
 	SystemSyncSender
+	SystemCollider
+	SystemBody
+	SystemGravityWell
+	SystemOrbit
+	SystemParent
+	SystemWeapons
+	SystemTorpedo
+	SystemShields
+	SystemHull
+	SystemReactor
+	SystemWarp
+	SystemAI
+	SystemBrain
+	SystemFleet
+	SystemWingman
+	SystemReplicated
 )
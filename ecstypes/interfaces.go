@@ -1,5 +1,11 @@
 package ecstypes
 
+import (
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/StCredZero/vectrek/spatial"
+	"math/rand/v2"
+)
+
 type System interface {
 	IsSystem()
 	SystemID() SystemID
@@ -14,6 +20,28 @@ type SystemManager interface {
 	GetReceiver() Receiver
 	GetCounter() uint64
 	GetName() string
+	GetRand() *rand.Rand
+	GetWorld() geom.Torus
+	// GetTimeStep returns the length of a tick in seconds.
+	GetTimeStep() float64
+	GetCollisions(e EntityID) []Collision
+	// GetSpatialIndex finds entities near a point, in a rectangle or along
+	// a ray.
+	GetSpatialIndex() *spatial.Index[EntityID]
+	// Raycast returns every Collider the ray hits, nearest first.
+	Raycast(ray Ray) []RayHit
+	// LineOfSight reports whether nothing on the mask's layers lies between
+	// two entities.
+	LineOfSight(from, to EntityID, mask uint32) bool
+	// NewEntity allocates an unused entity ID.
+	NewEntity() EntityID
+	// Broadcast applies msg at the end of the tick and sends it on to the
+	// Sender, so that spawns and effects reach clients.
+	Broadcast(msg ComponentMessage)
+	// Despawn removes an entity at the end of the tick, everywhere.
+	Despawn(e EntityID)
+	// ApplyDamage records damage dealt this tick.
+	ApplyDamage(damage Damage)
 }
 
 type Component interface {
@@ -25,6 +53,13 @@ type Sender interface {
 	Send(msg ComponentMessage)
 }
 
+// Router is a Sender that can also send to only the clients playing the
+// given entities.
+type Router interface {
+	Sender
+	SendTo(players []EntityID, msg ComponentMessage)
+}
+
 type Receiver interface {
 	Receive() (ComponentMessage, bool)
 }
@@ -33,3 +68,45 @@ type ComponentMessage struct {
 	Entity  EntityID
 	Payload any
 }
+
+// Collision is a contact between two entities found this tick, seen from
+// Entity: Normal points from Entity towards Other.
+type Collision struct {
+	Entity EntityID
+	Other  EntityID
+	geom.Contact
+}
+
+// Ray is a ray or segment cast against Colliders. Mask selects the collider
+// layers it can hit, zero meaning all of them, and entities in Ignore are
+// passed through, so a ship can look out from inside its own hull.
+type Ray struct {
+	Origin    geom.Vector
+	Direction geom.Vector
+	Length    float64
+	Mask      uint32
+	Ignore    []EntityID
+}
+
+// SegmentRay returns a ray from one point to another the short way round the
+// world.
+func SegmentRay(world geom.Torus, from, to geom.Vector, mask uint32) Ray {
+	var delta = world.Delta(from, to)
+	return Ray{Origin: from, Direction: delta.Normalize(), Length: delta.Length(), Mask: mask}
+}
+
+// RayHit is a Collider hit by a Ray. Point is wrapped into the world.
+type RayHit struct {
+	Entity EntityID
+	geom.RayHit
+}
+
+// Damage is dealt to Target by Source at Point, Direction being the way the
+// shot was travelling.
+type Damage struct {
+	Target    EntityID
+	Source    EntityID
+	Amount    float64
+	Point     geom.Vector
+	Direction geom.Vector
+}
@@ -0,0 +1,104 @@
+package geom
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Fixed is a signed 32.32 fixed-point number. Arithmetic on Fixed is exact
+// integer math, so results are identical on every platform.
+type Fixed int64
+
+const (
+	fixedShift       = 32
+	FixedOne   Fixed = 1 << fixedShift
+)
+
+func FixedFromInt(n int) Fixed {
+	return Fixed(n) << fixedShift
+}
+
+func FixedFromFloat(f float64) Fixed {
+	return Fixed(math.Round(f * float64(FixedOne)))
+}
+
+func (f Fixed) Float() float64 {
+	return float64(f) / float64(FixedOne)
+}
+
+func (f Fixed) Add(o Fixed) Fixed {
+	return f + o
+}
+
+func (f Fixed) Sub(o Fixed) Fixed {
+	return f - o
+}
+
+func (f Fixed) Mul(o Fixed) Fixed {
+	negative := (f < 0) != (o < 0)
+	hi, lo := bits.Mul64(fixedAbs(f), fixedAbs(o))
+	result := Fixed(hi<<fixedShift | lo>>fixedShift)
+	if negative {
+		return -result
+	}
+	return result
+}
+
+// Div divides f by o. It panics if o is zero or the quotient overflows.
+func (f Fixed) Div(o Fixed) Fixed {
+	negative := (f < 0) != (o < 0)
+	a := fixedAbs(f)
+	quo, _ := bits.Div64(a>>fixedShift, a<<fixedShift, fixedAbs(o))
+	result := Fixed(quo)
+	if negative {
+		return -result
+	}
+	return result
+}
+
+func fixedAbs(f Fixed) uint64 {
+	if f < 0 {
+		return uint64(-f)
+	}
+	return uint64(f)
+}
+
+type FixedVector struct {
+	X Fixed
+	Y Fixed
+}
+
+func (v Vector) ToFixed() FixedVector {
+	return FixedVector{
+		X: FixedFromFloat(v.X),
+		Y: FixedFromFloat(v.Y),
+	}
+}
+
+func (v FixedVector) ToVector() Vector {
+	return Vector{
+		X: v.X.Float(),
+		Y: v.Y.Float(),
+	}
+}
+
+func (v FixedVector) Add(ov FixedVector) FixedVector {
+	return FixedVector{
+		X: v.X + ov.X,
+		Y: v.Y + ov.Y,
+	}
+}
+
+func (v FixedVector) Sub(ov FixedVector) FixedVector {
+	return FixedVector{
+		X: v.X - ov.X,
+		Y: v.Y - ov.Y,
+	}
+}
+
+func (v FixedVector) Multiply(w Fixed) FixedVector {
+	return FixedVector{
+		X: v.X.Mul(w),
+		Y: v.Y.Mul(w),
+	}
+}
@@ -0,0 +1,42 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFixedRoundTrip(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var f = (2*r.Float64() - 1) * 1e6
+		if got := FixedFromFloat(f).Float(); !near(got, f, 1/float64(FixedOne)) {
+			t.Fatalf("%v round-trips to %v", f, got)
+		}
+	}
+}
+
+func TestFixedArithmetic(t *testing.T) {
+	var r = newRand()
+	var resolution = 1 / float64(FixedOne)
+	for trial := 0; trial < trials; trial++ {
+		var a, b = (2*r.Float64() - 1) * 1000, (2*r.Float64() - 1) * 1000
+		var fa, fb = FixedFromFloat(a), FixedFromFloat(b)
+		if fa.Add(fb).Sub(fb) != fa {
+			t.Fatalf("%v + %v - %v isn't exact", a, b, b)
+		}
+		if got := fa.Mul(fb).Float(); !near(got, fa.Float()*fb.Float(), 2*resolution) {
+			t.Fatalf("%v * %v = %v", a, b, got)
+		}
+		if fa.Mul(fb) != fb.Mul(fa) {
+			t.Fatalf("%v * %v isn't commutative", a, b)
+		}
+		if math.Abs(b) > 1e-3 {
+			if got := fa.Div(fb).Float(); !near(got, fa.Float()/fb.Float(), 1e-6*math.Max(1, math.Abs(got))) {
+				t.Fatalf("%v / %v = %v", a, b, got)
+			}
+		}
+	}
+	if FixedFromInt(3).Mul(FixedFromInt(-2)) != FixedFromInt(-6) {
+		t.Fatal("3 * -2 isn't -6")
+	}
+}
@@ -0,0 +1,29 @@
+package geom
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// trials is how many random cases each property is checked on.
+const trials = 1000
+
+func newRand() *rand.Rand {
+	return rand.New(rand.NewPCG(1, 2))
+}
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func randomVector(r *rand.Rand, scale float64) Vector {
+	return Vector{X: (2*r.Float64() - 1) * scale, Y: (2*r.Float64() - 1) * scale}
+}
+
+func randomAngle(r *rand.Rand) Angle {
+	return Angle((2*r.Float64() - 1) * 4 * math.Pi)
+}
+
+func nearVector(a, b Vector, tolerance float64) bool {
+	return near(a.X, b.X, tolerance) && near(a.Y, b.Y, tolerance)
+}
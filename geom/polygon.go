@@ -0,0 +1,269 @@
+package geom
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Outline is a simple polygon, convex or not, as its vertices in order.
+// Ship outlines are drawn from it and split into convex Shapes for
+// collision.
+//
+// Winding is measured with the y axis up: a positive SignedArea is
+// counterclockwise in those terms, clockwise on screen.
+type Outline []Vector
+
+// ConvexHull returns the smallest convex outline around points, with positive
+// winding and no collinear vertices.
+func ConvexHull(points []Vector) Outline {
+	var sorted = slices.Clone(points)
+	slices.SortFunc(sorted, func(a, b Vector) int {
+		if c := cmp.Compare(a.X, b.X); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Y, b.Y)
+	})
+	sorted = slices.Compact(sorted)
+	if len(sorted) < 3 {
+		return Outline(sorted)
+	}
+	// Andrew's monotone chain: lower hull then upper hull
+	var hull = make(Outline, 0, 2*len(sorted))
+	var build = func(p Vector, floor int) {
+		for len(hull) > floor && hull[len(hull)-1].Sub(hull[len(hull)-2]).Cross(p.Sub(hull[len(hull)-1])) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	for _, p := range sorted {
+		build(p, 1)
+	}
+	var lower = len(hull)
+	for index := len(sorted) - 2; index >= 0; index-- {
+		build(sorted[index], lower)
+	}
+	return hull[:len(hull)-1]
+}
+
+func (o Outline) SignedArea() float64 {
+	return signedArea(o)
+}
+
+func (o Outline) Area() float64 {
+	return math.Abs(o.SignedArea())
+}
+
+// Centroid returns the center of mass of the outline's area.
+func (o Outline) Centroid() Vector {
+	var area = o.SignedArea()
+	if area == 0 {
+		var sum Vector
+		for _, p := range o {
+			sum = sum.Add(p)
+		}
+		return sum.Multiply(1 / float64(max(1, len(o))))
+	}
+	var result Vector
+	for index, p := range o {
+		var q = o[(index+1)%len(o)]
+		result = result.Add(p.Add(q).Multiply(p.Cross(q)))
+	}
+	return result.Multiply(1 / (6 * area))
+}
+
+// Inertia returns the moment of inertia of a uniform plate of the outline's
+// shape and the given mass, about the local origin.
+func (o Outline) Inertia(mass float64) float64 {
+	var numerator, denominator float64
+	for index, p := range o {
+		var q = o[(index+1)%len(o)]
+		var cross = p.Cross(q)
+		numerator += cross * (p.Dot(p) + p.Dot(q) + q.Dot(q))
+		denominator += cross
+	}
+	if denominator == 0 {
+		return 0
+	}
+	return mass * numerator / (6 * denominator)
+}
+
+// Contains reports whether p is inside the outline, by the even-odd rule.
+func (o Outline) Contains(p Vector) bool {
+	var inside bool
+	for index, a := range o {
+		var b = o[(index+1)%len(o)]
+		if (a.Y > p.Y) != (b.Y > p.Y) {
+			if x := a.X + (p.Y-a.Y)/(b.Y-a.Y)*(b.X-a.X); p.X < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// IsConvex reports whether every turn of the outline goes the same way.
+func (o Outline) IsConvex() bool {
+	var positive, negative bool
+	for index, a := range o {
+		var b, c = o[(index+1)%len(o)], o[(index+2)%len(o)]
+		var turn = b.Sub(a).Cross(c.Sub(b))
+		positive = positive || turn > 0
+		negative = negative || turn < 0
+	}
+	return !(positive && negative)
+}
+
+// Reversed returns the outline wound the other way.
+func (o Outline) Reversed() Outline {
+	var result = slices.Clone(o)
+	slices.Reverse(result)
+	return result
+}
+
+// Transform returns the outline mapped by t, e.g. Pose to place a ship.
+func (o Outline) Transform(t Transform) Outline {
+	var result = make(Outline, len(o))
+	for index, p := range o {
+		result[index] = t.Apply(p)
+	}
+	return result
+}
+
+// Clip returns the part of the outline inside the convex outline clip, by
+// Sutherland-Hodgman. The result is empty if they don't overlap.
+func (o Outline) Clip(clip Outline) Outline {
+	if clip.SignedArea() < 0 {
+		clip = clip.Reversed()
+	}
+	var result = slices.Clone(o)
+	for index, a := range clip {
+		var b = clip[(index+1)%len(clip)]
+		var edge = b.Sub(a)
+		var inside = func(p Vector) bool {
+			return edge.Cross(p.Sub(a)) >= 0
+		}
+		var input = result
+		result = nil
+		for n, p := range input {
+			var q = input[(n+1)%len(input)]
+			if inside(p) {
+				result = append(result, p)
+			}
+			if inside(p) != inside(q) {
+				// where pq crosses the clip edge's line
+				var t = edge.Cross(a.Sub(p)) / edge.Cross(q.Sub(p))
+				result = append(result, p.Add(q.Sub(p).Multiply(t)))
+			}
+		}
+		if len(result) == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+// Triangulate splits the outline into triangles by ear clipping. Each has
+// positive winding.
+func (o Outline) Triangulate() []Outline {
+	var remaining = slices.Clone(o)
+	if remaining.SignedArea() < 0 {
+		slices.Reverse(remaining)
+	}
+	var result []Outline
+	for len(remaining) > 3 {
+		var clipped = false
+		for index := range remaining {
+			var a = remaining[(index+len(remaining)-1)%len(remaining)]
+			var b = remaining[index]
+			var c = remaining[(index+1)%len(remaining)]
+			if isEar(remaining, a, b, c) {
+				result = append(result, Outline{a, b, c})
+				remaining = slices.Delete(remaining, index, index+1)
+				clipped = true
+				break
+			}
+		}
+		if !clipped {
+			// not a simple polygon; keep what's left as one piece rather
+			// than loop forever
+			break
+		}
+	}
+	return append(result, remaining)
+}
+
+func isEar(points Outline, a, b, c Vector) bool {
+	if b.Sub(a).Cross(c.Sub(b)) <= 0 {
+		return false
+	}
+	var triangle = Outline{a, b, c}
+	for _, p := range points {
+		if p != a && p != b && p != c && insideConvex(triangle, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Decompose splits the outline into convex pieces with positive winding: a
+// triangulation with every diagonal removed that can be without making a
+// piece concave (Hertel-Mehlhorn). A convex outline comes back whole.
+func (o Outline) Decompose() []Outline {
+	if o.IsConvex() {
+		if o.SignedArea() < 0 {
+			return []Outline{o.Reversed()}
+		}
+		return []Outline{slices.Clone(o)}
+	}
+	var pieces = o.Triangulate()
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(pieces) && !merged; i++ {
+			for j := i + 1; j < len(pieces) && !merged; j++ {
+				if union, ok := mergeConvex(pieces[i], pieces[j]); ok {
+					pieces[i] = union
+					pieces = slices.Delete(pieces, j, j+1)
+					merged = true
+				}
+			}
+		}
+	}
+	return pieces
+}
+
+// mergeConvex joins two positively wound pieces along an edge they share, if
+// the result is convex.
+func mergeConvex(p, q Outline) (Outline, bool) {
+	for i, a := range p {
+		var b = p[(i+1)%len(p)]
+		for j, c := range q {
+			if c != b || q[(j+1)%len(q)] != a {
+				continue
+			}
+			// p from b round to a, then q between a and b
+			var union = make(Outline, 0, len(p)+len(q)-2)
+			for n := range p {
+				union = append(union, p[(i+1+n)%len(p)])
+			}
+			for n := 2; n < len(q); n++ {
+				union = append(union, q[(j+n)%len(q)])
+			}
+			if !union.IsConvex() {
+				return nil, false
+			}
+			return union, true
+		}
+	}
+	return nil, false
+}
+
+// Shapes returns the outline as convex collision Shapes.
+func (o Outline) Shapes() []Shape {
+	var pieces = o.Decompose()
+	var result = make([]Shape, len(pieces))
+	for index, piece := range pieces {
+		result[index] = Polygon(piece...)
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package geom
+
+import (
+	"slices"
+	"testing"
+)
+
+var (
+	square = Outline{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 2}, {X: 0, Y: 2}}
+	// an L, concave at (1, 1)
+	ell = Outline{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 1}, {X: 1, Y: 1}, {X: 1, Y: 2}, {X: 0, Y: 2}}
+	// a ship's arrowhead, notched at the back
+	arrow = Outline{{X: 15}, {X: -10, Y: 10}, {X: -4}, {X: -10, Y: -10}}
+)
+
+func TestOutlineMeasures(t *testing.T) {
+	var tests = []struct {
+		name     string
+		outline  Outline
+		area     float64
+		centroid Vector
+		convex   bool
+	}{
+		{"square", square, 4, Vector{X: 1, Y: 1}, true},
+		{"reversed square", square.Reversed(), 4, Vector{X: 1, Y: 1}, true},
+		{"ell", ell, 3, Vector{X: 5.0 / 6, Y: 5.0 / 6}, false},
+		{"arrow", arrow, 190, Vector{X: 1.0 / 3}, false},
+	}
+	for _, test := range tests {
+		if got := test.outline.Area(); !near(got, test.area, 1e-9) {
+			t.Errorf("%s: area %v, want %v", test.name, got, test.area)
+		}
+		if got := test.outline.Centroid(); !nearVector(got, test.centroid, 1e-9) {
+			t.Errorf("%s: centroid %v, want %v", test.name, got, test.centroid)
+		}
+		if got := test.outline.IsConvex(); got != test.convex {
+			t.Errorf("%s: convex %v, want %v", test.name, got, test.convex)
+		}
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	var points = append(slices.Clone(ell), Vector{X: 1, Y: 0.5}, Vector{X: 2, Y: 0})
+	var hull = ConvexHull(points)
+	var want = Outline{{X: 0, Y: 0}, {X: 2, Y: 0}, {X: 2, Y: 1}, {X: 1, Y: 2}, {X: 0, Y: 2}}
+	if !slices.Equal(hull, want) {
+		t.Fatalf("hull %v, want %v", hull, want)
+	}
+	if hull.SignedArea() <= 0 || !hull.IsConvex() {
+		t.Fatal("hull isn't convex with positive winding")
+	}
+}
+
+func TestClip(t *testing.T) {
+	var shifted = square.Transform(Pose(Vector{X: 1, Y: 1}, 0))
+	if got := square.Clip(shifted).Area(); !near(got, 1, 1e-9) {
+		t.Errorf("overlap of shifted squares has area %v, want 1", got)
+	}
+	if got := ell.Clip(square.Reversed()).Area(); !near(got, 3, 1e-9) {
+		t.Errorf("ell clipped by a square around it has area %v, want 3", got)
+	}
+	var apart = square.Transform(Pose(Vector{X: 5}, 0))
+	if got := square.Clip(apart); len(got) != 0 {
+		t.Errorf("squares apart overlap in %v", got)
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	var r = newRand()
+	for _, outline := range []Outline{square, ell, ell.Reversed(), arrow} {
+		for name, pieces := range map[string][]Outline{"triangles": outline.Triangulate(), "pieces": outline.Decompose()} {
+			var area float64
+			for _, piece := range pieces {
+				if !piece.IsConvex() || piece.SignedArea() <= 0 {
+					t.Fatalf("%v: %s %v isn't convex with positive winding", outline, name, piece)
+				}
+				for _, p := range piece {
+					if !slices.Contains(outline, p) {
+						t.Fatalf("%v: %s %v has a vertex not on the outline", outline, name, piece)
+					}
+				}
+				area += piece.Area()
+			}
+			if !near(area, outline.Area(), 1e-9) {
+				t.Fatalf("%v: %s cover %v of %v", outline, name, area, outline.Area())
+			}
+			// every point inside the outline is inside a piece
+			for trial := 0; trial < trials; trial++ {
+				var p = randomVector(r, 16)
+				var inPiece = slices.ContainsFunc(pieces, func(piece Outline) bool { return piece.Contains(p) })
+				if outline.Contains(p) && !inPiece {
+					t.Fatalf("%v: %v is in no %s", outline, p, name)
+				}
+			}
+		}
+	}
+	if got := len(ell.Decompose()); got != 2 {
+		t.Errorf("ell decomposed into %d pieces, want 2", got)
+	}
+	if got := len(square.Decompose()); got != 1 {
+		t.Errorf("square decomposed into %d pieces, want 1", got)
+	}
+}
@@ -0,0 +1,129 @@
+package geom
+
+import "math"
+
+// RayHit is where a ray first meets a shape: Distance along the ray from its
+// origin, the Point there and the outward Normal of the surface.
+type RayHit struct {
+	Distance float64
+	Point    Vector
+	Normal   Vector
+}
+
+// Contains reports whether p is inside the shape or on its boundary.
+func (s Shape) Contains(p Vector) bool {
+	if len(s.Points) >= 3 && insideConvex(s.Points, p) {
+		return true
+	}
+	for _, edge := range edges(s.Points) {
+		if p.DistanceSquared(ClosestPointOnSegment(p, edge[0], edge[1])) <= s.Radius*s.Radius {
+			return true
+		}
+	}
+	return false
+}
+
+func insideConvex(points []Vector, p Vector) bool {
+	var positive, negative bool
+	for index, a := range points {
+		var side = points[(index+1)%len(points)].Sub(a).Cross(p.Sub(a))
+		positive = positive || side > 0
+		negative = negative || side < 0
+	}
+	return !(positive && negative)
+}
+
+// Raycast finds where the ray from origin along the unit vector direction
+// first enters the shape within length. A ray starting inside the shape hits
+// it at distance zero, facing back along the ray.
+func (s Shape) Raycast(origin, direction Vector, length float64) (RayHit, bool) {
+	if s.Contains(origin) {
+		return RayHit{Point: origin, Normal: direction.Negate()}, true
+	}
+	var best = RayHit{Distance: math.Inf(1)}
+	var try = func(distance float64, normal Vector) {
+		if distance >= 0 && distance <= length && distance < best.Distance {
+			best = RayHit{Distance: distance, Point: origin.Add(direction.Multiply(distance)), Normal: normal}
+		}
+	}
+
+	// Flat sides: each edge of the core pushed out by Radius. A segment's
+	// single edge faces both ways.
+	var orientation = 1.0
+	if signedArea(s.Points) > 0 {
+		orientation = -1
+	}
+	for _, edge := range edges(s.Points) {
+		var along = edge[1].Sub(edge[0])
+		if along.LengthSquared() == 0 {
+			continue
+		}
+		var normals = []Vector{along.Perp().Normalize().Multiply(orientation)}
+		if len(s.Points) == 2 {
+			normals = append(normals, normals[0].Negate())
+		}
+		for _, normal := range normals {
+			// only the side facing the ray can be entered
+			if direction.Dot(normal) >= 0 {
+				continue
+			}
+			var offset = normal.Multiply(s.Radius)
+			if distance, ok := raySegment(origin, direction, edge[0].Add(offset), edge[1].Add(offset)); ok {
+				try(distance, normal)
+			}
+		}
+	}
+
+	// Rounded corners: a circle of Radius at each point of the core.
+	if s.Radius > 0 {
+		for _, p := range s.Points {
+			if distance, ok := rayCircle(origin, direction, p, s.Radius); ok {
+				try(distance, origin.Add(direction.Multiply(distance)).Sub(p).Normalize())
+			}
+		}
+	}
+	if math.IsInf(best.Distance, 1) {
+		return RayHit{}, false
+	}
+	return best, true
+}
+
+// signedArea is positive for points running counterclockwise with y up.
+func signedArea(points []Vector) float64 {
+	if len(points) < 3 {
+		return 0
+	}
+	var area float64
+	for index, p := range points {
+		area += p.Cross(points[(index+1)%len(points)])
+	}
+	return area / 2
+}
+
+// raySegment returns the distance along the ray to segment ab.
+func raySegment(origin, direction, a, b Vector) (float64, bool) {
+	var ab = b.Sub(a)
+	var denominator = direction.Cross(ab)
+	if denominator == 0 {
+		return 0, false
+	}
+	var ao = a.Sub(origin)
+	var distance = ao.Cross(ab) / denominator
+	var u = ao.Cross(direction) / denominator
+	if distance < 0 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return distance, true
+}
+
+// rayCircle returns the distance along the ray to where it enters the circle.
+func rayCircle(origin, direction, center Vector, radius float64) (float64, bool) {
+	var relative = center.Sub(origin)
+	var along = relative.Dot(direction)
+	var off = relative.Cross(direction)
+	if math.Abs(off) > radius {
+		return 0, false
+	}
+	var distance = along - math.Sqrt(radius*radius-off*off)
+	return distance, distance >= 0
+}
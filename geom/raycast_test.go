@@ -0,0 +1,56 @@
+package geom
+
+import "testing"
+
+func TestRaycast(t *testing.T) {
+	var square = box(1)
+	var tests = []struct {
+		name       string
+		shape      Shape
+		origin     Vector
+		direction  Vector
+		length     float64
+		hit        bool
+		distance   float64
+		wantNormal Vector
+	}{
+		{"circle head on", Circle(1), Vector{X: -5}, Vector{X: 1}, 10, true, 4, Vector{X: -1}},
+		{"circle glancing", Circle(1), Vector{X: -5, Y: 0.6}, Vector{X: 1}, 10, true, 4.2, Vector{X: -0.8, Y: 0.6}},
+		{"circle missed", Circle(1), Vector{X: -5, Y: 1.1}, Vector{X: 1}, 10, false, 0, Vector{}},
+		{"circle behind", Circle(1), Vector{X: 5}, Vector{X: 1}, 10, false, 0, Vector{}},
+		{"too short", Circle(1), Vector{X: -5}, Vector{X: 1}, 3.9, false, 0, Vector{}},
+		{"box side", square, Vector{Y: -5}, Vector{Y: 1}, 10, true, 4, Vector{Y: -1}},
+		{"box from inside", square, Vector{X: 0.5}, Vector{X: 1}, 10, true, 0, Vector{X: -1}},
+		{"segment from either side", Segment(Vector{Y: -1}, Vector{Y: 1}), Vector{X: 3}, Vector{X: -1}, 10, true, 3, Vector{X: 1}},
+		{"rounded corner", Shape{Points: square.Points, Radius: 1}, Vector{X: -5, Y: 1.6}, Vector{X: 1}, 10, true, 3.2, Vector{X: -0.8, Y: 0.6}},
+	}
+	for _, test := range tests {
+		var hit, ok = test.shape.Raycast(test.origin, test.direction, test.length)
+		if ok != test.hit {
+			t.Errorf("%s: hit %v, want %v", test.name, ok, test.hit)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !near(hit.Distance, test.distance, 1e-9) || !nearVector(hit.Normal, test.wantNormal, 1e-9) {
+			t.Errorf("%s: hit %+v, want distance %v and normal %v", test.name, hit, test.distance, test.wantNormal)
+		}
+		if !nearVector(hit.Point, test.origin.Add(test.direction.Multiply(hit.Distance)), 1e-9) {
+			t.Errorf("%s: hit point %v isn't on the ray", test.name, hit.Point)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var shape = randomShape(r)
+		var p = randomVector(r, 4)
+		// a point is inside exactly when a tiny circle there touches the shape
+		var _, touching = Collide(shape, Shape{Points: []Vector{p}, Radius: 1e-9})
+		if shape.Contains(p) != touching {
+			t.Fatalf("%+v Contains(%v) = %v, Collide says %v", shape, p, shape.Contains(p), touching)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package geom
+
+import "math"
+
+type ShapeKind uint8
+
+const (
+	ShapeCircle ShapeKind = iota + 1
+	ShapePolygon
+	ShapeSegment
+)
+
+// Shape is a convex collision shape: a core of Points (one point for a
+// circle, two for a segment, a convex polygon in either winding) grown by
+// Radius. A circle is a rounded point and a segment with a Radius is a
+// capsule.
+type Shape struct {
+	Kind   ShapeKind
+	Points []Vector
+	Radius float64
+}
+
+func Circle(radius float64) Shape {
+	return Shape{Kind: ShapeCircle, Points: []Vector{{}}, Radius: radius}
+}
+
+// Polygon makes a convex polygon from its vertices in order.
+func Polygon(points ...Vector) Shape {
+	return Shape{Kind: ShapePolygon, Points: points}
+}
+
+func Segment(a, b Vector) Shape {
+	return Shape{Kind: ShapeSegment, Points: []Vector{a, b}}
+}
+
+// BoundingRadius is the radius of the smallest circle around the local
+// origin that contains the shape.
+func (s Shape) BoundingRadius() float64 {
+	var result float64
+	for _, p := range s.Points {
+		result = math.Max(result, p.Length())
+	}
+	return result + s.Radius
+}
+
+// Transform returns the shape with its points mapped by t. t should be a
+// rigid transform, since Radius isn't scaled.
+func (s Shape) Transform(t Transform) Shape {
+	var points = make([]Vector, len(s.Points))
+	for index, p := range s.Points {
+		points[index] = t.Apply(p)
+	}
+	return Shape{Kind: s.Kind, Points: points, Radius: s.Radius}
+}
+
+// Contact describes how two shapes overlap. Normal is the unit direction
+// from the first shape to the second, Depth how far they must separate along
+// it, and Point a point in the overlap.
+type Contact struct {
+	Normal Vector
+	Depth  float64
+	Point  Vector
+}
+
+// Collide tests two shapes given in the same frame.
+func Collide(a, b Shape) (Contact, bool) {
+	var radius = a.Radius + b.Radius
+	if normal, depth, overlap := satOverlap(a.Points, b.Points); overlap {
+		var deepest = support(b.Points, normal.Negate())
+		return Contact{
+			Normal: normal,
+			Depth:  depth + radius,
+			Point:  deepest.Sub(normal.Multiply(b.Radius)),
+		}, true
+	}
+
+	// The cores are apart, so only the rounded parts can touch.
+	var pa, pb = closestPoints(a.Points, b.Points)
+	var delta = pb.Sub(pa)
+	var distance = delta.Length()
+	if distance >= radius {
+		return Contact{}, false
+	}
+	var normal = delta.Multiply(1 / distance)
+	return Contact{
+		Normal: normal,
+		Depth:  radius - distance,
+		Point:  pa.Add(normal.Multiply(a.Radius - (radius-distance)/2)),
+	}, true
+}
+
+// axes returns the directions to test when separating a core from another:
+// edge normals, plus the direction of a segment so that points beyond its
+// ends are separated.
+func axes(points []Vector) []Vector {
+	switch len(points) {
+	case 0, 1:
+		return nil
+	case 2:
+		var direction = points[1].Sub(points[0]).Normalize()
+		return []Vector{direction.Perp(), direction}
+	}
+	var result = make([]Vector, 0, len(points))
+	for index, p := range points {
+		var edge = points[(index+1)%len(points)].Sub(p)
+		if edge.LengthSquared() > 0 {
+			result = append(result, edge.Perp().Normalize())
+		}
+	}
+	return result
+}
+
+func project(points []Vector, axis Vector) (float64, float64) {
+	var lo, hi = math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		var d = p.Dot(axis)
+		lo = math.Min(lo, d)
+		hi = math.Max(hi, d)
+	}
+	return lo, hi
+}
+
+func support(points []Vector, direction Vector) Vector {
+	var best = points[0]
+	for _, p := range points[1:] {
+		if p.Dot(direction) > best.Dot(direction) {
+			best = p
+		}
+	}
+	return best
+}
+
+// satOverlap runs the separating axis test on two convex cores. When they
+// overlap it returns the axis of least penetration, pointing from a to b.
+func satOverlap(a, b []Vector) (Vector, float64, bool) {
+	var candidates = append(axes(a), axes(b)...)
+	if len(candidates) == 0 {
+		return Vector{X: 1}, 0, a[0] == b[0]
+	}
+	var bestAxis Vector
+	var bestDepth = math.Inf(1)
+	for _, axis := range candidates {
+		var aLo, aHi = project(a, axis)
+		var bLo, bHi = project(b, axis)
+		if aHi < bLo || bHi < aLo {
+			return Vector{}, 0, false
+		}
+		// push b whichever way along the axis is shorter
+		if depth := aHi - bLo; depth < bestDepth {
+			bestDepth, bestAxis = depth, axis
+		}
+		if depth := bHi - aLo; depth < bestDepth {
+			bestDepth, bestAxis = depth, axis.Negate()
+		}
+	}
+	return bestAxis, bestDepth, true
+}
+
+// ClosestPointOnSegment returns the point of segment ab nearest to p.
+func ClosestPointOnSegment(p, a, b Vector) Vector {
+	var ab = b.Sub(a)
+	var lengthSquared = ab.LengthSquared()
+	if lengthSquared == 0 {
+		return a
+	}
+	var t = math.Max(0, math.Min(1, p.Sub(a).Dot(ab)/lengthSquared))
+	return a.Add(ab.Multiply(t))
+}
+
+// edges returns the edges of a core as point pairs; a point is a degenerate
+// edge and a segment a single edge.
+func edges(points []Vector) [][2]Vector {
+	switch len(points) {
+	case 1:
+		return [][2]Vector{{points[0], points[0]}}
+	case 2:
+		return [][2]Vector{{points[0], points[1]}}
+	}
+	var result = make([][2]Vector, len(points))
+	for index, p := range points {
+		result[index] = [2]Vector{p, points[(index+1)%len(points)]}
+	}
+	return result
+}
+
+// closestPoints returns the nearest pair of points on two disjoint cores.
+func closestPoints(a, b []Vector) (Vector, Vector) {
+	var bestA, bestB Vector
+	var best = math.Inf(1)
+	var try = func(pa, pb Vector) {
+		if d := pa.DistanceSquared(pb); d < best {
+			best, bestA, bestB = d, pa, pb
+		}
+	}
+	var edgesA, edgesB = edges(a), edges(b)
+	for _, p := range a {
+		for _, edge := range edgesB {
+			try(p, ClosestPointOnSegment(p, edge[0], edge[1]))
+		}
+	}
+	for _, p := range b {
+		for _, edge := range edgesA {
+			try(ClosestPointOnSegment(p, edge[0], edge[1]), p)
+		}
+	}
+	return bestA, bestB
+}
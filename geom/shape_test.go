@@ -0,0 +1,108 @@
+package geom
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func box(half float64) Shape {
+	return Polygon(Vector{X: -half, Y: -half}, Vector{X: half, Y: -half}, Vector{X: half, Y: half}, Vector{X: -half, Y: half})
+}
+
+func TestCollide(t *testing.T) {
+	var tests = []struct {
+		name   string
+		a, b   Shape
+		at     Vector
+		hit    bool
+		normal Vector
+		depth  float64
+	}{
+		{"circles apart", Circle(1), Circle(1), Vector{X: 3}, false, Vector{}, 0},
+		{"circles touching", Circle(1), Circle(1), Vector{X: 2}, false, Vector{}, 0},
+		{"circles", Circle(1), Circle(2), Vector{Y: 2}, true, Vector{Y: 1}, 1},
+		{"same center", Circle(1), Circle(1), Vector{}, true, Vector{X: 1}, 2},
+		{"boxes", box(1), box(1), Vector{X: 1.5, Y: 0.5}, true, Vector{X: 1}, 0.5},
+		{"boxes apart", box(1), box(1), Vector{X: 2.5}, false, Vector{}, 0},
+		{"box and circle", box(1), Circle(1), Vector{Y: -1.5}, true, Vector{Y: -1}, 0.5},
+		{"box and circle at corner", box(1), Circle(1), Vector{X: 1.8, Y: 1.8}, false, Vector{}, 0},
+		{"segment through box", Segment(Vector{X: -3}, Vector{X: 3}), box(1), Vector{Y: 0.8}, true, Vector{Y: 1}, 0.2},
+		{"segment and circle", Segment(Vector{X: -3}, Vector{X: 3}), Circle(1), Vector{X: 2, Y: -0.5}, true, Vector{Y: -1}, 0.5},
+		{"segment end and circle", Segment(Vector{X: -3}, Vector{X: 3}), Circle(1), Vector{X: 3.5}, true, Vector{X: 1}, 0.5},
+	}
+	for _, test := range tests {
+		var b = test.b.Transform(Pose(test.at, 0))
+		contact, hit := Collide(test.a, b)
+		if hit != test.hit {
+			t.Errorf("%s: hit %v, want %v", test.name, hit, test.hit)
+			continue
+		}
+		if hit && (!nearVector(contact.Normal, test.normal, 1e-9) || !near(contact.Depth, test.depth, 1e-9)) {
+			t.Errorf("%s: normal %v depth %v, want %v %v", test.name, contact.Normal, contact.Depth, test.normal, test.depth)
+		}
+	}
+}
+
+func randomShape(r *rand.Rand) Shape {
+	var shape Shape
+	switch r.IntN(4) {
+	case 0:
+		return Circle(0.5 + 2*r.Float64())
+	case 1:
+		shape = box(0.5 + 2*r.Float64())
+	case 2:
+		shape = Segment(randomVector(r, 3), randomVector(r, 3))
+	default:
+		// a capsule
+		shape = Segment(randomVector(r, 3), randomVector(r, 3))
+		shape.Radius = r.Float64()
+	}
+	return shape.Transform(Pose(Vector{}, randomAngle(r)))
+}
+
+// TestCollideSeparates checks that moving the second shape by the contact's
+// depth along its normal is what it takes to part them.
+func TestCollideSeparates(t *testing.T) {
+	var r = newRand()
+	var hits int
+	for trial := 0; trial < trials; trial++ {
+		var a = randomShape(r)
+		var b = randomShape(r).Transform(Pose(randomVector(r, 5), 0))
+		contact, hit := Collide(a, b)
+		if _, reverse := Collide(b, a); reverse != hit {
+			t.Fatalf("Collide(%v, %v) is %v but reversed %v", a, b, hit, reverse)
+		}
+		if !hit {
+			continue
+		}
+		hits++
+		if !near(contact.Normal.Length(), 1, 1e-9) || contact.Depth <= 0 {
+			t.Fatalf("Collide(%v, %v) = %+v", a, b, contact)
+		}
+		var apart = b.Transform(Pose(contact.Normal.Multiply(contact.Depth+1e-6), 0))
+		if _, hit := Collide(a, apart); hit {
+			t.Fatalf("Collide(%v, %v) = %+v, which doesn't separate them", a, b, contact)
+		}
+		if contact.Depth > 1e-3 {
+			var short = b.Transform(Pose(contact.Normal.Multiply(contact.Depth-1e-3), 0))
+			if _, hit := Collide(a, short); !hit {
+				t.Fatalf("Collide(%v, %v) = %+v, deeper than the overlap", a, b, contact)
+			}
+		}
+	}
+	if hits < trials/10 {
+		t.Fatalf("only %d of %d trials collided", hits, trials)
+	}
+}
+
+func BenchmarkCollide(b *testing.B) {
+	var r = newRand()
+	var shapes = make([]Shape, 1024)
+	for index := range shapes {
+		shapes[index] = randomShape(r).Transform(Pose(randomVector(r, 3), 0))
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Collide(shapes[n%len(shapes)], shapes[(n*7+1)%len(shapes)])
+	}
+}
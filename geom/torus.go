@@ -0,0 +1,77 @@
+package geom
+
+import "math"
+
+// Torus is a Width by Height world whose opposite edges are joined, so an
+// object leaving one side comes back on the other.
+type Torus struct {
+	Width  float64
+	Height float64
+}
+
+func wrap(x, size float64) float64 {
+	x = math.Mod(x, size)
+	if x < 0 {
+		x += size
+		if x >= size {
+			// too close below zero to move off size when added
+			x = 0
+		}
+	}
+	return x
+}
+
+// wrapDelta maps d into [-size/2, size/2).
+func wrapDelta(d, size float64) float64 {
+	return wrap(d+size/2, size) - size/2
+}
+
+// Wrap returns the position of v inside [0, Width) x [0, Height).
+func (t Torus) Wrap(v Vector) Vector {
+	return Vector{
+		X: wrap(v.X, t.Width),
+		Y: wrap(v.Y, t.Height),
+	}
+}
+
+// Delta returns the shortest displacement from from to to, which may cross
+// an edge.
+func (t Torus) Delta(from, to Vector) Vector {
+	return Vector{
+		X: wrapDelta(to.X-from.X, t.Width),
+		Y: wrapDelta(to.Y-from.Y, t.Height),
+	}
+}
+
+func (t Torus) Distance(a, b Vector) float64 {
+	return t.Delta(a, b).Length()
+}
+
+func (t Torus) DistanceSquared(a, b Vector) float64 {
+	return t.Delta(a, b).LengthSquared()
+}
+
+// Ghosts returns v and, for an object of the given radius that straddles one
+// or more edges, the positions where the parts sticking out reappear. Drawing
+// the object at every returned position shows it on both sides of an edge.
+func (t Torus) Ghosts(v Vector, radius float64) []Vector {
+	var xs = []float64{v.X}
+	if v.X < radius {
+		xs = append(xs, v.X+t.Width)
+	} else if v.X > t.Width-radius {
+		xs = append(xs, v.X-t.Width)
+	}
+	var ys = []float64{v.Y}
+	if v.Y < radius {
+		ys = append(ys, v.Y+t.Height)
+	} else if v.Y > t.Height-radius {
+		ys = append(ys, v.Y-t.Height)
+	}
+	var result = make([]Vector, 0, len(xs)*len(ys))
+	for _, x := range xs {
+		for _, y := range ys {
+			result = append(result, Vector{X: x, Y: y})
+		}
+	}
+	return result
+}
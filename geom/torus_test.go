@@ -0,0 +1,58 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+var testTorus = Torus{Width: 800, Height: 600}
+
+func TestWrapStaysInside(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var v = randomVector(r, 10000)
+		var wrapped = testTorus.Wrap(v)
+		if wrapped.X < 0 || wrapped.X >= testTorus.Width || wrapped.Y < 0 || wrapped.Y >= testTorus.Height {
+			t.Fatalf("Wrap(%v) = %v is outside the torus", v, wrapped)
+		}
+		if testTorus.Wrap(wrapped) != wrapped {
+			t.Fatalf("Wrap(%v) moved an inside point", wrapped)
+		}
+		if testTorus.Distance(v, wrapped) > 1e-9 {
+			t.Fatalf("Wrap(%v) = %v isn't the same point", v, wrapped)
+		}
+	}
+}
+
+func TestDeltaIsShortest(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var a, b = testTorus.Wrap(randomVector(r, 1000)), testTorus.Wrap(randomVector(r, 1000))
+		var delta = testTorus.Delta(a, b)
+		if math.Abs(delta.X) > testTorus.Width/2 || math.Abs(delta.Y) > testTorus.Height/2 {
+			t.Fatalf("Delta(%v, %v) = %v goes the long way", a, b, delta)
+		}
+		if testTorus.Distance(a.Add(delta), b) > 1e-9 {
+			t.Fatalf("%v + Delta(%v, %v) doesn't reach %v", a, a, b, b)
+		}
+		if !near(testTorus.Distance(a, b), testTorus.Distance(b, a), 1e-9) {
+			t.Fatalf("distance between %v and %v isn't symmetric", a, b)
+		}
+		// going straight can't be shorter than the torus distance
+		if testTorus.Distance(a, b) > a.Distance(b)+1e-9 {
+			t.Fatalf("torus distance from %v to %v is longer than the straight line", a, b)
+		}
+	}
+}
+
+func TestWrapTinyNegative(t *testing.T) {
+	for _, v := range []Vector{
+		{X: -1e-17, Y: -1e-17},
+		{X: -1e-300, Y: -math.SmallestNonzeroFloat64},
+	} {
+		var wrapped = testTorus.Wrap(v)
+		if wrapped.X < 0 || wrapped.X >= testTorus.Width || wrapped.Y < 0 || wrapped.Y >= testTorus.Height {
+			t.Fatalf("Wrap(%v) = %v is outside the torus", v, wrapped)
+		}
+	}
+}
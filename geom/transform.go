@@ -0,0 +1,87 @@
+package geom
+
+import "math"
+
+// Transform is a 2D affine transform. It maps (x, y) to
+//
+//	(A*x + C*y + E, B*x + D*y + F)
+//
+// the same layout as canvas and SVG matrices.
+type Transform struct {
+	A, B, C, D, E, F float64
+}
+
+func Identity() Transform {
+	return Transform{A: 1, D: 1}
+}
+
+func Translation(v Vector) Transform {
+	return Transform{A: 1, D: 1, E: v.X, F: v.Y}
+}
+
+func Rotation(angle Angle) Transform {
+	var sin, cos = math.Sincos(float64(angle))
+	return Transform{A: cos, B: sin, C: -sin, D: cos}
+}
+
+func Scaling(sx, sy float64) Transform {
+	return Transform{A: sx, D: sy}
+}
+
+// Pose returns the transform from an object's local frame to the world for
+// an object at position facing angle: rotate, then translate.
+func Pose(position Vector, angle Angle) Transform {
+	var t = Rotation(angle)
+	t.E = position.X
+	t.F = position.Y
+	return t
+}
+
+// Compose returns the transform that applies o first and then t.
+func (t Transform) Compose(o Transform) Transform {
+	return Transform{
+		A: t.A*o.A + t.C*o.B,
+		B: t.B*o.A + t.D*o.B,
+		C: t.A*o.C + t.C*o.D,
+		D: t.B*o.C + t.D*o.D,
+		E: t.A*o.E + t.C*o.F + t.E,
+		F: t.B*o.E + t.D*o.F + t.F,
+	}
+}
+
+func (t Transform) Determinant() float64 {
+	return t.A*t.D - t.B*t.C
+}
+
+// Invert returns the inverse transform, or false if t is singular.
+func (t Transform) Invert() (Transform, bool) {
+	var det = t.Determinant()
+	if det == 0 {
+		return Transform{}, false
+	}
+	var inv = 1 / det
+	return Transform{
+		A: t.D * inv,
+		B: -t.B * inv,
+		C: -t.C * inv,
+		D: t.A * inv,
+		E: (t.C*t.F - t.D*t.E) * inv,
+		F: (t.B*t.E - t.A*t.F) * inv,
+	}, true
+}
+
+// Apply maps the point v.
+func (t Transform) Apply(v Vector) Vector {
+	return Vector{
+		X: t.A*v.X + t.C*v.Y + t.E,
+		Y: t.B*v.X + t.D*v.Y + t.F,
+	}
+}
+
+// ApplyVector maps the direction v, ignoring translation.
+func (t Transform) ApplyVector(v Vector) Vector {
+	return Vector{
+		X: t.A*v.X + t.C*v.Y,
+		Y: t.B*v.X + t.D*v.Y,
+	}
+}
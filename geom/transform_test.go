@@ -0,0 +1,57 @@
+package geom
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func randomTransform(r *rand.Rand) Transform {
+	return Pose(randomVector(r, 1000), randomAngle(r)).Compose(Scaling(0.1+r.Float64()*4, 0.1+r.Float64()*4))
+}
+
+func TestComposeAppliesRightFirst(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var a, b, v = randomTransform(r), randomTransform(r), randomVector(r, 100)
+		if !nearVector(a.Compose(b).Apply(v), a.Apply(b.Apply(v)), 1e-6) {
+			t.Fatalf("composed transform maps %v elsewhere", v)
+		}
+		if !nearVector(a.Compose(b).ApplyVector(v), a.ApplyVector(b.ApplyVector(v)), 1e-6) {
+			t.Fatalf("composed transform maps direction %v elsewhere", v)
+		}
+		if !near(a.Compose(b).Determinant(), a.Determinant()*b.Determinant(), 1e-6) {
+			t.Fatal("determinants don't multiply")
+		}
+	}
+}
+
+func TestInvertUndoes(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var transform, v = randomTransform(r), randomVector(r, 100)
+		inverse, ok := transform.Invert()
+		if !ok {
+			t.Fatalf("%v isn't invertible", transform)
+		}
+		if !nearVector(inverse.Apply(transform.Apply(v)), v, 1e-6) {
+			t.Fatalf("inverse of %v doesn't map %v back", transform, v)
+		}
+	}
+	if _, ok := Scaling(0, 1).Invert(); ok {
+		t.Fatal("a singular transform was inverted")
+	}
+}
+
+func TestPoseMatchesRotateThenTranslate(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var position, angle, v = randomVector(r, 1000), randomAngle(r), randomVector(r, 100)
+		var pose = Pose(position, angle)
+		if !nearVector(pose.Apply(v), v.Rotate(angle).Add(position), 1e-9) {
+			t.Fatalf("Pose(%v, %v) maps %v to %v", position, angle, v, pose.Apply(v))
+		}
+		if !nearVector(pose.Apply(v), Translation(position).Compose(Rotation(angle)).Apply(v), 1e-9) {
+			t.Fatalf("Pose(%v, %v) isn't a rotation then a translation", position, angle)
+		}
+	}
+}
@@ -4,6 +4,11 @@ import "math"
 
 type Angle float64
 
+// Degrees converts an angle in degrees to an Angle.
+func Degrees(degrees float64) Angle {
+	return Angle(degrees * math.Pi / 180)
+}
+
 func (angle Angle) ToVector() Vector {
 	return Vector{
 		X: math.Cos(float64(angle)),
@@ -11,6 +16,29 @@ func (angle Angle) ToVector() Vector {
 	}
 }
 
+func (angle Angle) Degrees() float64 {
+	return float64(angle) * 180 / math.Pi
+}
+
+// Normalize returns the same direction in the range [-Pi, Pi).
+func (angle Angle) Normalize() Angle {
+	var result = math.Mod(float64(angle)+math.Pi, 2*math.Pi)
+	if result < 0 {
+		result += 2 * math.Pi
+	}
+	return Angle(result - math.Pi)
+}
+
+// Diff returns the signed shortest turn from angle to other, in [-Pi, Pi).
+func (angle Angle) Diff(other Angle) Angle {
+	return (other - angle).Normalize()
+}
+
+// Lerp turns from angle towards other by the fraction t, the short way round.
+func (angle Angle) Lerp(other Angle, t float64) Angle {
+	return (angle + angle.Diff(other)*Angle(t)).Normalize()
+}
+
 type Vector struct {
 	X float64
 	Y float64
@@ -22,9 +50,86 @@ func (v Vector) Add(ov Vector) Vector {
 		Y: v.Y + ov.Y,
 	}
 }
+func (v Vector) Sub(ov Vector) Vector {
+	return Vector{
+		X: v.X - ov.X,
+		Y: v.Y - ov.Y,
+	}
+}
 func (v Vector) Multiply(w float64) Vector {
 	return Vector{
 		X: v.X * w,
 		Y: v.Y * w,
 	}
 }
+func (v Vector) Negate() Vector {
+	return Vector{
+		X: -v.X,
+		Y: -v.Y,
+	}
+}
+func (v Vector) Dot(ov Vector) float64 {
+	return v.X*ov.X + v.Y*ov.Y
+}
+
+// Cross returns the z component of the 3D cross product, positive when ov is
+// clockwise from v on screen (y pointing down).
+func (v Vector) Cross(ov Vector) float64 {
+	return v.X*ov.Y - v.Y*ov.X
+}
+func (v Vector) LengthSquared() float64 {
+	return v.Dot(v)
+}
+func (v Vector) Length() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Normalize returns v scaled to length 1, or the zero vector if v is zero.
+func (v Vector) Normalize() Vector {
+	var length = v.Length()
+	if length == 0 {
+		return Vector{}
+	}
+	return v.Multiply(1 / length)
+}
+
+// Perp returns v rotated a quarter turn in the direction of positive Angle.
+func (v Vector) Perp() Vector {
+	return Vector{
+		X: -v.Y,
+		Y: v.X,
+	}
+}
+func (v Vector) Rotate(angle Angle) Vector {
+	var sin, cos = math.Sincos(float64(angle))
+	return Vector{
+		X: v.X*cos - v.Y*sin,
+		Y: v.X*sin + v.Y*cos,
+	}
+}
+
+// Angle returns the direction of v.
+func (v Vector) Angle() Angle {
+	return Angle(math.Atan2(v.Y, v.X))
+}
+func (v Vector) Lerp(ov Vector, t float64) Vector {
+	return Vector{
+		X: v.X + (ov.X-v.X)*t,
+		Y: v.Y + (ov.Y-v.Y)*t,
+	}
+}
+func (v Vector) Distance(ov Vector) float64 {
+	return ov.Sub(v).Length()
+}
+func (v Vector) DistanceSquared(ov Vector) float64 {
+	return ov.Sub(v).LengthSquared()
+}
+
+// ClampLength returns v shortened to at most max.
+func (v Vector) ClampLength(max float64) Vector {
+	var lengthSquared = v.LengthSquared()
+	if lengthSquared <= max*max {
+		return v
+	}
+	return v.Multiply(max / math.Sqrt(lengthSquared))
+}
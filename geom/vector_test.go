@@ -0,0 +1,106 @@
+package geom
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeRange(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var angle = randomAngle(r)
+		var normal = angle.Normalize()
+		if normal < -math.Pi || normal >= math.Pi {
+			t.Fatalf("%v.Normalize() = %v, outside [-Pi, Pi)", angle, normal)
+		}
+		if !nearVector(angle.ToVector(), normal.ToVector(), 1e-9) {
+			t.Fatalf("%v.Normalize() = %v points another way", angle, normal)
+		}
+	}
+}
+
+func TestDiffTurnsOntoOther(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var a, b = randomAngle(r), randomAngle(r)
+		var diff = a.Diff(b)
+		if math.Abs(float64(diff)) > math.Pi {
+			t.Fatalf("%v.Diff(%v) = %v, longer than half a turn", a, b, diff)
+		}
+		if !nearVector((a + diff).ToVector(), b.ToVector(), 1e-9) {
+			t.Fatalf("%v + %v.Diff(%v) doesn't point at %v", a, a, b, b)
+		}
+	}
+}
+
+func TestRotatePreservesLengthAndTurnsByAngle(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var v, angle = randomVector(r, 1000), randomAngle(r)
+		var rotated = v.Rotate(angle)
+		if !near(rotated.Length(), v.Length(), 1e-9*v.Length()+1e-12) {
+			t.Fatalf("%v.Rotate(%v) = %v changed length", v, angle, rotated)
+		}
+		if !nearVector(rotated.Rotate(-angle), v, 1e-9) {
+			t.Fatalf("%v rotated by %v and back is %v", v, angle, rotated.Rotate(-angle))
+		}
+		if !near(float64((v.Angle() + angle).Diff(rotated.Angle())), 0, 1e-9) {
+			t.Fatalf("%v.Rotate(%v) turned by %v", v, angle, v.Angle().Diff(rotated.Angle()))
+		}
+	}
+}
+
+func TestProducts(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var v, w = randomVector(r, 100), randomVector(r, 100)
+		if !near(v.Dot(w), w.Dot(v), 1e-9) || !near(v.Cross(w), -w.Cross(v), 1e-9) {
+			t.Fatalf("dot or cross of %v and %v isn't (anti)symmetric", v, w)
+		}
+		if !near(v.Perp().Dot(v), 0, 1e-9) || !near(v.Cross(v.Perp()), v.LengthSquared(), 1e-9) {
+			t.Fatalf("%v.Perp() = %v isn't a positive quarter turn", v, v.Perp())
+		}
+		// |v|²|w|² = (v·w)² + (v×w)²
+		if !near(v.LengthSquared()*w.LengthSquared(), v.Dot(w)*v.Dot(w)+v.Cross(w)*v.Cross(w), 1e-6) {
+			t.Fatalf("Lagrange's identity fails for %v and %v", v, w)
+		}
+	}
+}
+
+func TestNormalizeAndClampLength(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var v = randomVector(r, 1000)
+		if !near(v.Normalize().Length(), 1, 1e-9) {
+			t.Fatalf("%v.Normalize() has length %v", v, v.Normalize().Length())
+		}
+		var limit = r.Float64() * 1000
+		var clamped = v.ClampLength(limit)
+		if clamped.Length() > limit+1e-9 {
+			t.Fatalf("%v.ClampLength(%v) = %v is too long", v, limit, clamped)
+		}
+		if v.Length() <= limit && clamped != v {
+			t.Fatalf("%v.ClampLength(%v) changed a short vector", v, limit)
+		}
+		if clamped.Length() > 1e-9 && !near(clamped.Angle().Diff(v.Angle()).Degrees(), 0, 1e-6) {
+			t.Fatalf("%v.ClampLength(%v) = %v turned it", v, limit, clamped)
+		}
+	}
+	if (Vector{}).Normalize() != (Vector{}) {
+		t.Fatal("the zero vector doesn't normalize to itself")
+	}
+}
+
+func TestLerpEnds(t *testing.T) {
+	var r = newRand()
+	for trial := 0; trial < trials; trial++ {
+		var v, w = randomVector(r, 1000), randomVector(r, 1000)
+		if !nearVector(v.Lerp(w, 0), v, 1e-9) || !nearVector(v.Lerp(w, 1), w, 1e-9) {
+			t.Fatalf("%v.Lerp(%v) doesn't start and end at them", v, w)
+		}
+		var a, b = randomAngle(r), randomAngle(r)
+		if !nearVector(a.Lerp(b, 0).ToVector(), a.ToVector(), 1e-9) || !nearVector(a.Lerp(b, 1).ToVector(), b.ToVector(), 1e-9) {
+			t.Fatalf("%v.Lerp(%v) doesn't start and end at them", a, b)
+		}
+	}
+}
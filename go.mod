@@ -2,7 +2,10 @@ module github.com/StCredZero/vectrek
 
 go 1.23.5
 
-require github.com/hajimehoshi/ebiten/v2 v2.8.6
+require (
+	github.com/coder/websocket v1.8.15
+	github.com/hajimehoshi/ebiten/v2 v2.8.6
+)
 
 require (
 	github.com/ebitengine/gomobile v0.0.0-20240911145611-4856209ac325 // indirect
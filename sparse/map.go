@@ -3,40 +3,72 @@ package sparse
 import (
 	"errors"
 	"fmt"
+	"sort"
 )
 
 var ErrMissing = errors.New("missing")
 
 type Key uint64
 
+const pageSize = 64
+
 // Map is a structure to hold entities with a specific component type.
 type Map[T any] struct {
 	// sparse maps entity IDs to their index in the dense list
 	sparse map[Key]int
-	// dense holds the actual components or entity IDs
-	dense   []T
+	// dense holds the actual components or entity IDs. It is stored in
+	// fixed-size pages that are never reallocated, so pointers returned by
+	// Get stay valid as the Map grows.
+	dense   denseList[T]
 	deleted map[int]struct{}
 }
 
+type denseList[T any] struct {
+	pages  [][]T
+	length int
+}
+
+func (d *denseList[T]) at(index int) *T {
+	return &d.pages[index/pageSize][index%pageSize]
+}
+
+func (d *denseList[T]) append(value T) {
+	if d.length == len(d.pages)*pageSize {
+		d.pages = append(d.pages, make([]T, pageSize))
+	}
+	*d.at(d.length) = value
+	d.length++
+}
+
 func NewMap[T any]() *Map[T] {
 	return &Map[T]{
 		sparse:  make(map[Key]int),
-		dense:   make([]T, 0, 16),
 		deleted: make(map[int]struct{}),
 	}
 }
 
+// Add stores value under key. When a slot has been freed by Delete, the
+// lowest free slot is reused so that the dense order, and therefore the
+// order of Iterate, depends only on the sequence of Adds and Deletes.
 func (s *Map[T]) Add(key Key, value T) {
-	for index := range s.deleted {
+	if denseIndex, found := s.sparse[key]; found {
+		*s.dense.at(denseIndex) = value
+		return
+	}
+	if len(s.deleted) > 0 {
+		index := -1
+		for each := range s.deleted {
+			if index < 0 || each < index {
+				index = each
+			}
+		}
 		s.sparse[key] = index
 		delete(s.deleted, index)
-		s.dense[index] = value
+		*s.dense.at(index) = value
 		return
 	}
-	denseIndex := len(s.dense)
-	s.sparse[key] = denseIndex
-	s.dense = append(s.dense, value)
-	delete(s.deleted, denseIndex)
+	s.sparse[key] = s.dense.length
+	s.dense.append(value)
 }
 
 func (s *Map[T]) Delete(key Key) {
@@ -44,28 +76,48 @@ func (s *Map[T]) Delete(key Key) {
 	if !found {
 		return
 	}
+	var zero T
+	*s.dense.at(denseIndex) = zero
 	s.deleted[denseIndex] = struct{}{}
+	delete(s.sparse, key)
 }
 
 func (s *Map[T]) Iterate(fn func(value T) (T, error)) []error {
-	errs := make([]error, 0, len(s.dense))
-	for i := range s.dense {
+	errs := make([]error, 0, s.dense.length)
+	for i := 0; i < s.dense.length; i++ {
 		if _, deleted := s.deleted[i]; !deleted {
-			updated, err := fn(s.dense[i])
-			s.dense[i] = updated
+			value := s.dense.at(i)
+			updated, err := fn(*value)
+			*value = updated
 			errs = append(errs, err)
 		}
 	}
 	return errs
 }
 
+// Keys returns the live keys in ascending order.
+func (s *Map[T]) Keys() []Key {
+	keys := make([]Key, 0, len(s.sparse))
+	for key := range s.sparse {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (s *Map[T]) Len() int {
+	return len(s.sparse)
+}
+
 func (s *Map[T]) Get(key Key) (*T, bool) {
 	var result T
 	denseIndex, ok := s.sparse[key]
 	if !ok {
 		return &result, false
 	}
-	return &s.dense[denseIndex], true
+	return s.dense.at(denseIndex), true
 }
 
 func (s *Map[T]) GetErr(key Key) (*T, error) {
@@ -83,3 +135,74 @@ func (s *Map[T]) MustGet(key Key) *T {
 	}
 	return result
 }
+
+// Cloner is implemented by values holding slices that are changed in place.
+// Snapshot and Restore copy them with Clone, so a snapshot and the Map never
+// share them.
+type Cloner[T any] interface {
+	Clone() T
+}
+
+// cloneFunc returns how to copy a T: with Clone if it is a Cloner, or as is.
+func cloneFunc[T any]() func(T) T {
+	var zero T
+	if _, ok := any(zero).(Cloner[T]); !ok {
+		return func(value T) T {
+			return value
+		}
+	}
+	return func(value T) T {
+		return any(value).(Cloner[T]).Clone()
+	}
+}
+
+// Snapshot is a copy of the contents of a Map, taken by Map.Snapshot.
+type Snapshot[T any] struct {
+	sparse  map[Key]int
+	values  []T
+	deleted map[int]struct{}
+}
+
+// Snapshot copies the contents of the Map into dst, reusing dst's storage.
+func (s *Map[T]) Snapshot(dst *Snapshot[T]) {
+	if dst.sparse == nil {
+		dst.sparse = make(map[Key]int, len(s.sparse))
+		dst.deleted = make(map[int]struct{}, len(s.deleted))
+	}
+	clear(dst.sparse)
+	for key, index := range s.sparse {
+		dst.sparse[key] = index
+	}
+	clear(dst.deleted)
+	for index := range s.deleted {
+		dst.deleted[index] = struct{}{}
+	}
+	var clone = cloneFunc[T]()
+	dst.values = dst.values[:0]
+	for index := 0; index < s.dense.length; index++ {
+		dst.values = append(dst.values, clone(*s.dense.at(index)))
+	}
+}
+
+// Restore puts the Map back to the contents saved in src. Values are copied
+// into the existing pages, so pointers into the Map remain valid and point
+// at whatever the same slot held when the snapshot was taken.
+func (s *Map[T]) Restore(src *Snapshot[T]) {
+	clear(s.sparse)
+	for key, index := range src.sparse {
+		s.sparse[key] = index
+	}
+	clear(s.deleted)
+	for index := range src.deleted {
+		s.deleted[index] = struct{}{}
+	}
+	var zero T
+	for index := len(src.values); index < s.dense.length; index++ {
+		*s.dense.at(index) = zero
+	}
+	var clone = cloneFunc[T]()
+	s.dense.length = 0
+	for _, value := range src.values {
+		s.dense.append(clone(value))
+	}
+}
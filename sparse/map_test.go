@@ -0,0 +1,139 @@
+package sparse
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// values returns the Map's values in the order Iterate visits them.
+func values[T any](m *Map[T]) []T {
+	var result []T
+	m.Iterate(func(value T) (T, error) {
+		result = append(result, value)
+		return value, nil
+	})
+	return result
+}
+
+func TestAddReusesLowestFreeSlot(t *testing.T) {
+	// the same Adds and Deletes must give the same order, every time
+	for run := 0; run < 20; run++ {
+		var m = NewMap[int]()
+		for key := Key(0); key < 10; key++ {
+			m.Add(key, int(key))
+		}
+		for _, key := range []Key{7, 2, 5} {
+			m.Delete(key)
+		}
+		m.Add(20, 20)
+		m.Add(21, 21)
+		if got, want := values(m), []int{0, 1, 20, 3, 4, 21, 6, 8, 9}; !slices.Equal(got, want) {
+			t.Fatalf("iterated %v, want %v", got, want)
+		}
+	}
+}
+
+func TestKeysSorted(t *testing.T) {
+	var m = NewMap[string]()
+	for _, key := range []Key{9, 3, 1 << 40, 0, 5} {
+		m.Add(key, "")
+	}
+	m.Delete(5)
+	if got, want := m.Keys(), []Key{0, 3, 9, 1 << 40}; !slices.Equal(got, want) {
+		t.Fatalf("keys %v, want %v", got, want)
+	}
+	if m.Len() != 4 {
+		t.Fatalf("Len %d, want 4", m.Len())
+	}
+}
+
+func TestGet(t *testing.T) {
+	var m = NewMap[int]()
+	m.Add(1, 10)
+	m.Add(1, 11)
+	if value, ok := m.Get(1); !ok || *value != 11 {
+		t.Fatalf("Get(1) = %d, %v", *value, ok)
+	}
+	if _, err := m.GetErr(2); !errors.Is(err, ErrMissing) {
+		t.Fatalf("GetErr(2) = %v", err)
+	}
+	if m.MustGet(2) != nil {
+		t.Fatal("MustGet(2) isn't nil")
+	}
+}
+
+func TestPointersSurviveGrowth(t *testing.T) {
+	var m = NewMap[int]()
+	m.Add(0, 42)
+	var first = m.MustGet(0)
+	for key := Key(1); key < 10*pageSize; key++ {
+		m.Add(key, int(key))
+	}
+	if first != m.MustGet(0) || *first != 42 {
+		t.Fatal("pointer moved as the Map grew")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	var m = NewMap[int]()
+	for key := Key(0); key < 5; key++ {
+		m.Add(key, int(key))
+	}
+	m.Delete(1)
+	var pointer = m.MustGet(3)
+	var snapshot Snapshot[int]
+	m.Snapshot(&snapshot)
+	var before = values(m)
+
+	*pointer = 30
+	m.Delete(0)
+	m.Add(1, 100)
+	for key := Key(10); key < 2*pageSize; key++ {
+		m.Add(key, int(key))
+	}
+	m.Restore(&snapshot)
+
+	if got := values(m); !slices.Equal(got, before) {
+		t.Fatalf("restored %v, want %v", got, before)
+	}
+	if got, want := m.Keys(), []Key{0, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Fatalf("restored keys %v, want %v", got, want)
+	}
+	if pointer != m.MustGet(3) || *pointer != 3 {
+		t.Fatal("pointer doesn't see the restored value")
+	}
+	// the free slot is reused after a restore as it would have been before
+	m.Add(7, 7)
+	if got, want := values(m), []int{0, 7, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Fatalf("iterated %v, want %v", got, want)
+	}
+}
+
+type cloned struct {
+	items []int
+}
+
+func (c cloned) Clone() cloned {
+	return cloned{items: slices.Clone(c.items)}
+}
+
+func TestSnapshotClones(t *testing.T) {
+	var m = NewMap[cloned]()
+	m.Add(1, cloned{items: []int{1, 2}})
+	var snapshot Snapshot[cloned]
+	m.Snapshot(&snapshot)
+
+	m.MustGet(1).items[0] = 100
+	m.Restore(&snapshot)
+	if got := m.MustGet(1).items; got[0] != 1 {
+		t.Fatalf("snapshot shares its slice with the Map: %v", got)
+	}
+	// a restore mustn't share with the snapshot either, so it can be restored
+	// again
+	m.MustGet(1).items[0] = 200
+	m.Restore(&snapshot)
+	if got := m.MustGet(1).items; got[0] != 1 {
+		t.Fatalf("restore shares its slice with the snapshot: %v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package spatial
+
+import (
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+)
+
+// Item is a circle stored in a Grid.
+type Item struct {
+	ID       uint64
+	Position geom.Vector
+	Radius   float64
+}
+
+// Grid is a uniform grid over a toroidal world. Each item is listed in every
+// cell its bounding circle touches, with cell coordinates wrapping at the
+// world's edges, so items near opposite edges share cells.
+type Grid struct {
+	World geom.Torus
+
+	cols, rows int
+	cellW      float64
+	cellH      float64
+	cells      [][]int
+	items      []Item
+	seen       map[[2]int]struct{}
+}
+
+// NewGrid makes a grid with cells of about cellSize. The cell size is
+// adjusted so a whole number of cells spans the world.
+func NewGrid(world geom.Torus, cellSize float64) *Grid {
+	var cols = max(1, int(world.Width/cellSize))
+	var rows = max(1, int(world.Height/cellSize))
+	return &Grid{
+		World: world,
+		cols:  cols,
+		rows:  rows,
+		cellW: world.Width / float64(cols),
+		cellH: world.Height / float64(rows),
+		cells: make([][]int, cols*rows),
+		seen:  make(map[[2]int]struct{}),
+	}
+}
+
+// Reset removes every item, keeping the allocated cells.
+func (g *Grid) Reset() {
+	for index := range g.cells {
+		g.cells[index] = g.cells[index][:0]
+	}
+	g.items = g.items[:0]
+}
+
+func (g *Grid) Len() int {
+	return len(g.items)
+}
+
+// cellRange calls fn with every cell index within radius of p.
+func (g *Grid) cellRange(p geom.Vector, radius float64, fn func(cell int)) {
+	var x0 = int(math.Floor((p.X - radius) / g.cellW))
+	var x1 = int(math.Floor((p.X + radius) / g.cellW))
+	var y0 = int(math.Floor((p.Y - radius) / g.cellH))
+	var y1 = int(math.Floor((p.Y + radius) / g.cellH))
+	// an item can't cover a cell twice by wrapping all the way round
+	x1 = min(x1, x0+g.cols-1)
+	y1 = min(y1, y0+g.rows-1)
+	for y := y0; y <= y1; y++ {
+		var row = ((y%g.rows + g.rows) % g.rows) * g.cols
+		for x := x0; x <= x1; x++ {
+			fn(row + (x%g.cols+g.cols)%g.cols)
+		}
+	}
+}
+
+func (g *Grid) Insert(item Item) {
+	var index = len(g.items)
+	item.Position = g.World.Wrap(item.Position)
+	g.items = append(g.items, item)
+	g.cellRange(item.Position, item.Radius, func(cell int) {
+		g.cells[cell] = append(g.cells[cell], index)
+	})
+}
+
+// Pairs calls fn once for every pair of items whose circles overlap,
+// measuring distance across the world's edges. Pairs are reported in an
+// order that depends only on the order of insertion.
+func (g *Grid) Pairs(fn func(a, b Item)) {
+	clear(g.seen)
+	for _, cell := range g.cells {
+		for i := 0; i < len(cell); i++ {
+			for j := i + 1; j < len(cell); j++ {
+				var key = [2]int{min(cell[i], cell[j]), max(cell[i], cell[j])}
+				if _, ok := g.seen[key]; ok {
+					continue
+				}
+				g.seen[key] = struct{}{}
+				var a, b = g.items[key[0]], g.items[key[1]]
+				var reach = a.Radius + b.Radius
+				if g.World.DistanceSquared(a.Position, b.Position) < reach*reach {
+					fn(a, b)
+				}
+			}
+		}
+	}
+}
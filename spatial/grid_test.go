@@ -0,0 +1,110 @@
+package spatial
+
+import (
+	"fmt"
+	"github.com/StCredZero/vectrek/geom"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+var testWorld = geom.Torus{Width: 2000, Height: 1500}
+
+// randomItems scatters count circles over world, some of them big enough to
+// cover several cells and some over its edges.
+func randomItems(world geom.Torus, count int, seed uint64) []Item {
+	var r = rand.New(rand.NewPCG(seed, 1))
+	var items = make([]Item, count)
+	for index := range items {
+		var radius = 4 + 12*r.Float64()
+		if index%50 == 0 {
+			radius = 120
+		}
+		items[index] = Item{
+			ID:       uint64(index),
+			Position: geom.Vector{X: (1.1*r.Float64() - 0.05) * world.Width, Y: (1.1*r.Float64() - 0.05) * world.Height},
+			Radius:   radius,
+		}
+	}
+	return items
+}
+
+func bruteForcePairs(world geom.Torus, items []Item) [][2]uint64 {
+	var result [][2]uint64
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			var reach = items[i].Radius + items[j].Radius
+			if world.DistanceSquared(world.Wrap(items[i].Position), world.Wrap(items[j].Position)) < reach*reach {
+				result = append(result, [2]uint64{items[i].ID, items[j].ID})
+			}
+		}
+	}
+	return result
+}
+
+func gridPairs(grid *Grid, items []Item) [][2]uint64 {
+	grid.Reset()
+	for _, item := range items {
+		grid.Insert(item)
+	}
+	var result [][2]uint64
+	grid.Pairs(func(a, b Item) {
+		result = append(result, [2]uint64{min(a.ID, b.ID), max(a.ID, b.ID)})
+	})
+	return result
+}
+
+func comparePairs(a, b [2]uint64) int {
+	if a[0] != b[0] {
+		return int(a[0]) - int(b[0])
+	}
+	return int(a[1]) - int(b[1])
+}
+
+func TestGridPairsMatchBruteForce(t *testing.T) {
+	for _, cellSize := range []float64{7, 32, 500, 5000} {
+		var items = randomItems(testWorld, 1000, 1)
+		var grid = NewGrid(testWorld, cellSize)
+		var got = gridPairs(grid, items)
+		var want = bruteForcePairs(testWorld, items)
+		slices.SortFunc(got, comparePairs)
+		if !slices.Equal(got, want) {
+			t.Fatalf("cell size %v: %d pairs, want %d", cellSize, len(got), len(want))
+		}
+		// reusing the grid finds the same pairs, in the same order
+		var first = gridPairs(grid, items)
+		if again := gridPairs(grid, items); !slices.Equal(first, again) {
+			t.Fatalf("cell size %v: pairs differ on reuse", cellSize)
+		}
+	}
+}
+
+func TestGridPairsAcrossEdges(t *testing.T) {
+	var grid = NewGrid(testWorld, 32)
+	var got = gridPairs(grid, []Item{
+		{ID: 1, Position: geom.Vector{X: 2, Y: 2}, Radius: 5},
+		{ID: 2, Position: geom.Vector{X: testWorld.Width - 2, Y: testWorld.Height - 2}, Radius: 5},
+		{ID: 3, Position: geom.Vector{X: -20, Y: 750}, Radius: 5},
+		{ID: 4, Position: geom.Vector{X: testWorld.Width - 25, Y: 750}, Radius: 5},
+	})
+	if want := [][2]uint64{{1, 2}, {3, 4}}; !slices.Equal(got, want) {
+		t.Fatalf("pairs %v, want %v", got, want)
+	}
+}
+
+func BenchmarkGridPairs(b *testing.B) {
+	for _, count := range []int{1000, 5000} {
+		var world = geom.Torus{Width: 8000, Height: 8000}
+		var items = randomItems(world, count, 2)
+		var grid = NewGrid(world, 32)
+		b.Run(fmt.Sprint(count), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				grid.Reset()
+				for _, item := range items {
+					grid.Insert(item)
+				}
+				grid.Pairs(func(a, b Item) {})
+			}
+		})
+	}
+}
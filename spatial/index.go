@@ -0,0 +1,280 @@
+package spatial
+
+import (
+	"cmp"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"slices"
+)
+
+// Rect is an axis-aligned rectangle. Min may lie past the world's edges, and
+// the rectangle then wraps round to the far side.
+type Rect struct {
+	Min geom.Vector
+	Max geom.Vector
+}
+
+// RayHit is an entry whose circle a ray passes through, Distance along the
+// ray from its origin.
+type RayHit[K ~uint64] struct {
+	ID       K
+	Distance float64
+	Point    geom.Vector
+}
+
+type indexEntry[K ~uint64] struct {
+	id       K
+	position geom.Vector
+	radius   float64
+	// cells is the range of cells the entry is listed in, x0, y0, x1, y1,
+	// before wrapping
+	cells [4]int
+	stamp uint64
+}
+
+// Index is a spatial hash of circles kept up to date incrementally: Update
+// moves an entry between cells only when it crosses into new ones. Queries
+// measure distance across the world's edges and return entries nearest first,
+// ties broken by ID, so results don't depend on the order of updates.
+type Index[K ~uint64] struct {
+	World geom.Torus
+
+	cols, rows int
+	cellW      float64
+	cellH      float64
+	cells      [][]K
+	entries    map[K]*indexEntry[K]
+	maxRadius  float64
+	stamp      uint64
+	seen       map[K]struct{}
+}
+
+// NewIndex makes an index with cells of about cellSize. The cell size is
+// adjusted so a whole number of cells spans the world.
+func NewIndex[K ~uint64](world geom.Torus, cellSize float64) *Index[K] {
+	var cols = max(1, int(world.Width/cellSize))
+	var rows = max(1, int(world.Height/cellSize))
+	return &Index[K]{
+		World:   world,
+		cols:    cols,
+		rows:    rows,
+		cellW:   world.Width / float64(cols),
+		cellH:   world.Height / float64(rows),
+		cells:   make([][]K, cols*rows),
+		entries: make(map[K]*indexEntry[K]),
+		seen:    make(map[K]struct{}),
+	}
+}
+
+func (index *Index[K]) Len() int {
+	return len(index.entries)
+}
+
+// Get returns the position and radius id was last updated with.
+func (index *Index[K]) Get(id K) (geom.Vector, float64, bool) {
+	entry, ok := index.entries[id]
+	if !ok {
+		return geom.Vector{}, 0, false
+	}
+	return entry.position, entry.radius, true
+}
+
+func (index *Index[K]) cellBounds(p geom.Vector, radius float64) [4]int {
+	var x0 = int(math.Floor((p.X - radius) / index.cellW))
+	var y0 = int(math.Floor((p.Y - radius) / index.cellH))
+	var x1 = int(math.Floor((p.X + radius) / index.cellW))
+	var y1 = int(math.Floor((p.Y + radius) / index.cellH))
+	// a circle can't cover a cell twice by wrapping all the way round
+	return [4]int{x0, y0, min(x1, x0+index.cols-1), min(y1, y0+index.rows-1)}
+}
+
+func (index *Index[K]) eachCell(bounds [4]int, fn func(cell int)) {
+	for y := bounds[1]; y <= bounds[3]; y++ {
+		var row = ((y%index.rows + index.rows) % index.rows) * index.cols
+		for x := bounds[0]; x <= bounds[2]; x++ {
+			fn(row + (x%index.cols+index.cols)%index.cols)
+		}
+	}
+}
+
+// Update adds id as a circle at position, or moves it there.
+func (index *Index[K]) Update(id K, position geom.Vector, radius float64) {
+	position = index.World.Wrap(position)
+	var bounds = index.cellBounds(position, radius)
+	index.maxRadius = max(index.maxRadius, radius)
+	entry, ok := index.entries[id]
+	if !ok {
+		entry = &indexEntry[K]{id: id}
+		index.entries[id] = entry
+	} else if entry.cells != bounds {
+		index.unlist(entry)
+	}
+	if !ok || entry.cells != bounds {
+		entry.cells = bounds
+		index.eachCell(bounds, func(cell int) {
+			index.cells[cell] = append(index.cells[cell], id)
+		})
+	}
+	entry.position = position
+	entry.radius = radius
+	entry.stamp = index.stamp
+}
+
+func (index *Index[K]) unlist(entry *indexEntry[K]) {
+	index.eachCell(entry.cells, func(cell int) {
+		var ids = index.cells[cell]
+		if at := slices.Index(ids, entry.id); at >= 0 {
+			ids[at] = ids[len(ids)-1]
+			index.cells[cell] = ids[:len(ids)-1]
+		}
+	})
+}
+
+func (index *Index[K]) Remove(id K) {
+	if entry, ok := index.entries[id]; ok {
+		index.unlist(entry)
+		delete(index.entries, id)
+	}
+}
+
+// RemoveStale removes every entry that hasn't been updated since the last
+// call, for callers that update everything that still exists once a tick.
+func (index *Index[K]) RemoveStale() {
+	for id, entry := range index.entries {
+		if entry.stamp != index.stamp {
+			index.Remove(id)
+		}
+	}
+	index.stamp++
+}
+
+// candidates calls fn once for every entry listed in a cell within radius of
+// p.
+func (index *Index[K]) candidates(p geom.Vector, radius float64, fn func(entry *indexEntry[K])) {
+	clear(index.seen)
+	index.eachCell(index.cellBounds(index.World.Wrap(p), radius), func(cell int) {
+		for _, id := range index.cells[cell] {
+			if _, ok := index.seen[id]; !ok {
+				index.seen[id] = struct{}{}
+				fn(index.entries[id])
+			}
+		}
+	})
+}
+
+type byDistance[K ~uint64] struct {
+	id       K
+	distance float64
+}
+
+func sortedIDs[K ~uint64](found []byDistance[K]) []K {
+	slices.SortFunc(found, func(a, b byDistance[K]) int {
+		if c := cmp.Compare(a.distance, b.distance); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	var result = make([]K, len(found))
+	for n, each := range found {
+		result[n] = each.id
+	}
+	return result
+}
+
+// WithinRadius returns the entries whose circles come within radius of
+// center.
+func (index *Index[K]) WithinRadius(center geom.Vector, radius float64) []K {
+	var found []byDistance[K]
+	index.candidates(center, radius+index.maxRadius, func(entry *indexEntry[K]) {
+		var distance = index.World.Distance(center, entry.position)
+		if distance-entry.radius <= radius {
+			found = append(found, byDistance[K]{entry.id, distance})
+		}
+	})
+	return sortedIDs(found)
+}
+
+// WithinRect returns the entries whose centers lie in rect, nearest its
+// center first.
+func (index *Index[K]) WithinRect(rect Rect) []K {
+	var size = rect.Max.Sub(rect.Min)
+	var center = rect.Min.Add(size.Multiply(0.5))
+	var found []byDistance[K]
+	index.candidates(center, size.Length()/2, func(entry *indexEntry[K]) {
+		var offset = entry.position.Sub(rect.Min)
+		var dx = math.Mod(math.Mod(offset.X, index.World.Width)+index.World.Width, index.World.Width)
+		var dy = math.Mod(math.Mod(offset.Y, index.World.Height)+index.World.Height, index.World.Height)
+		if dx <= size.X && dy <= size.Y {
+			found = append(found, byDistance[K]{entry.id, index.World.Distance(center, entry.position)})
+		}
+	})
+	return sortedIDs(found)
+}
+
+// Nearest returns the k entries with centers nearest to center, searching
+// outwards ring by ring.
+func (index *Index[K]) Nearest(center geom.Vector, k int) []K {
+	if k <= 0 || len(index.entries) == 0 {
+		return nil
+	}
+	var limit = math.Hypot(index.World.Width, index.World.Height) / 2
+	for radius := max(index.cellW, index.cellH); ; radius *= 2 {
+		var found []byDistance[K]
+		index.candidates(center, radius, func(entry *indexEntry[K]) {
+			if distance := index.World.Distance(center, entry.position); distance <= radius {
+				found = append(found, byDistance[K]{entry.id, distance})
+			}
+		})
+		if len(found) >= k || radius >= limit {
+			var result = sortedIDs(found)
+			return result[:min(k, len(result))]
+		}
+	}
+}
+
+// Ray returns the entries whose circles the ray from origin along direction
+// passes through within length, in order along the ray. A ray longer than
+// the world can hit the same entry only once.
+func (index *Index[K]) Ray(origin geom.Vector, direction geom.Vector, length float64) []RayHit[K] {
+	direction = direction.Normalize()
+	var step = math.Min(index.cellW, index.cellH)
+	var hits = make(map[K]RayHit[K])
+	for t := 0.0; t < length+step; t += step {
+		// test each candidate against its copy nearest this stretch of the
+		// ray, which is the one the ray may pass through here
+		var sample = origin.Add(direction.Multiply(t))
+		index.candidates(sample, step+index.maxRadius, func(entry *indexEntry[K]) {
+			var image = sample.Add(index.World.Delta(sample, entry.position))
+			var relative = image.Sub(origin)
+			var along = relative.Dot(direction)
+			var off = relative.Cross(direction)
+			if math.Abs(off) > entry.radius {
+				return
+			}
+			var half = math.Sqrt(entry.radius*entry.radius - off*off)
+			// a circle entirely behind the origin isn't hit
+			if along+half < 0 || along-half > length {
+				return
+			}
+			var distance = math.Max(0, along-half)
+			if previous, ok := hits[entry.id]; !ok || distance < previous.Distance {
+				hits[entry.id] = RayHit[K]{
+					ID:       entry.id,
+					Distance: distance,
+					Point:    index.World.Wrap(origin.Add(direction.Multiply(distance))),
+				}
+			}
+		})
+	}
+	var result = make([]RayHit[K], 0, len(hits))
+	for _, hit := range hits {
+		result = append(result, hit)
+	}
+	slices.SortFunc(result, func(a, b RayHit[K]) int {
+		if c := cmp.Compare(a.Distance, b.Distance); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return result
+}
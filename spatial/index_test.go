@@ -0,0 +1,137 @@
+package spatial
+
+import (
+	"cmp"
+	"github.com/StCredZero/vectrek/geom"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+type testID uint64
+
+// movedIndex builds an index of items, then moves every one of them and
+// removes every tenth, so queries see the result of incremental updates.
+func movedIndex(items []Item) (*Index[testID], map[testID]Item) {
+	var index = NewIndex[testID](testWorld, 64)
+	for _, item := range items {
+		index.Update(testID(item.ID), item.Position, item.Radius)
+	}
+	var live = make(map[testID]Item)
+	var moved = randomItems(testWorld, len(items), 9)
+	for n, item := range moved {
+		if n%10 == 0 {
+			index.Remove(testID(item.ID))
+			continue
+		}
+		index.Update(testID(item.ID), item.Position, item.Radius)
+		item.Position = testWorld.Wrap(item.Position)
+		live[testID(item.ID)] = item
+	}
+	return index, live
+}
+
+// bruteForce returns the live items keep accepts, nearest center first.
+func bruteForce(live map[testID]Item, center geom.Vector, keep func(item Item, distance float64) bool) []testID {
+	var found []byDistance[testID]
+	for id, item := range live {
+		var distance = testWorld.Distance(center, item.Position)
+		if keep(item, distance) {
+			found = append(found, byDistance[testID]{id, distance})
+		}
+	}
+	return sortedIDs(found)
+}
+
+func TestIndexQueriesMatchBruteForce(t *testing.T) {
+	var index, live = movedIndex(randomItems(testWorld, 2000, 3))
+	if index.Len() != len(live) {
+		t.Fatalf("index holds %d entries, want %d", index.Len(), len(live))
+	}
+	var r = rand.New(rand.NewPCG(4, 1))
+	for trial := 0; trial < 100; trial++ {
+		var center = geom.Vector{X: r.Float64() * testWorld.Width, Y: r.Float64() * testWorld.Height}
+		var radius = 10 + 200*r.Float64()
+
+		var want = bruteForce(live, center, func(item Item, distance float64) bool {
+			return distance-item.Radius <= radius
+		})
+		if got := index.WithinRadius(center, radius); !slices.Equal(got, want) {
+			t.Fatalf("WithinRadius(%v, %v) = %v, want %v", center, radius, got, want)
+		}
+
+		var rect = Rect{Min: center, Max: center.Add(geom.Vector{X: radius, Y: radius / 2})}
+		var rectCenter = center.Add(geom.Vector{X: radius / 2, Y: radius / 4})
+		want = bruteForce(live, rectCenter, func(item Item, _ float64) bool {
+			var offset = testWorld.Wrap(item.Position.Sub(rect.Min))
+			return offset.X <= radius && offset.Y <= radius/2
+		})
+		if got := index.WithinRect(rect); !slices.Equal(got, want) {
+			t.Fatalf("WithinRect(%v) = %v, want %v", rect, got, want)
+		}
+
+		var k = 1 + r.IntN(20)
+		want = bruteForce(live, center, func(Item, float64) bool { return true })[:k]
+		if got := index.Nearest(center, k); !slices.Equal(got, want) {
+			t.Fatalf("Nearest(%v, %d) = %v, want %v", center, k, got, want)
+		}
+	}
+}
+
+func TestIndexRemoveStale(t *testing.T) {
+	var index = NewIndex[testID](testWorld, 64)
+	index.Update(1, geom.Vector{X: 10, Y: 10}, 5)
+	index.Update(2, geom.Vector{X: 20, Y: 10}, 5)
+	index.RemoveStale()
+	index.Update(2, geom.Vector{X: 30, Y: 10}, 5)
+	index.RemoveStale()
+	if _, _, ok := index.Get(1); ok {
+		t.Error("entry not updated since the last call wasn't removed")
+	}
+	if position, _, ok := index.Get(2); !ok || position.X != 30 {
+		t.Errorf("updated entry is at %v, %v", position, ok)
+	}
+	if got := index.WithinRadius(geom.Vector{X: 10, Y: 10}, 1); len(got) != 0 {
+		t.Errorf("WithinRadius found %v where the stale entry was", got)
+	}
+}
+
+func TestIndexRay(t *testing.T) {
+	var index = NewIndex[testID](testWorld, 64)
+	index.Update(1, geom.Vector{X: 300, Y: 100}, 10)
+	index.Update(2, geom.Vector{X: 200, Y: 104}, 10)
+	index.Update(3, geom.Vector{X: 200, Y: 130}, 10)
+	// across the world's left edge from the origin
+	index.Update(4, geom.Vector{X: 1950, Y: 100}, 10)
+	var tests = []struct {
+		name      string
+		direction geom.Vector
+		length    float64
+		want      []testID
+	}{
+		{"nearest first", geom.Vector{X: 1}, 1000, []testID{2, 1}},
+		{"stops at length", geom.Vector{X: 1}, 150, []testID{2}},
+		{"wraps round the edge", geom.Vector{X: -1}, 200, []testID{4}},
+		{"misses", geom.Vector{Y: -1}, 500, nil},
+	}
+	for _, test := range tests {
+		var hits = index.Ray(geom.Vector{X: 100, Y: 100}, test.direction, test.length)
+		var got []testID
+		for _, hit := range hits {
+			got = append(got, hit.ID)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: hit %v, want %v", test.name, got, test.want)
+		}
+		if !slices.IsSortedFunc(hits, func(a, b RayHit[testID]) int { return cmp.Compare(a.Distance, b.Distance) }) {
+			t.Errorf("%s: hits out of order: %+v", test.name, hits)
+		}
+	}
+	var hits = index.Ray(geom.Vector{X: 100, Y: 100}, geom.Vector{X: 1}, 1000)
+	// entry 2 is 4 off the ray with radius 10, so the ray enters it
+	// sqrt(100-16) short of its center
+	if want := 100 - math.Sqrt(84); math.Abs(hits[0].Distance-want) > 1e-9 {
+		t.Errorf("hit entry 2 at %v, want %v", hits[0].Distance, want)
+	}
+}
@@ -0,0 +1,289 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/StCredZero/vectrek/ecs"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/coder/websocket"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClosed = errors.New("connection closed")
+	// ErrSlow closes a connection whose client doesn't read what it's sent
+	// fast enough.
+	ErrSlow = errors.New("client too slow")
+)
+
+const (
+	// queueSize is how many messages a connection holds each way: read and
+	// not yet received, or sent and not yet written.
+	queueSize = 1000
+	// writeTimeout is how long writing one message may take.
+	writeTimeout = 5 * time.Second
+)
+
+// Conn is one WebSocket connection. It is both an ecstypes.Sender and an
+// ecstypes.Receiver, so it can be handed straight to Instance.SetSender and
+// Instance.SetReceiver on the client side.
+//
+// Send never blocks: messages are queued for a goroutine to write. If the
+// queue fills up, or a write takes longer than writeTimeout, the connection
+// is closed with ErrSlow rather than hold up the simulation.
+type Conn struct {
+	ws     *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	inbox  chan ecstypes.ComponentMessage
+	outbox chan []byte
+
+	mu     sync.Mutex
+	err    error
+	bound  bool
+	entity ecstypes.EntityID
+}
+
+func newConn(ws *websocket.Conn, inbox chan ecstypes.ComponentMessage) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := &Conn{
+		ws:     ws,
+		ctx:    ctx,
+		cancel: cancel,
+		inbox:  inbox,
+		outbox: make(chan []byte, queueSize),
+	}
+	go conn.writeLoop()
+	return conn
+}
+
+// Dial connects to a WebSocket server. It works both natively and when
+// compiled to WebAssembly, where it uses the browser's WebSocket.
+func Dial(ctx context.Context, url string) (*Conn, error) {
+	ws, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dialing %s: %w", url, err)
+	}
+	conn := newConn(ws, make(chan ecstypes.ComponentMessage, queueSize))
+	go conn.readLoop()
+	return conn, nil
+}
+
+// Bind restricts what is read from the connection to messages about entity,
+// the one its client plays. Anything else the client sends is dropped, so
+// it can't give orders to other players' ships.
+func (c *Conn) Bind(entity ecstypes.EntityID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bound, c.entity = true, entity
+}
+
+// accepts reports whether a message about entity may be read.
+func (c *Conn) accepts(entity ecstypes.EntityID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.bound || c.entity == entity
+}
+
+// plays reports whether the connection is bound to one of players.
+func (c *Conn) plays(players []ecstypes.EntityID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bound && slices.Contains(players, c.entity)
+}
+
+func (c *Conn) readLoop() {
+	defer c.cancel()
+	for {
+		msgType, data, err := c.ws.Read(c.ctx)
+		if err != nil {
+			c.fail(fmt.Errorf("reading: %w", err))
+			return
+		}
+		if msgType != websocket.MessageBinary {
+			continue
+		}
+		msg, err := ecs.DecodeMessage(data)
+		if err != nil {
+			c.fail(fmt.Errorf("decoding: %w", err))
+			return
+		}
+		if !c.accepts(msg.Entity) {
+			continue
+		}
+		select {
+		case c.inbox <- msg:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Conn) writeLoop() {
+	for {
+		select {
+		case data := <-c.outbox:
+			ctx, cancel := context.WithTimeout(c.ctx, writeTimeout)
+			err := c.ws.Write(ctx, websocket.MessageBinary, data)
+			cancel()
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = ErrSlow
+			}
+			if err != nil {
+				c.fail(fmt.Errorf("writing: %w", err))
+				c.cancel()
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Conn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Err returns the error that closed the connection, if any.
+func (c *Conn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Done is closed once the connection stops reading.
+func (c *Conn) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+func (c *Conn) Send(msg ecstypes.ComponentMessage) {
+	data, err := ecs.EncodeMessage(msg)
+	if err != nil {
+		c.fail(fmt.Errorf("encoding: %w", err))
+		return
+	}
+	select {
+	case c.outbox <- data:
+	case <-c.ctx.Done():
+	default:
+		c.fail(fmt.Errorf("sending: %w", ErrSlow))
+		c.cancel()
+	}
+}
+
+func (c *Conn) Receive() (ecstypes.ComponentMessage, bool) {
+	select {
+	case msg := <-c.inbox:
+		return msg, true
+	default:
+		return ecstypes.ComponentMessage{}, false
+	}
+}
+
+func (c *Conn) Close() error {
+	c.fail(ErrClosed)
+	c.cancel()
+	return c.ws.Close(websocket.StatusNormalClosure, "")
+}
+
+// Server accepts WebSocket clients over HTTP. Messages from every client are
+// merged into one Receiver, and Send broadcasts to all connected clients, so
+// a Server can be used as the server Instance's Sender and Receiver. SendTo
+// reaches only the clients bound to some players, for a Galaxy to tell each
+// client about its own sector. A
+// client that falls behind is disconnected rather than slow the others.
+//
+// OnConnect, if set, is called with each new connection before anything is
+// read from it, so the server can Bind it to the entity its client plays.
+type Server struct {
+	AcceptOptions *websocket.AcceptOptions
+	OnConnect     func(conn *Conn)
+
+	mu    sync.Mutex
+	conns map[*Conn]struct{}
+	inbox chan ecstypes.ComponentMessage
+}
+
+func NewServer() *Server {
+	return &Server{
+		conns: make(map[*Conn]struct{}),
+		inbox: make(chan ecstypes.ComponentMessage, queueSize),
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocket.Accept(w, r, s.AcceptOptions)
+	if err != nil {
+		return
+	}
+	conn := newConn(ws, s.inbox)
+	if s.OnConnect != nil {
+		s.OnConnect(conn)
+	}
+	go conn.readLoop()
+	s.mu.Lock()
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	select {
+	case <-conn.Done():
+	case <-r.Context().Done():
+	}
+
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+func (s *Server) Send(msg ecstypes.ComponentMessage) {
+	s.mu.Lock()
+	conns := make([]*Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		conn.Send(msg)
+	}
+}
+
+// SendTo sends msg only to the clients bound to one of players, which makes
+// a Server an ecstypes.Router.
+func (s *Server) SendTo(players []ecstypes.EntityID, msg ecstypes.ComponentMessage) {
+	s.mu.Lock()
+	conns := make([]*Conn, 0, len(players))
+	for conn := range s.conns {
+		if conn.plays(players) {
+			conns = append(conns, conn)
+		}
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		conn.Send(msg)
+	}
+}
+
+func (s *Server) Receive() (ecstypes.ComponentMessage, bool) {
+	select {
+	case msg := <-s.inbox:
+		return msg, true
+	default:
+		return ecstypes.ComponentMessage{}, false
+	}
+}
+
+// ConnectionCount returns the number of connected clients.
+func (s *Server) ConnectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
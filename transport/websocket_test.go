@@ -0,0 +1,185 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"github.com/StCredZero/vectrek/ecs"
+	"github.com/StCredZero/vectrek/ecstypes"
+	"github.com/StCredZero/vectrek/geom"
+	"github.com/coder/websocket"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventually polls until done reports true or a second has passed.
+func eventually(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if done() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func receive(t *testing.T, receiver ecstypes.Receiver) ecstypes.ComponentMessage {
+	t.Helper()
+	var msg ecstypes.ComponentMessage
+	eventually(t, "a message", func() bool {
+		var ok bool
+		msg, ok = receiver.Receive()
+		return ok
+	})
+	return msg
+}
+
+// listen serves server on localhost and returns its WebSocket URL.
+func listen(t *testing.T, server *Server) string {
+	t.Helper()
+	var http = httptest.NewServer(server)
+	t.Cleanup(http.Close)
+	return "ws" + strings.TrimPrefix(http.URL, "http")
+}
+
+// dial connects a client and waits for server to count it.
+func dial(t *testing.T, server *Server, url string) *Conn {
+	t.Helper()
+	var connected = server.ConnectionCount()
+	conn, err := Dial(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	eventually(t, "the server to accept", func() bool {
+		return server.ConnectionCount() > connected
+	})
+	return conn
+}
+
+func TestClientAndServerExchangeMessages(t *testing.T) {
+	var server = NewServer()
+	var conn = dial(t, server, listen(t, server))
+
+	var input = ecs.HelmInput{Left: true, Thrust: true}
+	conn.Send(ecstypes.ComponentMessage{Entity: 7, Payload: input})
+	if msg := receive(t, server); msg.Entity != 7 || msg.Payload != any(input) {
+		t.Fatalf("server received %+v, want %+v for entity 7", msg, input)
+	}
+
+	var sync = ecs.SyncInput{Position: geom.Vector{X: 1, Y: 2}, Velocity: geom.Vector{X: -3}, Angle: 1.5}
+	server.Send(ecstypes.ComponentMessage{Entity: 7, Payload: sync})
+	if msg := receive(t, conn); msg.Entity != 7 || msg.Payload != any(sync) {
+		t.Fatalf("client received %+v, want %+v for entity 7", msg, sync)
+	}
+	if err := conn.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerSendsToEveryClient(t *testing.T) {
+	var server = NewServer()
+	var url = listen(t, server)
+	var clients = []*Conn{dial(t, server, url), dial(t, server, url)}
+
+	var sync = ecs.SyncInput{Position: geom.Vector{X: 10}, Velocity: geom.Vector{X: 20}}
+	server.Send(ecstypes.ComponentMessage{Entity: 3, Payload: sync})
+	for index, conn := range clients {
+		if msg := receive(t, conn); msg.Entity != 3 || msg.Payload != any(sync) {
+			t.Fatalf("client %d received %+v", index, msg)
+		}
+	}
+}
+
+func TestServerSendsToPlayers(t *testing.T) {
+	var server = NewServer()
+	var players = make(chan ecstypes.EntityID, 2)
+	players <- 1
+	players <- 2
+	server.OnConnect = func(conn *Conn) {
+		conn.Bind(<-players)
+	}
+	var url = listen(t, server)
+	var first, second = dial(t, server, url), dial(t, server, url)
+
+	var sync = ecs.SyncInput{Position: geom.Vector{X: 10}}
+	server.SendTo([]ecstypes.EntityID{2}, ecstypes.ComponentMessage{Entity: 2, Payload: sync})
+	if msg := receive(t, second); msg.Entity != 2 {
+		t.Fatalf("player 2 received %+v", msg)
+	}
+	// messages arrive in order, so had the first been sent anything, it
+	// would come before this
+	server.Send(ecstypes.ComponentMessage{Entity: 5, Payload: sync})
+	if msg := receive(t, first); msg.Entity != 5 {
+		t.Errorf("player 1 received %+v, meant for player 2", msg)
+	}
+}
+
+func TestBoundConnectionDropsOtherEntities(t *testing.T) {
+	var server = NewServer()
+	server.OnConnect = func(conn *Conn) {
+		conn.Bind(1)
+	}
+	var conn = dial(t, server, listen(t, server))
+
+	conn.Send(ecstypes.ComponentMessage{Entity: 2, Payload: ecs.HelmInput{Right: true}})
+	conn.Send(ecstypes.ComponentMessage{Entity: 1, Payload: ecs.HelmInput{Thrust: true}})
+	if msg := receive(t, server); msg.Entity != 1 {
+		t.Fatalf("server received a message about entity %d from a client bound to 1", msg.Entity)
+	}
+	if msg, ok := server.Receive(); ok {
+		t.Fatalf("server received %+v as well", msg)
+	}
+}
+
+func TestCloseDisconnects(t *testing.T) {
+	var server = NewServer()
+	var conn = dial(t, server, listen(t, server))
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the server to drop the client", func() bool {
+		return server.ConnectionCount() == 0
+	})
+	<-conn.Done()
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	var server = NewServer()
+	var accepted = make(chan *Conn, 1)
+	server.OnConnect = func(conn *Conn) {
+		accepted <- conn
+	}
+	// a client that never reads
+	ws, _, err := websocket.Dial(context.Background(), listen(t, server), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ws.CloseNow()
+	})
+	var conn = <-accepted
+
+	var msg = ecstypes.ComponentMessage{Entity: 3, Payload: ecs.SyncInput{Position: geom.Vector{X: 10}}}
+	var slowest time.Duration
+	for deadline := time.Now().Add(10 * time.Second); conn.Err() == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("still sending to a client that doesn't read")
+		}
+		var start = time.Now()
+		server.Send(msg)
+		slowest = max(slowest, time.Since(start))
+	}
+	if !errors.Is(conn.Err(), ErrSlow) {
+		t.Errorf("dropped with %v, want ErrSlow", conn.Err())
+	}
+	if slowest > 100*time.Millisecond {
+		t.Errorf("a Send took %v", slowest)
+	}
+	eventually(t, "the server to drop the client", func() bool {
+		return server.ConnectionCount() == 0
+	})
+}